@@ -0,0 +1,274 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+// ErrWebhookNotFound 指定的通知渠道不存在
+var ErrWebhookNotFound = errors.New("webhook 不存在")
+
+// Alert 一条告警
+type Alert struct {
+	Rule     string    `json:"rule"`
+	Type     string    `json:"type"`
+	Level    string    `json:"level"`
+	Account  string    `json:"account,omitempty"`
+	Message  string    `json:"message"`
+	Value    float64   `json:"value"`
+	Resolved bool      `json:"resolved"`
+	Since    time.Time `json:"since"` // 开始触发的时间
+	Time     time.Time `json:"time"`
+}
+
+// Source 规则求值所需的数据
+type Source interface {
+	Accounts() []string
+	Token(account string) (valid bool, expiresAt time.Time)
+	RefreshError(account string) string                           // 最近一次刷新成功时返回空
+	Requests(account string, since time.Time) (total, failed int) // since 之后的上游请求数与失败数
+	TodoCount(account, key string, at time.Time) (int, bool)      // at 时刻之前最近一次采样的数量
+}
+
+type state struct {
+	alert    Alert
+	notified time.Time
+}
+
+// Manager 定时检查告警规则并发送通知，同一告警在冷却时间内只通知一次
+type Manager struct {
+	source Source
+	client *http.Client
+
+	mu     sync.Mutex
+	states map[string]*state // 规则名|账号 -> 触发状态
+	now    func() time.Time
+}
+
+// NewManager 创建告警管理器
+func NewManager(source Source) *Manager {
+	return &Manager{
+		source: source,
+		client: &http.Client{Timeout: 10 * time.Second},
+		states: make(map[string]*state),
+		now:    time.Now,
+	}
+}
+
+// Check 检查所有规则，返回通知发送失败的错误
+func (m *Manager) Check() error {
+	rules, hooks, cooldown := config.GetAlertSettings()
+	now := m.now()
+
+	type pending struct {
+		alert Alert
+		hooks []config.AlertWebhook
+	}
+	var notify []pending
+	seen := make(map[string]bool)
+
+	m.mu.Lock()
+	for _, rule := range rules {
+		if rule.Disabled {
+			continue
+		}
+		accounts := []string{rule.Account}
+		if rule.Account == "" {
+			accounts = m.source.Accounts()
+		}
+		for _, account := range accounts {
+			key := rule.Name + "|" + account
+			seen[key] = true
+			firing, value, msg := m.evaluate(rule, account, now)
+
+			st := m.states[key]
+			if !firing {
+				if st != nil {
+					delete(m.states, key)
+					if !st.notified.IsZero() {
+						resolved := st.alert
+						resolved.Resolved = true
+						resolved.Time = now
+						resolved.Message = "已恢复: " + st.alert.Message
+						notify = append(notify, pending{resolved, selectHooks(hooks, rule.Webhooks)})
+					}
+				}
+				continue
+			}
+
+			if st == nil {
+				st = &state{alert: Alert{Rule: rule.Name, Type: rule.Type, Level: level(rule), Account: account, Since: now}}
+				m.states[key] = st
+				logger.Warn("告警 [%s] %s: %s", rule.Name, account, msg)
+			}
+			st.alert.Value = value
+			st.alert.Message = msg
+			st.alert.Time = now
+
+			wait := time.Duration(cooldown) * time.Minute
+			if rule.Cooldown > 0 {
+				wait = time.Duration(rule.Cooldown) * time.Minute
+			}
+			if st.notified.IsZero() || now.Sub(st.notified) >= wait {
+				st.notified = now
+				notify = append(notify, pending{st.alert, selectHooks(hooks, rule.Webhooks)})
+			}
+		}
+	}
+	// 规则被删除或改名后清理残留状态
+	for key := range m.states {
+		if !seen[key] {
+			delete(m.states, key)
+		}
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, p := range notify {
+		for _, hook := range p.hooks {
+			if err := Send(m.client, hook, p.alert); err != nil {
+				errs = append(errs, fmt.Errorf("发送告警到 %s 失败: %w", hook.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// evaluate 对单个账号求值规则
+func (m *Manager) evaluate(rule config.AlertRule, account string, now time.Time) (bool, float64, string) {
+	switch rule.Type {
+	case config.AlertTokenInvalid:
+		valid, _ := m.source.Token(account)
+		return !valid, 0, "Token 已失效，请重新登录"
+
+	case config.AlertTokenExpiring:
+		valid, expiresAt := m.source.Token(account)
+		if !valid || expiresAt.IsZero() {
+			return false, 0, ""
+		}
+		left := expiresAt.Sub(now).Minutes()
+		return left < rule.Threshold, left, fmt.Sprintf("Token 将在 %.0f 分钟后过期 (%s)", left, expiresAt.Format("01-02 15:04"))
+
+	case config.AlertRefreshFailed:
+		msg := m.source.RefreshError(account)
+		return msg != "", 0, "自动刷新失败: " + msg
+
+	case config.AlertErrorRate:
+		total, failed := m.source.Requests(account, now.Add(-window(rule)))
+		if total == 0 || total < rule.MinRequests {
+			return false, 0, ""
+		}
+		rate := float64(failed) / float64(total)
+		return rate > rule.Threshold, rate, fmt.Sprintf("最近 %v 上游失败率 %.0f%% (%d/%d)", window(rule), rate*100, failed, total)
+
+	case config.AlertTodoTotal:
+		n, ok := m.source.TodoCount(account, rule.Key, now)
+		return ok && float64(n) > rule.Threshold, float64(n), fmt.Sprintf("%s数量 %d，超过阈值 %.0f", todoLabel(rule.Key), n, rule.Threshold)
+
+	case config.AlertTodoIncrease:
+		cur, ok := m.source.TodoCount(account, rule.Key, now)
+		base, baseOK := m.source.TodoCount(account, rule.Key, now.Add(-window(rule)))
+		if !ok || !baseOK {
+			return false, 0, ""
+		}
+		delta := float64(cur - base)
+		return delta > rule.Threshold, delta, fmt.Sprintf("%s数量 %v 内从 %d 增加到 %d", todoLabel(rule.Key), window(rule), base, cur)
+	}
+	return false, 0, ""
+}
+
+func window(rule config.AlertRule) time.Duration {
+	if rule.Window <= 0 {
+		return 60 * time.Minute
+	}
+	return time.Duration(rule.Window) * time.Minute
+}
+
+func level(rule config.AlertRule) string {
+	if rule.Level == "" {
+		return "warning"
+	}
+	return rule.Level
+}
+
+func todoLabel(key string) string {
+	if key == "" {
+		return "待办"
+	}
+	return "待办「" + key + "」"
+}
+
+// selectHooks 按名称筛选通知渠道，names 为空表示全部
+func selectHooks(hooks []config.AlertWebhook, names []string) []config.AlertWebhook {
+	if len(names) == 0 {
+		return hooks
+	}
+	var selected []config.AlertWebhook
+	for _, h := range hooks {
+		for _, n := range names {
+			if h.Name == n {
+				selected = append(selected, h)
+			}
+		}
+	}
+	return selected
+}
+
+// Active 返回正在触发的告警
+func (m *Manager) Active() []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Alert, 0, len(m.states))
+	for _, st := range m.states {
+		list = append(list, st.alert)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Since.Before(list[j].Since) })
+	return list
+}
+
+// Test 向指定渠道发送一条测试消息，name 为空时发送到全部渠道
+func (m *Manager) Test(name string) error {
+	_, hooks, _ := config.GetAlertSettings()
+	if name != "" {
+		hooks = selectHooks(hooks, []string{name})
+	}
+	if len(hooks) == 0 {
+		return ErrWebhookNotFound
+	}
+
+	now := m.now()
+	a := Alert{Rule: "测试告警", Type: "test", Level: "info", Message: "这是一条测试消息，收到说明告警通道配置正确", Since: now, Time: now}
+	var errs []error
+	for _, hook := range hooks {
+		if err := Send(m.client, hook, a); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hook.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PushImage 把图片推送到指定名称的渠道，names 为空时不推送
+func (m *Manager) PushImage(names []string, title, text string, png []byte) error {
+	if len(names) == 0 {
+		return nil
+	}
+	_, hooks, _ := config.GetAlertSettings()
+	hooks = selectHooks(hooks, names)
+	if len(hooks) == 0 {
+		return ErrWebhookNotFound
+	}
+	var errs []error
+	for _, hook := range hooks {
+		if err := SendImage(m.client, hook, title, text, png); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hook.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,187 @@
+package alert
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"zto-api-proxy/config"
+)
+
+type fakeSource struct {
+	valid      bool
+	expiresAt  time.Time
+	refreshErr string
+	total      int
+	failed     int
+	todo       map[time.Time]int
+}
+
+func (f *fakeSource) Accounts() []string { return []string{"default"} }
+func (f *fakeSource) Token(string) (bool, time.Time) {
+	return f.valid, f.expiresAt
+}
+func (f *fakeSource) RefreshError(string) string            { return f.refreshErr }
+func (f *fakeSource) Requests(string, time.Time) (int, int) { return f.total, f.failed }
+func (f *fakeSource) TodoCount(_, _ string, at time.Time) (int, bool) {
+	n, ok := f.todo[at]
+	return n, ok
+}
+
+// receiver 记录收到的 webhook 请求
+type receiver struct {
+	mu     sync.Mutex
+	bodies []map[string]interface{}
+	urls   []string
+}
+
+func newReceiver(t *testing.T, reply string) (*receiver, *httptest.Server) {
+	rec := &receiver{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		rec.mu.Lock()
+		rec.bodies = append(rec.bodies, body)
+		rec.urls = append(rec.urls, r.URL.String())
+		rec.mu.Unlock()
+		w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+	return rec, srv
+}
+
+func setAlertConfig(t *testing.T, rules []config.AlertRule, hooks []config.AlertWebhook) {
+	cfg := config.GetConfig()
+	oldRules, oldHooks, oldCooldown := cfg.AlertRules, cfg.AlertWebhooks, cfg.AlertCooldown
+	cfg.AlertRules, cfg.AlertWebhooks, cfg.AlertCooldown = rules, hooks, 60
+	t.Cleanup(func() { cfg.AlertRules, cfg.AlertWebhooks, cfg.AlertCooldown = oldRules, oldHooks, oldCooldown })
+}
+
+func TestManager_CooldownAndResolve(t *testing.T) {
+	rec, srv := newReceiver(t, `{"ok":true}`)
+	setAlertConfig(t,
+		[]config.AlertRule{{Name: "token", Type: config.AlertTokenInvalid}},
+		[]config.AlertWebhook{{Name: "hook", Type: WebhookGeneric, URL: srv.URL}})
+
+	now := time.Date(2025, 12, 3, 8, 0, 0, 0, time.Local)
+	src := &fakeSource{valid: false}
+	m := NewManager(src)
+	m.now = func() time.Time { return now }
+
+	if err := m.Check(); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(10 * time.Minute)
+	m.Check() // 冷却期内不重复通知
+	if len(rec.bodies) != 1 || len(m.Active()) != 1 {
+		t.Fatalf("冷却期内应只通知一次: %d", len(rec.bodies))
+	}
+
+	now = now.Add(time.Hour)
+	m.Check()
+	if len(rec.bodies) != 2 {
+		t.Errorf("冷却结束后应再次通知: %d", len(rec.bodies))
+	}
+
+	src.valid = true
+	m.Check()
+	if len(rec.bodies) != 3 || rec.bodies[2]["resolved"] != true || len(m.Active()) != 0 {
+		t.Errorf("恢复时应发送恢复通知: %+v", rec.bodies)
+	}
+}
+
+func TestManager_Rules(t *testing.T) {
+	now := time.Date(2025, 12, 3, 8, 0, 0, 0, time.Local)
+	src := &fakeSource{
+		valid:      true,
+		expiresAt:  now.Add(10 * time.Minute),
+		refreshErr: "登录超时",
+		total:      10,
+		failed:     6,
+		todo:       map[time.Time]int{now: 120, now.Add(-30 * time.Minute): 50},
+	}
+	rules := []config.AlertRule{
+		{Name: "expiring", Type: config.AlertTokenExpiring, Threshold: 15},
+		{Name: "refresh", Type: config.AlertRefreshFailed},
+		{Name: "errors", Type: config.AlertErrorRate, Threshold: 0.5, MinRequests: 5},
+		{Name: "errors-few", Type: config.AlertErrorRate, Threshold: 0.5, MinRequests: 20},
+		{Name: "todo", Type: config.AlertTodoTotal, Threshold: 100},
+		{Name: "spike", Type: config.AlertTodoIncrease, Threshold: 50, Window: 30},
+		{Name: "spike-high", Type: config.AlertTodoIncrease, Threshold: 100, Window: 30},
+		{Name: "off", Type: config.AlertTokenExpiring, Threshold: 15, Disabled: true},
+	}
+	setAlertConfig(t, rules, nil)
+
+	m := NewManager(src)
+	m.now = func() time.Time { return now }
+	m.Check()
+
+	fired := map[string]bool{}
+	for _, a := range m.Active() {
+		fired[a.Rule] = true
+	}
+	for _, name := range []string{"expiring", "refresh", "errors", "todo", "spike"} {
+		if !fired[name] {
+			t.Errorf("规则 %s 应触发", name)
+		}
+	}
+	for _, name := range []string{"errors-few", "spike-high", "off"} {
+		if fired[name] {
+			t.Errorf("规则 %s 不应触发", name)
+		}
+	}
+}
+
+func TestSend_Formats(t *testing.T) {
+	a := Alert{Rule: "Token 失效", Level: "critical", Account: "default", Message: "Token 已失效", Time: time.Now()}
+
+	rec, srv := newReceiver(t, `{"errcode":0,"errmsg":"ok"}`)
+	if err := Send(http.DefaultClient, config.AlertWebhook{Type: WebhookDingTalk, URL: srv.URL + "/robot/send?access_token=x", Secret: "SEC"}, a); err != nil {
+		t.Fatal(err)
+	}
+	md, _ := rec.bodies[0]["markdown"].(map[string]interface{})
+	if rec.bodies[0]["msgtype"] != "markdown" || !strings.Contains(md["text"].(string), "Token 已失效") {
+		t.Errorf("钉钉消息格式不正确: %+v", rec.bodies[0])
+	}
+	if !strings.Contains(rec.urls[0], "access_token=x&timestamp=") || !strings.Contains(rec.urls[0], "&sign=") {
+		t.Errorf("钉钉加签参数缺失: %s", rec.urls[0])
+	}
+
+	if err := Send(http.DefaultClient, config.AlertWebhook{Type: WebhookWeCom, URL: srv.URL}, a); err != nil {
+		t.Fatal(err)
+	}
+	md, _ = rec.bodies[1]["markdown"].(map[string]interface{})
+	if !strings.Contains(md["content"].(string), "[告警] Token 失效") {
+		t.Errorf("企业微信消息格式不正确: %+v", rec.bodies[1])
+	}
+
+	_, bad := newReceiver(t, `{"errcode":310000,"errmsg":"sign not match"}`)
+	if err := Send(http.DefaultClient, config.AlertWebhook{Type: WebhookDingTalk, URL: bad.URL}, a); err == nil || !strings.Contains(err.Error(), "310000") {
+		t.Errorf("errcode 非 0 应返回错误: %v", err)
+	}
+}
+
+func TestSendImage(t *testing.T) {
+	png := []byte("\x89PNG fake")
+	rec, srv := newReceiver(t, `{"errcode":0,"errmsg":"ok"}`)
+
+	if err := SendImage(http.DefaultClient, config.AlertWebhook{Type: WebhookWeCom, URL: srv.URL}, "扫码登录", "账号: default", png); err != nil {
+		t.Fatal(err)
+	}
+	img, _ := rec.bodies[1]["image"].(map[string]interface{})
+	if len(rec.bodies) != 2 || rec.bodies[1]["msgtype"] != "image" || img["base64"] != base64.StdEncoding.EncodeToString(png) {
+		t.Errorf("企业微信应先发文字再发图片: %+v", rec.bodies)
+	}
+
+	if err := SendImage(http.DefaultClient, config.AlertWebhook{URL: srv.URL}, "扫码登录", "账号: default", png); err != nil {
+		t.Fatal(err)
+	}
+	if rec.bodies[2]["type"] != "image" || rec.bodies[2]["image"] == "" {
+		t.Errorf("通用 webhook 应包含 base64 图片: %+v", rec.bodies[2])
+	}
+}
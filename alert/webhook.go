@@ -0,0 +1,164 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"zto-api-proxy/config"
+)
+
+// Webhook 类型
+const (
+	WebhookDingTalk = "dingtalk"
+	WebhookWeCom    = "wecom"
+	WebhookGeneric  = "generic"
+)
+
+// Send 按渠道类型发送告警
+func Send(client *http.Client, hook config.AlertWebhook, a Alert) error {
+	target := hook.URL
+	var payload interface{}
+
+	switch hook.Type {
+	case WebhookDingTalk:
+		payload = map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"title": title(a), "text": markdown(a)},
+		}
+		if hook.Secret != "" {
+			target = signDingTalk(target, hook.Secret, time.Now())
+		}
+	case WebhookWeCom:
+		payload = map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"content": markdown(a)},
+		}
+	case WebhookGeneric, "":
+		payload = a
+	default:
+		return fmt.Errorf("不支持的 webhook 类型: %s", hook.Type)
+	}
+
+	return post(client, hook, target, payload)
+}
+
+// SendImage 推送一张 PNG 图片 (如登录二维码)。企业微信直接发送图片消息，
+// 钉钉机器人不支持上传图片，只发送文字提示
+func SendImage(client *http.Client, hook config.AlertWebhook, title, text string, png []byte) error {
+	target := hook.URL
+	switch hook.Type {
+	case WebhookDingTalk:
+		if hook.Secret != "" {
+			target = signDingTalk(target, hook.Secret, time.Now())
+		}
+		return post(client, hook, target, map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"title": title, "text": "### " + title + "\n" + text + "\n\n> 钉钉不支持直接推送图片，请在控制面板中查看"},
+		})
+	case WebhookWeCom:
+		if err := post(client, hook, target, map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"content": "### " + title + "\n" + text},
+		}); err != nil {
+			return err
+		}
+		sum := md5.Sum(png)
+		return post(client, hook, target, map[string]interface{}{
+			"msgtype": "image",
+			"image":   map[string]string{"base64": base64.StdEncoding.EncodeToString(png), "md5": hex.EncodeToString(sum[:])},
+		})
+	case WebhookGeneric, "":
+		return post(client, hook, target, map[string]interface{}{
+			"type":    "image",
+			"title":   title,
+			"message": text,
+			"image":   base64.StdEncoding.EncodeToString(png),
+			"time":    time.Now(),
+		})
+	default:
+		return fmt.Errorf("不支持的 webhook 类型: %s", hook.Type)
+	}
+}
+
+func post(client *http.Client, hook config.AlertWebhook, target string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for k, v := range hook.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	// 钉钉与企业微信在 HTTP 200 中通过 errcode 返回错误
+	if hook.Type == WebhookDingTalk || hook.Type == WebhookWeCom {
+		var result struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if json.Unmarshal(respBody, &result) == nil && result.ErrCode != 0 {
+			return fmt.Errorf("errcode %d: %s", result.ErrCode, result.ErrMsg)
+		}
+	}
+	return nil
+}
+
+// signDingTalk 钉钉机器人加签: sign = base64(HmacSHA256(timestamp + "\n" + secret))
+func signDingTalk(rawURL, secret string, now time.Time) string {
+	ts := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + "timestamp=" + ts + "&sign=" + url.QueryEscape(sign)
+}
+
+func title(a Alert) string {
+	prefix := "[告警]"
+	if a.Resolved {
+		prefix = "[恢复]"
+	}
+	return prefix + " " + a.Rule
+}
+
+func markdown(a Alert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n", title(a))
+	if a.Account != "" {
+		fmt.Fprintf(&b, "- 账号: %s\n", a.Account)
+	}
+	fmt.Fprintf(&b, "- 级别: %s\n", a.Level)
+	fmt.Fprintf(&b, "- 详情: %s\n", a.Message)
+	fmt.Fprintf(&b, "- 时间: %s\n", a.Time.Format("2006-01-02 15:04:05"))
+	return b.String()
+}
@@ -4,11 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chromedp/cdproto/network"
@@ -16,103 +16,158 @@ import (
 
 	"zto-api-proxy/config"
 	"zto-api-proxy/logger"
+	"zto-api-proxy/procdetect"
 )
 
 // Browser 浏览器自动化
 type Browser struct {
-	chromeDataDir string
+	mu         sync.RWMutex
+	strategies map[string]LoginStrategy
+	timelines  map[string]Timeline // 账号 -> 最近一次刷新的步骤记录
+	procs      procdetect.Lister   // 检测宝盒进程
 }
 
-// NewBrowser 创建浏览器实例
+// NewBrowser 创建浏览器实例并注册内置登录方式
 func NewBrowser() *Browser {
-	cfg := config.GetConfig()
-	return &Browser{
-		chromeDataDir: filepath.Join(cfg.DataDir, "chrome-data"),
-	}
+	b := &Browser{strategies: make(map[string]LoginStrategy), timelines: make(map[string]Timeline), procs: procdetect.System()}
+	b.Register(&ZBoxStrategy{b: b})
+	b.Register(&ProfileStrategy{b: b})
+	b.Register(CookieStrategy{})
+	b.Register(&QRStrategy{b: b})
+	return b
+}
+
+// Register 注册或替换登录方式
+func (b *Browser) Register(s LoginStrategy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.strategies[s.Name()] = s
 }
 
-// RefreshToken 通过自动登录刷新 Token
+// Strategy 按名称查找登录方式
+func (b *Browser) Strategy(name string) (LoginStrategy, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	s, ok := b.strategies[name]
+	return s, ok
+}
+
+// RefreshToken 通过自动登录刷新默认账号的 Token
 func (b *Browser) RefreshToken() error {
-	logger.Token("开始自动刷新 Token...")
+	return b.RefreshAccount(config.DefaultAccount)
+}
 
-	if !b.isZBoxRunning() {
-		return fmt.Errorf("宝盒未运行，无法自动登录")
+// SetQRHandler 设置扫码登录的二维码展示回调
+func (b *Browser) SetQRHandler(fn func(account string, png []byte)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if q, ok := b.strategies[StrategyQR].(*QRStrategy); ok {
+		q.OnCode = fn
 	}
+}
 
-	ctx, cancel := b.createContext()
-	defer cancel()
-
-	ctx, timeoutCancel := context.WithTimeout(ctx, 150*time.Second)
-	defer timeoutCancel()
-
-	var cookies []*network.Cookie
-	var screenshot []byte
-
-	err := chromedp.Run(ctx,
-		chromedp.Navigate("https://www.zt-express.com"),
-
-		// 1. 等待网页充分加载（用户建议 10 秒左右）
-		chromedp.Sleep(12*time.Second),
-
-		// 2. 模拟人工点击一键登录
-		b.tryClickLogin(),
-
-		// 3. 点击后等待流程完成（用户建议再等 5-10 秒，这里给 15 秒确保充分加载）
-		chromedp.Sleep(15*time.Second),
-
-		// 4. 轮询获取 Cookie（增加轮询次数）
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			for i := 0; i < 15; i++ {
-				var err error
-				cookies, err = network.GetCookies().Do(ctx)
-				if err != nil {
-					return err
-				}
-
-				found := false
-				for _, c := range cookies {
-					if c.Name == "wyzdzjxhdnh" {
-						found = true
-						break
-					}
-				}
-				if found {
-					logger.Token("轮询检测：获取到核心 Token Cookie")
-					return nil
-				}
-				logger.Token("轮询检测：核心 Cookie 尚未出现 (%d/15)", i+1)
-				chromedp.Sleep(2 * time.Second).Do(ctx)
-			}
-
-			// 失败时截屏
-			chromedp.CaptureScreenshot(&screenshot).Do(ctx)
-			debugDir := filepath.Join(config.GetConfig().DataDir, "debug")
-			os.MkdirAll(debugDir, 0755)
-			screenshotPath := filepath.Join(debugDir, "login_failed.png")
-			os.WriteFile(screenshotPath, screenshot, 0644)
-			logger.Warn("Token 刷新失败，截图已保存: %s", screenshotPath)
-
-			return fmt.Errorf("未能获取 wyzdzjxhdnh cookie (最终共 %d 个)", len(cookies))
-		}),
-	)
+// RefreshAccount 按账号配置的登录方式依次尝试，第一个成功的结果写入 Token
+func (b *Browser) RefreshAccount(account string) error {
+	return b.RefreshAccountWith(context.Background(), account)
+}
 
-	if err != nil {
-		return err
+// RefreshAccountWith 使用指定的登录方式刷新，names 为空时使用账号配置。
+// ctx 中的请求 ID 会写入刷新日志
+func (b *Browser) RefreshAccountWith(ctx context.Context, account string, names ...string) error {
+	acc, ok := config.GetAccount(account)
+	if !ok {
+		return fmt.Errorf("未知账号: %s", account)
+	}
+	if len(names) == 0 {
+		names = strategyNames(acc)
+	}
+	log := logger.With(logger.CategoryToken, "account", acc.Name)
+	log.InfoContext(ctx, "开始自动刷新 Token", "strategies", strings.Join(names, " -> "))
+
+	rec := newRecorder(acc.Name)
+	ctx = withRecorder(ctx, rec)
+
+	var errs []error
+	for _, name := range names {
+		strategy, ok := b.Strategy(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("未知登录方式: %s", name))
+			continue
+		}
+
+		rec.setPrefix(name + ": ")
+		tokenData, err := strategy.Login(ctx, acc)
+		if err != nil {
+			log.WarnContext(ctx, "登录方式失败", "strategy", name, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := config.SetAccountToken(acc.Name, tokenData); err != nil {
+			err = fmt.Errorf("保存 Token 失败: %w", err)
+			b.finish(ctx, rec, name, err)
+			return err
+		}
+		b.finish(ctx, rec, name, nil)
+		log.InfoContext(ctx, "Token 刷新成功", "strategy", name, "expires_at", tokenData.ExpiresAt.Format("2006-01-02 15:04:05"))
+		return nil
+	}
+
+	err := errs[0]
+	if len(errs) > 1 {
+		err = errors.Join(errs...)
+	}
+	b.finish(ctx, rec, "", err)
+	return err
+}
+
+// finish 结束记录，保存诊断包与步骤记录
+func (b *Browser) finish(ctx context.Context, rec *recorder, strategy string, err error) {
+	tl := rec.finish(strategy, err)
+	tl.Bundle = b.saveDiagnostics(ctx, rec, tl)
+	b.saveTimeline(ctx, tl)
+}
+
+func (b *Browser) saveTimeline(ctx context.Context, tl Timeline) {
+	b.mu.Lock()
+	b.timelines[tl.Account] = tl
+	b.mu.Unlock()
+	logger.With(logger.CategoryToken, "account", tl.Account).InfoContext(ctx, "刷新结束",
+		"duration", tl.End.Sub(tl.Start).Round(time.Millisecond).String(), "steps", len(tl.Steps))
+}
+
+// LastTimeline 返回账号最近一次刷新的步骤记录
+func (b *Browser) LastTimeline(account string) (Timeline, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	tl, ok := b.timelines[account]
+	return tl, ok
+}
+
+// cookieMap 提取中通域名下的 Cookie
+func cookieMap(cookies []*network.Cookie) map[string]string {
+	m := make(map[string]string)
+	for _, cookie := range cookies {
+		if strings.Contains(cookie.Domain, "zt-express.com") {
+			m[cookie.Name] = cookie.Value
+		}
 	}
+	return m
+}
 
+// buildToken 由 Cookie 生成 Token 数据并解析各 JWT 的失效时间
+func buildToken(cookies map[string]string) (*config.TokenData, error) {
 	tokenData := &config.TokenData{
 		Cookies:     make(map[string]string),
 		LastRefresh: time.Now(),
 	}
 
-	for _, cookie := range cookies {
-		if strings.Contains(cookie.Domain, "zt-express.com") {
-			tokenData.Cookies[cookie.Name] = cookie.Value
-			if cookie.Name == "wyandyy" {
-				tokenData.AppExpire = extractExpireTime(cookie.Value, 10)
-			} else if cookie.Name == "wyzdzjxhdnh" {
-				tokenData.SessExpire = extractExpireTime(cookie.Value, 14*24)
-			}
+	for name, value := range cookies {
+		tokenData.Cookies[name] = value
+		if name == "wyandyy" {
+			tokenData.AppExpire = extractExpireTime(value, 10)
+		} else if name == "wyzdzjxhdnh" {
+			tokenData.SessExpire = extractExpireTime(value, 14*24)
 		}
 	}
 
@@ -123,20 +178,14 @@ func (b *Browser) RefreshToken() error {
 	}
 
 	if _, ok := tokenData.Cookies["wyzdzjxhdnh"]; !ok {
-		return fmt.Errorf("解析结果中缺失核心 Token")
+		return nil, fmt.Errorf("解析结果中缺失核心 Token")
 	}
-
-	if err := config.SetTokenData(tokenData); err != nil {
-		return fmt.Errorf("保存 Token 失败: %w", err)
-	}
-
-	logger.Token("Token 刷新成功，有效期至 %s", tokenData.ExpiresAt.Format("2006-01-02 15:04:05"))
-	return nil
+	return tokenData, nil
 }
 
-func (b *Browser) createContext() (context.Context, context.CancelFunc) {
+func (b *Browser) createContext(parent context.Context, chromeDataDir string) (context.Context, context.CancelFunc) {
 	// 确保数据目录存在
-	os.MkdirAll(b.chromeDataDir, 0755)
+	os.MkdirAll(chromeDataDir, 0755)
 
 	// 配置 Chrome 选项
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
@@ -145,7 +194,7 @@ func (b *Browser) createContext() (context.Context, context.CancelFunc) {
 		chromedp.Flag("no-sandbox", true),
 		chromedp.Flag("disable-dev-shm-usage", true),
 		chromedp.Flag("disable-extensions", false),
-		chromedp.UserDataDir(b.chromeDataDir),
+		chromedp.UserDataDir(chromeDataDir),
 		chromedp.WindowSize(1920, 1080),
 	)
 
@@ -155,103 +204,50 @@ func (b *Browser) createContext() (context.Context, context.CancelFunc) {
 		opts = append(opts, chromedp.ExecPath(cfg.ChromePath))
 	}
 
-	allocCtx, _ := chromedp.NewExecAllocator(context.Background(), opts...)
+	allocCtx, allocCancel := chromedp.NewExecAllocator(parent, opts...)
 	ctx, cancel := chromedp.NewContext(allocCtx,
 		chromedp.WithLogf(func(format string, args ...interface{}) {
-			logger.Debug("[Chrome] "+format, args...)
+			logger.With(logger.CategoryChrome).Debug(fmt.Sprintf(format, args...))
 		}),
 	)
 
-	return ctx, cancel
-}
-
-func (b *Browser) tryClickLogin() chromedp.ActionFunc {
-	return func(ctx context.Context) error {
-		logger.Token("尝试定位一键登录按钮坐标...")
-
-		var coords []float64
-		// 获取按钮中心坐标
-		err := chromedp.Evaluate(`
-			(function() {
-				const findBtn = () => {
-					const all = document.querySelectorAll('button, a, div, span');
-					// 优先找蓝色的按钮（一键登录通常是一个大按钮）
-					return Array.from(all).find(el => 
-						el.textContent.trim() === '一键登录' && 
-						el.offsetWidth > 50 && 
-						el.offsetHeight > 20
-					);
-				};
-				const btn = findBtn();
-				if (btn) {
-					const rect = btn.getBoundingClientRect();
-					return [rect.left + rect.width / 2, rect.top + rect.height / 2];
-				}
-				return null;
-			})()
-		`, &coords).Do(ctx)
-
-		if err != nil || len(coords) != 2 {
-			logger.Warn("脚本定位按钮坐标失败，尝试使用备用文本搜索点击")
-			return chromedp.Click("//*[contains(text(), '一键登录')]", chromedp.BySearch).Do(ctx)
-		}
-
-		logger.Token("按钮定位成功: (%.2f, %.2f)，执行模拟点击", coords[0], coords[1])
-
-		// 模拟真实的鼠标点击
-		return chromedp.MouseClickXY(coords[0], coords[1]).Do(ctx)
+	return ctx, func() {
+		cancel()
+		allocCancel()
 	}
 }
 
-func (b *Browser) isZBoxRunning() bool {
-	// 使用 wmic 命令检测进程，避免中文编码问题
-	cmd := exec.Command("wmic", "process", "where", "name like '%zbox%' or name like '%lite%'", "get", "processid")
-	output, err := cmd.Output()
+// extractExpireTime 解析 JWT 失效时间，无法解析时按 defaultHours 估算
+func extractExpireTime(jwtStr string, defaultHours int) time.Time {
+	exp, err := parseJWTExpire(jwtStr)
 	if err != nil {
-		// 备用方案：检查进程列表
-		cmd2 := exec.Command("powershell", "-Command", "Get-Process | Where-Object {$_.ProcessName -like '*zbox*' -or $_.ProcessName -like '*lite*'} | Select-Object -First 1")
-		output2, err2 := cmd2.Output()
-		if err2 != nil {
-			logger.Debug("进程检测失败: %v", err2)
-			return false
-		}
-		return len(strings.TrimSpace(string(output2))) > 0
+		return time.Now().Add(time.Duration(defaultHours) * time.Hour)
 	}
-	// 检查输出是否包含进程ID（数字）
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && line != "ProcessId" {
-			// 找到了进程ID
-			return true
-		}
-	}
-	return false
+	return exp
 }
 
-func extractExpireTime(jwtStr string, defaultHours int) time.Time {
-	// 默认过期时间 (兜底)
-	now := time.Now()
-	defaultExp := now.Add(time.Duration(defaultHours) * time.Hour)
-
+// parseJWTExpire 读取 JWT payload 中的 exp，payload 为 base64url 编码
+func parseJWTExpire(jwtStr string) (time.Time, error) {
 	parts := strings.Split(jwtStr, ".")
 	if len(parts) < 2 {
-		return defaultExp
+		return time.Time{}, fmt.Errorf("不是有效的 JWT")
 	}
 
-	payload, err := base64.RawStdEncoding.DecodeString(parts[1])
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
 	if err != nil {
-		return defaultExp
+		return time.Time{}, fmt.Errorf("JWT payload 解码失败: %w", err)
 	}
 
 	var data struct {
 		Exp int64 `json:"exp"`
 	}
-	if err := json.Unmarshal(payload, &data); err != nil || data.Exp == 0 {
-		return defaultExp
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return time.Time{}, fmt.Errorf("JWT payload 解析失败: %w", err)
 	}
-
-	return time.Unix(data.Exp, 0)
+	if data.Exp == 0 {
+		return time.Time{}, fmt.Errorf("JWT 缺少 exp")
+	}
+	return time.Unix(data.Exp, 0), nil
 }
 
 func truncateString(s string, n int) string {
@@ -0,0 +1,271 @@
+package browser
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/procdetect"
+)
+
+// fakeJWT 生成指定失效时间的 JWT，sub 使 payload 含有 base64url 特有的字符
+func fakeJWT(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d,"sub":"~~~~~~"}`, exp.Unix())))
+	return "eyJhbGciOiJIUzI1NiJ9." + payload + ".sig"
+}
+
+type fakeStrategy struct {
+	name  string
+	token *config.TokenData
+	err   error
+	calls int
+}
+
+func (f *fakeStrategy) Name() string { return f.name }
+func (f *fakeStrategy) Login(context.Context, config.AccountConfig) (*config.TokenData, error) {
+	f.calls++
+	return f.token, f.err
+}
+
+func newTestBrowser(strategies ...LoginStrategy) *Browser {
+	b := &Browser{strategies: map[string]LoginStrategy{}, timelines: map[string]Timeline{}}
+	for _, s := range strategies {
+		b.Register(s)
+	}
+	return b
+}
+
+func useTempConfig(t *testing.T) *config.Config {
+	cfg := config.GetConfig()
+	oldDir, oldStrategies, oldAccounts := cfg.DataDir, cfg.LoginStrategies, cfg.Accounts
+	cfg.DataDir = t.TempDir()
+	t.Cleanup(func() { cfg.DataDir, cfg.LoginStrategies, cfg.Accounts = oldDir, oldStrategies, oldAccounts })
+	return cfg
+}
+
+func TestRefreshAccount_Fallback(t *testing.T) {
+	cfg := useTempConfig(t)
+	cfg.Accounts = []config.AccountConfig{{Name: "site2", LoginStrategies: []string{"a", "missing", "b", "c"}}}
+
+	token, _ := buildToken(map[string]string{"wyzdzjxhdnh": fakeJWT(time.Now().Add(time.Hour))})
+	a := &fakeStrategy{name: "a", err: errors.New("宝盒未运行")}
+	b := &fakeStrategy{name: "b", token: token}
+	c := &fakeStrategy{name: "c", token: token}
+
+	br := newTestBrowser(a, b, c)
+
+	if err := br.RefreshAccount("site2"); err != nil {
+		t.Fatalf("后备登录方式成功时不应返回错误: %v", err)
+	}
+	if a.calls != 1 || b.calls != 1 || c.calls != 0 {
+		t.Errorf("应按顺序尝试并在成功后停止: a=%d b=%d c=%d", a.calls, b.calls, c.calls)
+	}
+	if !config.IsAccountTokenValid("site2") {
+		t.Error("成功后应保存 Token")
+	}
+	if tl, ok := br.LastTimeline("site2"); !ok || !tl.Success || tl.Strategy != "b" {
+		t.Errorf("应记录刷新过程: %+v", tl)
+	}
+}
+
+func TestRefreshAccount_AllFail(t *testing.T) {
+	cfg := useTempConfig(t)
+	cfg.LoginStrategies = []string{"a", "b"}
+
+	br := newTestBrowser(&fakeStrategy{name: "a", err: errors.New("宝盒未运行")}, &fakeStrategy{name: "b", err: errors.New("等待扫码超时")})
+
+	err := br.RefreshAccount(config.DefaultAccount)
+	if err == nil || !strings.Contains(err.Error(), "宝盒未运行") || !strings.Contains(err.Error(), "等待扫码超时") {
+		t.Errorf("全部失败时应汇总各登录方式的错误: %v", err)
+	}
+	if tl, _ := br.LastTimeline(config.DefaultAccount); tl.Success || tl.Error == "" || tl.Bundle == "" {
+		t.Errorf("失败时应记录错误并保存诊断包: %+v", tl)
+	}
+}
+
+func TestZBoxStrategy_NotRunning(t *testing.T) {
+	useTempConfig(t)
+	br := newTestBrowser()
+	br.procs = &procdetect.Fake{Procs: []procdetect.Process{{PID: 1, Name: "explorer.exe"}}}
+
+	_, err := (&ZBoxStrategy{b: br}).Login(context.Background(), config.AccountConfig{Name: config.DefaultAccount})
+	if err == nil || !strings.Contains(err.Error(), "宝盒未运行") {
+		t.Errorf("宝盒未运行时应直接返回错误而不启动浏览器: %v", err)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	rec := newRecorder("default")
+	rec.setPrefix("zbox: ")
+	rec.step("打开登录页", func() error { return nil })
+	rec.event("核心 Cookie 写入", "wyzdzjxhdnh")
+	rec.step("等待登录 Cookie", func() error { return errors.New("超时 (45s)") })
+
+	tl := rec.finish("", errors.New("超时 (45s)"))
+	if len(tl.Steps) != 3 || tl.Steps[0].Name != "zbox: 打开登录页" || tl.Steps[1].Status != "event" ||
+		tl.Steps[2].Status != "failed" || tl.Steps[2].Detail != "超时 (45s)" {
+		t.Errorf("步骤记录不正确: %+v", tl.Steps)
+	}
+}
+
+func TestDiagnosticsBundle(t *testing.T) {
+	useTempConfig(t)
+	rec := newRecorder("site2")
+	d := rec.diag
+	d.onEvent(&network.EventRequestWillBeSent{RequestID: "1", Request: &network.Request{
+		URL: "https://sso.zt-express.com/login?from=portal", Method: "GET",
+		Headers: network.Headers{"Cookie": "wyzdzjxhdnh=secret1; other=x"},
+	}})
+	d.onEvent(&network.EventResponseReceived{RequestID: "1", Response: &network.Response{
+		Status: 200, MimeType: "text/html", Headers: network.Headers{"Set-Cookie": "wyzdzjxhdnh=secret2; Path=/"},
+	}})
+	d.onEvent(&network.EventLoadingFinished{RequestID: "1", EncodedDataLength: 512})
+	d.onEvent(&network.EventRequestWillBeSent{RequestID: "2", Request: &network.Request{URL: "https://www.zt-express.com/a.js", Method: "GET"}})
+	d.onEvent(&runtime.EventConsoleAPICalled{Type: "error", Args: []*runtime.RemoteObject{{Description: "登录组件加载失败"}}})
+
+	tl := rec.finish("", errors.New("超时"))
+	name := newTestBrowser().saveDiagnostics(context.Background(), rec, tl)
+	path, err := DiagnosticsStore().Path("site2", name)
+	if err != nil {
+		t.Fatalf("诊断包应保存到账号目录: %v", err)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(data)
+	}
+	for _, want := range []string{"timeline.json", "network.har", "console.log", "cookies.json"} {
+		if _, ok := contents[want]; !ok {
+			t.Errorf("诊断包缺少 %s", want)
+		}
+	}
+	har := contents["network.har"]
+	if strings.Contains(har, "secret") || !strings.Contains(har, "wyzdzjxhdnh=***; Path=/") || !strings.Contains(har, "未完成") {
+		t.Errorf("HAR 内容不正确: %s", har)
+	}
+	if !strings.Contains(contents["console.log"], "[error] 登录组件加载失败") {
+		t.Errorf("控制台日志不正确: %s", contents["console.log"])
+	}
+}
+
+func TestCookieStrategy(t *testing.T) {
+	useTempConfig(t)
+	exp := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	file := filepath.Join(t.TempDir(), "cookie.txt")
+	os.WriteFile(file, []byte("Cookie: wyzdzjxhdnh="+fakeJWT(exp)+"; other = x \n"), 0600)
+	acc := config.AccountConfig{Name: "cookie-site", CookieFile: file}
+
+	token, err := CookieStrategy{}.Login(context.Background(), acc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.ExpiresAt.Equal(exp) || token.Cookies["other"] != "x" {
+		t.Errorf("Cookie 解析不正确: %+v", token)
+	}
+
+	// 文件未更新时不能算作刷新成功，否则会挡住后续登录方式
+	config.SetAccountToken(acc.Name, token)
+	if _, err := (CookieStrategy{}).Login(context.Background(), acc); err == nil {
+		t.Error("与当前 Token 相同的 Cookie 文件应返回错误")
+	}
+
+	os.WriteFile(file, []byte("wyzdzjxhdnh="+fakeJWT(time.Now().Add(-time.Hour))), 0600)
+	if _, err := (CookieStrategy{}).Login(context.Background(), config.AccountConfig{CookieFile: file}); err == nil {
+		t.Error("过期的 Cookie 应返回错误")
+	}
+	if _, err := (CookieStrategy{}).Login(context.Background(), config.AccountConfig{}); err == nil {
+		t.Error("未配置 cookieFile 应返回错误")
+	}
+}
+
+func TestParseCookieInput(t *testing.T) {
+	jwt := fakeJWT(time.Now().Add(time.Hour))
+	cases := []struct {
+		name, input, format string
+	}{
+		{"header", "Cookie: wyzdzjxhdnh=" + jwt + "; wyandyy=app", FormatHeader},
+		{"bash", "curl 'https://x.zt-express.com/a' \\\n  -H 'accept: */*' \\\n  -b 'wyzdzjxhdnh=" + jwt + "; wyandyy=app' \\\n  --data-raw '{}'", FormatCurl},
+		{"header-flag", `curl "https://x.zt-express.com/a" -H "Cookie: wyzdzjxhdnh=` + jwt + `; wyandyy=app"`, FormatCurl},
+		{"cmd", `curl ^"https://x.zt-express.com/a^" ^` + "\n" + `  -b ^"wyzdzjxhdnh=` + jwt + `; wyandyy=app^"`, FormatCurl},
+		{"cookies.txt", "# Netscape HTTP Cookie File\n.zt-express.com\tTRUE\t/\tTRUE\t0\twyzdzjxhdnh\t" + jwt +
+			"\n#HttpOnly_.zt-express.com\tTRUE\t/\tTRUE\t0\twyandyy\tapp\n.example.com\tTRUE\t/\tFALSE\t0\tother\tx\n", FormatCookiesTxt},
+	}
+	for _, c := range cases {
+		cookies, format, err := ParseCookieInput(c.input)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if format != c.format || cookies["wyzdzjxhdnh"] != jwt || cookies["wyandyy"] != "app" || cookies["other"] != "" {
+			t.Errorf("%s: 解析结果不正确 format=%s cookies=%v", c.name, format, cookies)
+		}
+	}
+
+	if _, _, err := TokenFromInput("curl 'https://x.zt-express.com/a' -H 'accept: */*'"); err == nil {
+		t.Error("没有 Cookie 的 cURL 应返回错误")
+	}
+	if _, _, err := TokenFromInput("wyzdzjxhdnh=" + fakeJWT(time.Now().Add(-time.Hour))); err == nil {
+		t.Error("过期的 Token 应返回错误")
+	}
+}
+
+func TestParseJWTExpire(t *testing.T) {
+	exp := time.Now().Add(3 * time.Hour).Truncate(time.Second)
+	jwt := fakeJWT(exp)
+	if !strings.ContainsAny(strings.Split(jwt, ".")[1], "-_") {
+		t.Fatal("测试数据应包含 base64url 字符")
+	}
+	if got, err := parseJWTExpire(jwt); err != nil || !got.Equal(exp) {
+		t.Errorf("base64url payload 解析错误: %v %v", got, err)
+	}
+
+	token, _, err := TokenFromInput("wyzdzjxhdnh=" + jwt)
+	if err != nil || !token.ExpiresAt.Equal(exp) {
+		t.Errorf("导入应使用 JWT 中的失效时间: %v", err)
+	}
+	if _, _, err := TokenFromInput("wyzdzjxhdnh=not-a-jwt"); err == nil {
+		t.Error("无法解析的 JWT 导入时应返回错误")
+	}
+}
+
+func TestSetQRHandler_Concurrent(t *testing.T) {
+	b := newTestBrowser()
+	q := &QRStrategy{b: b}
+	b.Register(q)
+
+	// go test -race 下检查回调的读写均在锁内
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			b.SetQRHandler(func(string, []byte) {})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		q.codeHandler()
+	}
+	<-done
+	if q.codeHandler() == nil {
+		t.Error("应读取到设置的回调")
+	}
+}
@@ -0,0 +1,377 @@
+package browser
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/har"
+	"github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+	"zto-api-proxy/snapshot"
+)
+
+// DiagnosticsStore 登录诊断包目录 DataDir/debug/<账号>/
+func DiagnosticsStore() *snapshot.Store {
+	return snapshot.NewStore(filepath.Join(config.GetConfig().DataDir, "debug"))
+}
+
+type diagFile struct {
+	name string
+	data []byte
+}
+
+// harRequest 尚未结束的网络请求
+type harRequest struct {
+	entry *har.Entry
+	start time.Time
+}
+
+// diagnostics 收集一次刷新中各登录方式的截图、页面、控制台日志与网络请求
+type diagnostics struct {
+	mu        sync.Mutex
+	chromeCtx context.Context // 当前登录方式的浏览器，未启动时为 nil
+	files     []diagFile
+	shots     int
+	console   []string
+	entries   []*har.Entry
+	pending   map[network.RequestID]*harRequest
+	cookies   map[string]string // Cookie 名称 -> 域名，不记录值
+}
+
+func newDiagnostics() *diagnostics {
+	return &diagnostics{pending: make(map[network.RequestID]*harRequest), cookies: make(map[string]string)}
+}
+
+// attach 开始记录浏览器事件，返回的函数在关闭浏览器前调用，保存最终页面与 Cookie 名称
+func (r *recorder) attach(chromeCtx context.Context) func() {
+	d := r.diag
+	d.mu.Lock()
+	d.chromeCtx = chromeCtx
+	d.mu.Unlock()
+	chromedp.ListenTarget(chromeCtx, d.onEvent)
+
+	return func() {
+		prefix := r.currentPrefix()
+		d.capturePage(chromeCtx, strings.TrimSuffix(prefix, ": "))
+		d.screenshot(prefix + "最终页面")
+		d.mu.Lock()
+		d.chromeCtx = nil
+		d.mu.Unlock()
+	}
+}
+
+// screenshot 浏览器已启动时截取当前页面
+func (d *diagnostics) screenshot(name string) {
+	d.mu.Lock()
+	ctx := d.chromeCtx
+	d.mu.Unlock()
+	if ctx == nil {
+		return
+	}
+
+	var img []byte
+	if err := runWithTimeout(ctx, 3*time.Second, chromedp.CaptureScreenshot(&img)); err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.shots++
+	d.files = append(d.files, diagFile{fmt.Sprintf("screenshots/%02d-%s.png", d.shots, fileSafe(name)), img})
+}
+
+// capturePage 保存页面 HTML 与 Cookie 名称
+func (d *diagnostics) capturePage(ctx context.Context, strategy string) {
+	var html string
+	var cookies []*network.Cookie
+	runWithTimeout(ctx, 5*time.Second, chromedp.OuterHTML("html", &html, chromedp.ByQuery))
+	runWithTimeout(ctx, 5*time.Second, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().Do(ctx)
+		return err
+	}))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if html != "" {
+		d.files = append(d.files, diagFile{"page-" + fileSafe(strategy) + ".html", []byte(html)})
+	}
+	for _, c := range cookies {
+		d.cookies[c.Name] = c.Domain
+	}
+}
+
+func fileSafe(name string) string {
+	name = strings.NewReplacer(": ", "-", ":", "-", "/", "-", `\`, "-", " ", "_").Replace(name)
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// onEvent 在 CDP 事件循环中调用，只做记录不执行任何浏览器操作
+func (d *diagnostics) onEvent(ev interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch e := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		if p, ok := d.pending[e.RequestID]; ok && e.RedirectResponse != nil {
+			p.entry.Response = harResponse(e.RedirectResponse)
+			d.finishRequest(e.RequestID, monoTime(e.Timestamp))
+		}
+		d.pending[e.RequestID] = &harRequest{entry: harEntry(e), start: monoTime(e.Timestamp)}
+	case *network.EventResponseReceived:
+		if p, ok := d.pending[e.RequestID]; ok {
+			p.entry.Response = harResponse(e.Response)
+		}
+	case *network.EventLoadingFinished:
+		if p, ok := d.pending[e.RequestID]; ok {
+			p.entry.Response.BodySize = int64(e.EncodedDataLength)
+			d.finishRequest(e.RequestID, monoTime(e.Timestamp))
+		}
+	case *network.EventLoadingFailed:
+		if p, ok := d.pending[e.RequestID]; ok {
+			p.entry.Comment = e.ErrorText
+			d.finishRequest(e.RequestID, monoTime(e.Timestamp))
+		}
+	case *runtime.EventConsoleAPICalled:
+		args := make([]string, 0, len(e.Args))
+		for _, a := range e.Args {
+			args = append(args, remoteValue(a))
+		}
+		d.logConsole(string(e.Type), strings.Join(args, " "))
+	case *runtime.EventExceptionThrown:
+		text := e.ExceptionDetails.Text
+		if ex := e.ExceptionDetails.Exception; ex != nil && ex.Description != "" {
+			text += " " + ex.Description
+		}
+		d.logConsole("exception", text)
+	case *log.EventEntryAdded:
+		text := e.Entry.Text
+		if e.Entry.URL != "" {
+			text += " (" + e.Entry.URL + ")"
+		}
+		d.logConsole(string(e.Entry.Level), text)
+	}
+}
+
+func (d *diagnostics) logConsole(level, text string) {
+	d.console = append(d.console, fmt.Sprintf("%s [%s] %s", time.Now().Format("15:04:05.000"), level, text))
+}
+
+func (d *diagnostics) finishRequest(id network.RequestID, end time.Time) {
+	p := d.pending[id]
+	delete(d.pending, id)
+	if !p.start.IsZero() && !end.IsZero() {
+		p.entry.Time = float64(end.Sub(p.start).Microseconds()) / 1000
+		p.entry.Timings.Wait = p.entry.Time
+	}
+	d.entries = append(d.entries, p.entry)
+}
+
+func monoTime(t *cdp.MonotonicTime) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.Time()
+}
+
+func harEntry(e *network.EventRequestWillBeSent) *har.Entry {
+	started := time.Now()
+	if e.WallTime != nil {
+		started = e.WallTime.Time()
+	}
+	query := []*har.NameValuePair{}
+	if u, err := url.Parse(e.Request.URL); err == nil {
+		for k, vs := range u.Query() {
+			for _, v := range vs {
+				query = append(query, &har.NameValuePair{Name: k, Value: v})
+			}
+		}
+	}
+	bodySize := int64(0)
+	for _, p := range e.Request.PostDataEntries {
+		bodySize += int64(len(p.Bytes)) * 3 / 4 // base64 编码，不保存请求体
+	}
+	return &har.Entry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Request: &har.Request{
+			Method:      e.Request.Method,
+			URL:         e.Request.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []*har.Cookie{},
+			Headers:     harHeaders(e.Request.Headers),
+			QueryString: query,
+			HeadersSize: -1,
+			BodySize:    bodySize,
+		},
+		Response: &har.Response{Cookies: []*har.Cookie{}, Headers: []*har.NameValuePair{}, Content: &har.Content{}, HeadersSize: -1, BodySize: -1},
+		Cache:    &har.Cache{},
+		Timings:  &har.Timings{},
+	}
+}
+
+func harResponse(r *network.Response) *har.Response {
+	location := ""
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, "location") {
+			location = fmt.Sprint(v)
+		}
+	}
+	return &har.Response{
+		Status:      r.Status,
+		StatusText:  r.StatusText,
+		HTTPVersion: r.Protocol,
+		Cookies:     []*har.Cookie{},
+		Headers:     harHeaders(r.Headers),
+		Content:     &har.Content{MimeType: r.MimeType},
+		RedirectURL: location,
+		HeadersSize: -1,
+		BodySize:    int64(r.EncodedDataLength),
+	}
+}
+
+// harHeaders 转换请求头，Cookie 与认证信息只保留名称
+func harHeaders(h network.Headers) []*har.NameValuePair {
+	pairs := make([]*har.NameValuePair, 0, len(h))
+	for k, v := range h {
+		value := fmt.Sprint(v)
+		switch strings.ToLower(k) {
+		case "cookie":
+			value = redactCookies(value, false)
+		case "set-cookie":
+			value = redactCookies(value, true)
+		case "authorization":
+			value = "***"
+		}
+		pairs = append(pairs, &har.NameValuePair{Name: k, Value: value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// redactCookies 隐藏 Cookie 值: "a=1; b=2" -> "a=***; b=***"。
+// Set-Cookie 每行只有第一段是 Cookie，其余属性保留
+func redactCookies(value string, setCookie bool) string {
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		parts := strings.Split(line, ";")
+		for j, part := range parts {
+			if setCookie && j > 0 {
+				break
+			}
+			if name, _, ok := strings.Cut(part, "="); ok {
+				parts[j] = name + "=***"
+			}
+		}
+		lines[i] = strings.Join(parts, ";")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func remoteValue(a *runtime.RemoteObject) string {
+	if len(a.Value) > 0 {
+		var s string
+		if json.Unmarshal(a.Value, &s) == nil {
+			return s
+		}
+		return string(a.Value)
+	}
+	if a.UnserializableValue != "" {
+		return string(a.UnserializableValue)
+	}
+	return a.Description
+}
+
+// bundle 打包诊断信息为 zip
+func (d *diagnostics) bundle(tl Timeline) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entries := append([]*har.Entry(nil), d.entries...)
+	for _, p := range d.pending {
+		p.entry.Comment = "未完成"
+		entries = append(entries, p.entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].StartedDateTime < entries[j].StartedDateTime })
+	harData, err := json.MarshalIndent(har.HAR{Log: &har.Log{
+		Version: "1.2",
+		Creator: &har.Creator{Name: "zto-api-proxy", Version: "1.0"},
+		Entries: entries,
+	}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	type cookieSeen struct {
+		Name   string `json:"name"`
+		Domain string `json:"domain"`
+	}
+	cookies := make([]cookieSeen, 0, len(d.cookies))
+	for name, domain := range d.cookies {
+		cookies = append(cookies, cookieSeen{name, domain})
+	}
+	sort.Slice(cookies, func(i, j int) bool { return cookies[i].Name < cookies[j].Name })
+	cookieData, _ := json.MarshalIndent(cookies, "", "  ")
+	timeline, _ := json.MarshalIndent(tl, "", "  ")
+
+	files := append([]diagFile{
+		{"timeline.json", timeline},
+		{"network.har", harData},
+		{"console.log", []byte(strings.Join(d.console, "\n"))},
+		{"cookies.json", cookieData},
+	}, d.files...)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: tl.Start})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// saveDiagnostics 保存诊断包并按保留策略清理，返回文件名
+func (b *Browser) saveDiagnostics(ctx context.Context, rec *recorder, tl Timeline) string {
+	log := logger.With(logger.CategoryToken, "account", tl.Account)
+	data, err := rec.diag.bundle(tl)
+	if err != nil {
+		log.WarnContext(ctx, "生成登录诊断包失败", "error", err)
+		return ""
+	}
+	store := DiagnosticsStore()
+	file, err := store.Save(tl.Account, "zip", data, tl.Start)
+	if err != nil {
+		log.WarnContext(ctx, "保存登录诊断包失败", "error", err)
+		return ""
+	}
+	cfg := config.GetConfig()
+	store.Prune(tl.Account, cfg.DiagnosticsRetentionDays, cfg.DiagnosticsMaxFiles)
+	if !tl.Success {
+		log.WarnContext(ctx, "Token 刷新失败，诊断包已保存", "file", file.Name)
+	}
+	return file.Name
+}
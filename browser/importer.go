@@ -0,0 +1,185 @@
+package browser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"zto-api-proxy/config"
+)
+
+// 可导入的 Cookie 格式
+const (
+	FormatHeader     = "header"      // Cookie 请求头 / 字符串
+	FormatCurl       = "curl"        // 浏览器「Copy as cURL」命令
+	FormatCookiesTxt = "cookies.txt" // Netscape cookies.txt
+)
+
+// ParseCookieInput 自动识别格式并提取中通域名的 Cookie
+func ParseCookieInput(input string) (map[string]string, string, error) {
+	input = strings.TrimSpace(strings.TrimPrefix(input, "\ufeff"))
+	if input == "" {
+		return nil, "", fmt.Errorf("内容为空")
+	}
+
+	var cookies map[string]string
+	format := FormatHeader
+	switch {
+	case strings.HasPrefix(input, "curl ") || strings.HasPrefix(input, "curl.exe "):
+		format = FormatCurl
+		cookies = parseCurlCookies(input)
+	case isCookiesTxt(input):
+		format = FormatCookiesTxt
+		cookies = parseCookiesTxt(input)
+	default:
+		cookies = parseCookieHeader(input)
+	}
+	if len(cookies) == 0 {
+		return nil, format, fmt.Errorf("未解析到任何 Cookie (%s)", format)
+	}
+	return cookies, format, nil
+}
+
+// TokenFromInput 解析 Cookie 并生成 Token，核心 Cookie 缺失或已过期时返回错误
+func TokenFromInput(input string) (*config.TokenData, string, error) {
+	cookies, format, err := ParseCookieInput(input)
+	if err != nil {
+		return nil, format, err
+	}
+	// 导入时失效时间必须来自 JWT 本身，不能按默认时长估算
+	for _, name := range []string{"wyandyy", "wyzdzjxhdnh"} {
+		if value, ok := cookies[name]; ok {
+			if _, err := parseJWTExpire(value); err != nil {
+				return nil, format, fmt.Errorf("Cookie %s: %w", name, err)
+			}
+		}
+	}
+	token, err := buildToken(cookies)
+	if err != nil {
+		return nil, format, err
+	}
+	if !token.ExpiresAt.After(time.Now()) {
+		return nil, format, fmt.Errorf("Token 已过期 (%s)", token.ExpiresAt.Format("2006-01-02 15:04:05"))
+	}
+	return token, format, nil
+}
+
+// parseCurlCookies 提取 cURL 命令中 -b / --cookie 与 -H "cookie: ..." 的 Cookie
+func parseCurlCookies(cmd string) map[string]string {
+	// Windows cmd 格式以 ^ 转义
+	if strings.Contains(cmd, `^"`) {
+		cmd = strings.NewReplacer("^\r\n", " ", "^\n", " ", `^"`, `"`, "^^", "^", "^", "").Replace(cmd)
+	}
+
+	cookies := make(map[string]string)
+	args := splitShellWords(cmd)
+	for i := 0; i < len(args)-1; i++ {
+		var value string
+		switch args[i] {
+		case "-b", "--cookie":
+			value = args[i+1]
+			if !strings.Contains(value, "=") {
+				continue // -b 也可以是 Cookie 文件名
+			}
+		case "-H", "--header":
+			name, v, ok := strings.Cut(args[i+1], ":")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "cookie") {
+				continue
+			}
+			value = v
+		default:
+			continue
+		}
+		for k, v := range parseCookieHeader(value) {
+			cookies[k] = v
+		}
+		i++
+	}
+	return cookies
+}
+
+// splitShellWords 按 shell 规则拆分参数，支持单引号、双引号、$'...' 与行尾续行
+func splitShellWords(s string) []string {
+	var args []string
+	var cur strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+			if s[i] != '\n' {
+				cur.WriteByte(s[i])
+				inWord = true
+			}
+		case c == '\'' || (c == '$' && i+1 < len(s) && s[i+1] == '\''):
+			ansi := c == '$'
+			if ansi {
+				i++
+			}
+			for i++; i < len(s) && s[i] != '\''; i++ {
+				if ansi && s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				cur.WriteByte(s[i])
+			}
+			inWord = true
+		case c == '"':
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.ContainsRune("\"\\$`", rune(s[i+1])) {
+					i++
+				}
+				cur.WriteByte(s[i])
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// isCookiesTxt 判断是否为 Netscape cookies.txt (7 列，制表符分隔)
+func isCookiesTxt(s string) bool {
+	if strings.HasPrefix(s, "# Netscape HTTP Cookie File") || strings.HasPrefix(s, "# HTTP Cookie File") {
+		return true
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "#HttpOnly_") {
+			continue
+		}
+		return len(strings.Split(line, "\t")) == 7
+	}
+	return false
+}
+
+// parseCookiesTxt 解析 cookies.txt: domain, includeSubdomains, path, secure, expiry, name, value
+func parseCookiesTxt(s string) map[string]string {
+	cookies := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 || !strings.Contains(fields[0], "zt-express.com") {
+			continue
+		}
+		cookies[fields[5]] = strings.TrimSpace(fields[6])
+	}
+	return cookies
+}
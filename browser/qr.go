@@ -0,0 +1,109 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+// QRStrategy 切换到扫码登录，把二维码图片交给 OnCode 展示，等待手机扫码完成
+type QRStrategy struct {
+	b       *Browser
+	OnCode  func(account string, png []byte) // 二维码出现或刷新时回调，为空时不可用；运行中修改需通过 SetQRHandler
+	Timeout time.Duration                    // 等待扫码的时长，默认 3 分钟
+}
+
+func (q *QRStrategy) Name() string { return StrategyQR }
+
+// codeHandler 读取二维码回调，与 SetQRHandler 共用 b.mu
+func (q *QRStrategy) codeHandler() func(account string, png []byte) {
+	q.b.mu.RLock()
+	defer q.b.mu.RUnlock()
+	return q.OnCode
+}
+
+// markQRScript 切换到扫码登录页并标记二维码元素，返回是否找到
+const markQRScript = `
+	(function() {
+		const tab = Array.from(document.querySelectorAll('a, div, span, li'))
+			.find(el => /扫码登录|手机扫码/.test(el.textContent.trim()) && el.children.length === 0);
+		if (tab) tab.click();
+		const qr = Array.from(document.querySelectorAll('canvas, img')).find(el => {
+			const r = el.getBoundingClientRect();
+			return r.width >= 100 && Math.abs(r.width - r.height) < 10;
+		});
+		if (!qr) return false;
+		qr.setAttribute('data-zto-qr', '1');
+		return true;
+	})()
+`
+
+func (q *QRStrategy) Login(ctx context.Context, acc config.AccountConfig) (*config.TokenData, error) {
+	onCode := q.codeHandler()
+	if onCode == nil {
+		return nil, fmt.Errorf("未配置二维码展示方式，无法扫码登录")
+	}
+	timeout := q.Timeout
+	if timeout <= 0 {
+		timeout = 3 * time.Minute
+	}
+
+	chromeCtx, cancel := q.b.createContext(ctx, acc.ChromeDataDir)
+	defer cancel()
+
+	rec := recorderFrom(ctx)
+	defer rec.attach(chromeCtx)()
+	if err := startBrowser(chromeCtx, rec); err != nil {
+		return nil, err
+	}
+	err := rec.step("打开登录页", func() error {
+		return runWithTimeout(chromeCtx, stepTimeout(config.GetConfig().LoginTimeouts.PageLoad, 30), chromedp.Navigate(loginURL()))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var cookies []*network.Cookie
+	err = rec.step("等待扫码", func() error {
+		return runWithTimeout(chromeCtx, timeout, chromedp.ActionFunc(func(ctx context.Context) error {
+			var last []byte
+			for {
+				var err error
+				cookies, err = network.GetCookies().Do(ctx)
+				if err != nil {
+					return err
+				}
+				if _, ok := cookieMap(cookies)["wyzdzjxhdnh"]; ok {
+					logger.With(logger.CategoryToken, "account", acc.Name).InfoContext(ctx, "扫码登录完成")
+					return nil
+				}
+
+				// 二维码会定时过期刷新，变化时重新推送
+				var found bool
+				if err := chromedp.Evaluate(markQRScript, &found).Do(ctx); err == nil && found {
+					var img []byte
+					if err := chromedp.Screenshot(`[data-zto-qr]`, &img, chromedp.ByQuery).Do(ctx); err == nil && !bytes.Equal(img, last) {
+						last = img
+						rec.event("二维码已更新", "")
+						logger.With(logger.CategoryToken, "account", acc.Name).InfoContext(ctx, "已获取登录二维码，等待手机扫码")
+						onCode(acc.Name, img)
+					}
+				}
+				if err := chromedp.Sleep(2 * time.Second).Do(ctx); err != nil {
+					return err
+				}
+			}
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildToken(cookieMap(cookies))
+}
@@ -0,0 +1,136 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+// 登录方式名称
+const (
+	StrategyZBox    = "zbox"    // 宝盒一键登录
+	StrategyProfile = "profile" // 复用 Chrome 用户目录中已有的登录 Cookie
+	StrategyCookie  = "cookie"  // 从文件导入 Cookie
+	StrategyQR      = "qr"      // 手机扫码登录
+)
+
+// LoginStrategy 一种获取登录 Cookie 的方式
+type LoginStrategy interface {
+	Name() string
+	Login(ctx context.Context, acc config.AccountConfig) (*config.TokenData, error)
+}
+
+// strategyNames 返回账号依次尝试的登录方式
+func strategyNames(acc config.AccountConfig) []string {
+	if len(acc.LoginStrategies) > 0 {
+		return acc.LoginStrategies
+	}
+	if names := config.GetConfig().LoginStrategies; len(names) > 0 {
+		return names
+	}
+	return []string{StrategyZBox}
+}
+
+// loginURL 登录入口，未配置时使用官网
+func loginURL() string {
+	if u := config.GetConfig().LoginURL; u != "" {
+		return u
+	}
+	return "https://www.zt-express.com"
+}
+
+// CookieStrategy 读取账号 cookieFile 中的 Cookie，支持 Cookie 字符串、cURL 命令与 cookies.txt
+type CookieStrategy struct{}
+
+func (CookieStrategy) Name() string { return StrategyCookie }
+
+func (CookieStrategy) Login(ctx context.Context, acc config.AccountConfig) (*config.TokenData, error) {
+	if acc.CookieFile == "" {
+		return nil, fmt.Errorf("账号未配置 cookieFile")
+	}
+	data, err := os.ReadFile(acc.CookieFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Cookie 文件失败: %w", err)
+	}
+	token, _, err := TokenFromInput(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("Cookie 文件无效: %w", err)
+	}
+	// 与当前 Token 相同说明文件未更新，导入后仍会失效，交给后续登录方式
+	if cur := config.GetAccountToken(acc.Name); cur != nil && cur.Cookies["wyzdzjxhdnh"] == token.Cookies["wyzdzjxhdnh"] {
+		return nil, fmt.Errorf("Cookie 文件未更新，与当前 Token 相同")
+	}
+	return token, nil
+}
+
+// parseCookieHeader 解析 "a=1; b=2" 形式的 Cookie 字符串
+func parseCookieHeader(s string) map[string]string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "Cookie:")
+	cookies := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && name != "" {
+			cookies[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return cookies
+}
+
+// ProfileStrategy 打开已登录过的 Chrome 用户目录直接读取 Cookie，不做任何点击
+type ProfileStrategy struct {
+	b *Browser
+}
+
+func (p *ProfileStrategy) Name() string { return StrategyProfile }
+
+func (p *ProfileStrategy) Login(ctx context.Context, acc config.AccountConfig) (*config.TokenData, error) {
+	dir := acc.ChromeProfileDir
+	if dir == "" {
+		dir = acc.ChromeDataDir
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("Chrome 用户目录不存在: %s", dir)
+	}
+
+	chromeCtx, cancel := p.b.createContext(ctx, dir)
+	defer cancel()
+
+	rec := recorderFrom(ctx)
+	defer rec.attach(chromeCtx)()
+	if err := startBrowser(chromeCtx, rec); err != nil {
+		return nil, err
+	}
+	var cookies []*network.Cookie
+	err := rec.step("读取用户目录 Cookie", func() error {
+		return runWithTimeout(chromeCtx, stepTimeout(config.GetConfig().LoginTimeouts.PageLoad, 30),
+			chromedp.Navigate(loginURL()),
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				var err error
+				cookies, err = network.GetCookies().Do(ctx)
+				return err
+			}),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := buildToken(cookieMap(cookies))
+	if err != nil {
+		return nil, fmt.Errorf("Chrome 用户目录中没有有效的登录状态: %w", err)
+	}
+	if !token.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("Chrome 用户目录中的 Token 已过期")
+	}
+	logger.With(logger.CategoryToken, "account", acc.Name).InfoContext(ctx, "从 Chrome 用户目录读取到登录 Cookie", "dir", dir)
+	return token, nil
+}
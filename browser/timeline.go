@@ -0,0 +1,111 @@
+package browser
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"zto-api-proxy/logger"
+)
+
+// Step 登录流程中的一个步骤或事件
+type Step struct {
+	Name     string    `json:"name"`
+	Start    time.Time `json:"start"`
+	Duration int64     `json:"duration"` // 毫秒，事件为 0
+	Status   string    `json:"status"`   // ok | failed | event
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// Timeline 一次刷新的步骤记录
+type Timeline struct {
+	Account  string    `json:"account"`
+	Strategy string    `json:"strategy,omitempty"` // 最终成功的登录方式
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end,omitzero"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	Bundle   string    `json:"bundle,omitempty"` // 诊断包文件名
+	Steps    []Step    `json:"steps"`
+}
+
+// recorder 并发安全地记录步骤，CDP 事件回调与主流程可能同时写入
+type recorder struct {
+	mu     sync.Mutex
+	ctx    context.Context // 只用于在步骤日志中带上请求 ID
+	prefix string
+	tl     Timeline
+	diag   *diagnostics
+}
+
+func newRecorder(account string) *recorder {
+	return &recorder{ctx: context.Background(), tl: Timeline{Account: account, Start: time.Now(), Steps: []Step{}}, diag: newDiagnostics()}
+}
+
+// step 执行并记录一个步骤，浏览器已启动时步骤结束后截图
+func (r *recorder) step(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	st := Step{Name: r.currentPrefix() + name, Start: start, Duration: time.Since(start).Milliseconds(), Status: "ok"}
+	if err != nil {
+		st.Status = "failed"
+		st.Detail = err.Error()
+	}
+	logger.With(logger.CategoryToken, "account", r.tl.Account).DebugContext(r.ctx, "登录步骤",
+		"step", st.Name, "status", st.Status, "duration_ms", st.Duration)
+
+	r.mu.Lock()
+	r.tl.Steps = append(r.tl.Steps, st)
+	r.mu.Unlock()
+	r.diag.screenshot(st.Name)
+	return err
+}
+
+// event 记录一个瞬时事件
+func (r *recorder) event(name, detail string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tl.Steps = append(r.tl.Steps, Step{Name: r.prefix + name, Start: time.Now(), Status: "event", Detail: detail})
+}
+
+func (r *recorder) currentPrefix() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.prefix
+}
+
+func (r *recorder) setPrefix(prefix string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prefix = prefix
+}
+
+// finish 结束记录并返回副本
+func (r *recorder) finish(strategy string, err error) Timeline {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tl.End = time.Now()
+	r.tl.Strategy = strategy
+	r.tl.Success = err == nil
+	if err != nil {
+		r.tl.Error = err.Error()
+	}
+	tl := r.tl
+	tl.Steps = append([]Step(nil), r.tl.Steps...)
+	return tl
+}
+
+type recorderKey struct{}
+
+func withRecorder(ctx context.Context, r *recorder) context.Context {
+	r.ctx = ctx
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// recorderFrom 取出当前刷新的记录器，策略被单独调用时返回一个不保存的记录器
+func recorderFrom(ctx context.Context) *recorder {
+	if r, ok := ctx.Value(recorderKey{}).(*recorder); ok {
+		return r
+	}
+	return newRecorder("")
+}
@@ -0,0 +1,188 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+	"zto-api-proxy/procdetect"
+)
+
+// ZBoxStrategy 宝盒运行时在登录页点击「一键登录」
+type ZBoxStrategy struct {
+	b *Browser
+}
+
+func (z *ZBoxStrategy) Name() string { return StrategyZBox }
+
+func (z *ZBoxStrategy) Login(ctx context.Context, acc config.AccountConfig) (*config.TokenData, error) {
+	rec := recorderFrom(ctx)
+	if err := rec.step("检测宝盒", func() error {
+		procs, err := procdetect.Find(z.b.procs, config.GetConfig().ZBoxProcesses)
+		if err != nil {
+			return fmt.Errorf("检测宝盒进程失败: %w", err)
+		}
+		if len(procs) == 0 {
+			return fmt.Errorf("宝盒未运行，无法自动登录")
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	chromeCtx, cancel := z.b.createContext(ctx, acc.ChromeDataDir)
+	defer cancel()
+	defer rec.attach(chromeCtx)()
+
+	timeouts := config.GetConfig().LoginTimeouts
+	cookieSet := make(chan struct{}, 1)
+	listenLogin(chromeCtx, rec, cookieSet)
+
+	var cookies []*network.Cookie
+	err := startBrowser(chromeCtx, rec)
+	if err == nil {
+		err = rec.step("打开登录页", func() error {
+			return runWithTimeout(chromeCtx, stepTimeout(timeouts.PageLoad, 30), chromedp.Navigate(loginURL()))
+		})
+	}
+	if err == nil {
+		err = rec.step("等待一键登录按钮", func() error {
+			return runWithTimeout(chromeCtx, stepTimeout(timeouts.Button, 20), clickLogin())
+		})
+	}
+	if err == nil {
+		err = rec.step("等待登录 Cookie", func() error {
+			var werr error
+			cookies, werr = waitLoginCookie(chromeCtx, stepTimeout(timeouts.Cookie, 45), cookieSet)
+			return werr
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buildToken(cookieMap(cookies))
+}
+
+func stepTimeout(seconds, def int) time.Duration {
+	if seconds <= 0 {
+		seconds = def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// startBrowser 启动浏览器，浏览器生命周期绑定在 chromeCtx 上，之后的步骤才能使用带超时的子 context
+func startBrowser(chromeCtx context.Context, rec *recorder) error {
+	return rec.step("启动浏览器", func() error { return chromedp.Run(chromeCtx) })
+}
+
+func runWithTimeout(ctx context.Context, timeout time.Duration, actions ...chromedp.Action) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := chromedp.Run(ctx, actions...)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("超时 (%v)", timeout)
+	}
+	return err
+}
+
+// listenLogin 监听认证接口响应与 Set-Cookie，核心 Cookie 写入时通知 cookieSet
+func listenLogin(ctx context.Context, rec *recorder, cookieSet chan<- struct{}) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch e := ev.(type) {
+		case *network.EventResponseReceived:
+			if isAuthURL(e.Response.URL) {
+				rec.event("认证接口响应", fmt.Sprintf("%d %s", e.Response.Status, truncateString(e.Response.URL, 120)))
+			}
+		case *network.EventResponseReceivedExtraInfo:
+			for k, v := range e.Headers {
+				if !strings.EqualFold(k, "set-cookie") {
+					continue
+				}
+				if str, ok := v.(string); ok && strings.Contains(str, "wyzdzjxhdnh=") {
+					rec.event("核心 Cookie 写入", "wyzdzjxhdnh")
+					select {
+					case cookieSet <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+	})
+}
+
+func isAuthURL(u string) bool {
+	u = strings.ToLower(u)
+	for _, k := range []string{"login", "auth", "sso", "passport", "token"} {
+		if strings.Contains(u, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitLoginCookie 等待核心 Cookie 出现。Set-Cookie 事件触发立即检查，
+// 另每 3 秒兜底检查一次（Cookie 也可能由页面脚本写入）
+func waitLoginCookie(ctx context.Context, timeout time.Duration, cookieSet <-chan struct{}) ([]*network.Cookie, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+
+	var cookies []*network.Cookie
+	for {
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			cookies, err = network.GetCookies().Do(ctx)
+			return err
+		}))
+		if err == nil {
+			if _, ok := cookieMap(cookies)["wyzdzjxhdnh"]; ok {
+				logger.With(logger.CategoryToken).InfoContext(ctx, "获取到核心 Token Cookie")
+				return cookies, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("未能获取 wyzdzjxhdnh cookie (超时 %v，最终共 %d 个)", timeout, len(cookies))
+		case <-cookieSet:
+		case <-ticker.C:
+		}
+	}
+}
+
+// findLoginScript 返回可见的「一键登录」按钮中心坐标，未出现时返回 null
+const findLoginScript = `(function() {
+	const btn = Array.from(document.querySelectorAll('button, a, div, span')).find(el =>
+		el.textContent.trim() === '一键登录' &&
+		el.offsetWidth > 50 &&
+		el.offsetHeight > 20
+	);
+	if (!btn) return null;
+	const rect = btn.getBoundingClientRect();
+	return [rect.left + rect.width / 2, rect.top + rect.height / 2];
+})()`
+
+// clickLogin 等待「一键登录」按钮渲染完成后模拟鼠标点击
+func clickLogin() chromedp.ActionFunc {
+	return func(ctx context.Context) error {
+		var coords []float64
+		// 页面结构变化时检查，超时由调用方的 context 控制
+		if err := chromedp.Poll(findLoginScript, &coords,
+			chromedp.WithPollingMutation(), chromedp.WithPollingTimeout(0)).Do(ctx); err != nil {
+			return err
+		}
+		if len(coords) != 2 {
+			return fmt.Errorf("未找到一键登录按钮")
+		}
+		logger.With(logger.CategoryToken).InfoContext(ctx, "按钮定位成功，执行模拟点击", "x", coords[0], "y", coords[1])
+		return chromedp.MouseClickXY(coords[0], coords[1]).Do(ctx)
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"zto-api-proxy/logger"
+)
+
+// DefaultAccount 默认账号，沿用 token.json 与 chrome-data 目录
+const DefaultAccount = "default"
+
+// AccountConfig 账号配置
+type AccountConfig struct {
+	Name          string `json:"name"`
+	ChromeDataDir string `json:"chromeDataDir"` // 为空时使用 DataDir/chrome-data-<name>
+	SiteCode      string `json:"siteCode"`      // 便捷接口未指定网点时使用
+
+	// 登录方式
+	LoginStrategies  []string `json:"loginStrategies,omitempty"`  // 依次尝试的登录方式，为空时使用全局 loginStrategies
+	CookieFile       string   `json:"cookieFile,omitempty"`       // cookie 方式读取的 Cookie 文件
+	ChromeProfileDir string   `json:"chromeProfileDir,omitempty"` // profile 方式复用的 Chrome 用户目录，为空时使用 chromeDataDir
+}
+
+var accountTokens = make(map[string]*TokenData)
+
+// AccountNames 返回所有账号名称，默认账号总是排在第一位
+func AccountNames() []string {
+	cfg := GetConfig()
+	names := []string{DefaultAccount}
+	for _, acc := range cfg.Accounts {
+		if acc.Name == "" || acc.Name == DefaultAccount {
+			continue
+		}
+		names = append(names, acc.Name)
+	}
+	return names
+}
+
+// HasAccount 检查账号是否存在
+func HasAccount(name string) bool {
+	for _, n := range AccountNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAccount 获取账号配置，默认账号即使未配置也会返回
+func GetAccount(name string) (AccountConfig, bool) {
+	cfg := GetConfig()
+	for _, acc := range cfg.Accounts {
+		if acc.Name == name {
+			if acc.ChromeDataDir == "" {
+				acc.ChromeDataDir = defaultChromeDataDir(cfg.DataDir, name)
+			}
+			return acc, true
+		}
+	}
+	if name == DefaultAccount {
+		return AccountConfig{
+			Name:          DefaultAccount,
+			ChromeDataDir: defaultChromeDataDir(cfg.DataDir, name),
+		}, true
+	}
+	return AccountConfig{}, false
+}
+
+func defaultChromeDataDir(dataDir, name string) string {
+	if name == DefaultAccount {
+		return filepath.Join(dataDir, "chrome-data")
+	}
+	return filepath.Join(dataDir, "chrome-data-"+safeName(name))
+}
+
+func accountTokenPath(dataDir, name string) string {
+	if name == DefaultAccount {
+		return filepath.Join(dataDir, "token.json")
+	}
+	return filepath.Join(dataDir, "tokens", safeName(name)+".json")
+}
+
+// safeName 去除账号名中的路径字符，避免写到数据目录之外。
+// 替换过的名称追加原名的短哈希，避免 "a/b" 与 "a_b" 落到同一个文件
+func safeName(name string) string {
+	safe := strings.NewReplacer("/", "_", "\\", "_", "..", "_", ":", "_").Replace(name)
+	if safe == name {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	return safe + "-" + hex.EncodeToString(sum[:4])
+}
+
+// GetAccountToken 获取指定账号的 Token 数据
+func GetAccountToken(name string) *TokenData {
+	if name == "" || name == DefaultAccount {
+		return GetTokenData()
+	}
+
+	tokenLock.Lock()
+	defer tokenLock.Unlock()
+
+	if t, ok := accountTokens[name]; ok {
+		return t
+	}
+	t := readTokenFile(accountTokenPath(GetConfig().DataDir, name))
+	accountTokens[name] = t
+	return t
+}
+
+// SetAccountToken 设置并保存指定账号的 Token 数据
+func SetAccountToken(name string, data *TokenData) error {
+	if name == "" || name == DefaultAccount {
+		return SetTokenData(data)
+	}
+
+	tokenLock.Lock()
+	defer tokenLock.Unlock()
+
+	accountTokens[name] = data
+	return writeTokenFile(accountTokenPath(GetConfig().DataDir, name), data)
+}
+
+// IsAccountTokenValid 检查指定账号的 Token 是否有效
+func IsAccountTokenValid(name string) bool {
+	return tokenValid(GetAccountToken(name))
+}
+
+// GetAccountCookieString 获取指定账号的 Cookie 字符串
+func GetAccountCookieString(name string) string {
+	return cookieString(GetAccountToken(name))
+}
+
+func readTokenFile(path string) *TokenData {
+	t := &TokenData{}
+	if data, err := readSecret(path); err == nil {
+		json.Unmarshal(data, t)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		logger.Error("读取 Token 文件 %s 失败: %v", filepath.Base(path), err)
+	}
+	if t.Cookies == nil {
+		t.Cookies = make(map[string]string)
+	}
+	return t
+}
+
+func writeTokenFile(path string, t *TokenData) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	st, err := getStore()
+	if err != nil {
+		return err
+	}
+	return st.Write(path, data)
+}
+
+func readSecret(path string) ([]byte, error) {
+	st, err := getStore()
+	if err != nil {
+		return nil, err
+	}
+	return st.Read(path)
+}
+
+func tokenValid(token *TokenData) bool {
+	if token == nil || len(token.Cookies) == 0 {
+		return false
+	}
+
+	// 检查是否有必需的 cookies
+	if _, ok := token.Cookies["wyzdzjxhdnh"]; !ok {
+		return false
+	}
+
+	// 检查是否过期
+	if token.ExpiresAt.IsZero() {
+		return false
+	}
+
+	return time.Now().Before(token.ExpiresAt)
+}
+
+// CookieString 拼接为 Cookie 请求头
+func (t *TokenData) CookieString() string {
+	return cookieString(t)
+}
+
+func cookieString(token *TokenData) string {
+	if token == nil {
+		return ""
+	}
+
+	result := ""
+	for name, value := range token.Cookies {
+		if result != "" {
+			result += "; "
+		}
+		result += name + "=" + value
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package config
+
+// 告警规则类型
+const (
+	AlertTokenInvalid  = "token-invalid"  // Token 已失效
+	AlertTokenExpiring = "token-expiring" // Token 剩余有效期低于 threshold 分钟
+	AlertRefreshFailed = "refresh-failed" // 最近一次自动刷新失败
+	AlertErrorRate     = "error-rate"     // window 分钟内上游失败率超过 threshold (0-1)
+	AlertTodoTotal     = "todo-total"     // 待办数量超过 threshold
+	AlertTodoIncrease  = "todo-increase"  // 待办数量较 window 分钟前增加超过 threshold
+)
+
+// AlertRule 告警规则
+type AlertRule struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Account     string   `json:"account,omitempty"`     // 为空时检查所有账号
+	Threshold   float64  `json:"threshold,omitempty"`   // 阈值，含义见规则类型
+	Window      int      `json:"window,omitempty"`      // 统计窗口 (分钟)
+	MinRequests int      `json:"minRequests,omitempty"` // error-rate 窗口内最少请求数，避免样本过少误报
+	Key         string   `json:"key,omitempty"`         // todo-* 规则的待办事项，为空表示合计
+	Level       string   `json:"level,omitempty"`       // warning | critical，默认 warning
+	Cooldown    int      `json:"cooldown,omitempty"`    // 重复通知间隔 (分钟)，0 使用 alertCooldown
+	Webhooks    []string `json:"webhooks,omitempty"`    // 通知的 webhook 名称，为空表示全部
+	Disabled    bool     `json:"disabled,omitempty"`
+}
+
+// AlertWebhook 告警通知渠道
+type AlertWebhook struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`              // dingtalk | wecom | generic
+	URL     string            `json:"url"`               // 机器人 Webhook 地址
+	Secret  string            `json:"secret,omitempty"`  // 钉钉加签密钥
+	Headers map[string]string `json:"headers,omitempty"` // generic 附加请求头
+}
+
+// GetAlertSettings 返回告警规则与通知渠道的副本
+func GetAlertSettings() ([]AlertRule, []AlertWebhook, int) {
+	cfg := GetConfig()
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+
+	rules := append([]AlertRule(nil), cfg.AlertRules...)
+	hooks := append([]AlertWebhook(nil), cfg.AlertWebhooks...)
+	return rules, hooks, cfg.AlertCooldown
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// API Key 角色
+const (
+	RoleQuery = "query" // 便捷业务接口
+	RoleProxy = "proxy" // 任意 /proxy 透传
+	RoleAdmin = "admin" // /admin/* 与 /refresh，拥有全部权限
+)
+
+// APIKey 接口访问密钥
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Key       string    `json:"key,omitempty"`     // 手工配置的明文密钥
+	KeyHash   string    `json:"keyHash,omitempty"` // 通过管理接口生成的密钥只保存 SHA-256
+	Prefix    string    `json:"prefix,omitempty"`  // 用于展示的前缀
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// HasRole 检查是否拥有角色，admin 拥有全部权限
+func (k *APIKey) HasRole(role string) bool {
+	for _, r := range k.Roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// matches 常量时间比较密钥
+func (k *APIKey) matches(key string) bool {
+	if k.Key != "" {
+		return subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1
+	}
+	if k.KeyHash != "" {
+		return subtle.ConstantTimeCompare([]byte(k.KeyHash), []byte(hashKey(key))) == 1
+	}
+	return false
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidRole 检查角色名是否合法
+func ValidRole(role string) bool {
+	return role == RoleQuery || role == RoleProxy || role == RoleAdmin
+}
+
+// AuthEnabled 是否启用接口鉴权（配置了任意 API Key 即启用）
+func AuthEnabled() bool {
+	cfg := GetConfig()
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+	return len(cfg.APIKeys) > 0
+}
+
+// FindAPIKey 查找匹配的 API Key
+func FindAPIKey(key string) (APIKey, bool) {
+	if key == "" {
+		return APIKey{}, false
+	}
+	cfg := GetConfig()
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+
+	for _, k := range cfg.APIKeys {
+		if k.matches(key) {
+			return k, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// ListAPIKeys 返回所有 API Key（不含明文与哈希）
+func ListAPIKeys() []APIKey {
+	cfg := GetConfig()
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+
+	list := make([]APIKey, 0, len(cfg.APIKeys))
+	for _, k := range cfg.APIKeys {
+		if k.Key != "" && k.Prefix == "" && len(k.Key) > 8 {
+			k.Prefix = k.Key[:8]
+		}
+		k.Key = ""
+		k.KeyHash = ""
+		list = append(list, k)
+	}
+	return list
+}
+
+// AddAPIKey 生成新的 API Key 并保存，返回只展示一次的明文密钥
+func AddAPIKey(name string, roles []string) (string, APIKey, error) {
+	if len(roles) == 0 {
+		return "", APIKey{}, errors.New("至少需要一个角色")
+	}
+	for _, r := range roles {
+		if !ValidRole(r) {
+			return "", APIKey{}, errors.New("无效的角色: " + r)
+		}
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", APIKey{}, err
+	}
+	key := "zto_" + hex.EncodeToString(buf)
+	id := make([]byte, 6)
+	rand.Read(id)
+
+	k := APIKey{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		KeyHash:   hashKey(key),
+		Prefix:    key[:12],
+		Roles:     roles,
+		CreatedAt: time.Now(),
+	}
+
+	cfg := GetConfig()
+	cfgLock.Lock()
+	cfg.APIKeys = append(cfg.APIKeys, k)
+	cfgLock.Unlock()
+
+	if err := SaveConfig(); err != nil {
+		return "", APIKey{}, err
+	}
+	k.KeyHash = ""
+	return key, k, nil
+}
+
+// ErrLastAPIKey 删除最后一个 API Key 会关闭鉴权，需要显式确认
+var ErrLastAPIKey = errors.New("这是最后一个 API Key，删除后将关闭接口鉴权，所有接口无需 Key 即可访问")
+
+// DeleteAPIKey 删除指定 ID 的 API Key，删除最后一个时需 force 确认
+func DeleteAPIKey(id string, force bool) error {
+	cfg := GetConfig()
+	cfgLock.Lock()
+	idx := -1
+	for i, k := range cfg.APIKeys {
+		if k.ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		cfgLock.Unlock()
+		return errors.New("API Key 不存在: " + id)
+	}
+	if len(cfg.APIKeys) == 1 && !force {
+		cfgLock.Unlock()
+		return ErrLastAPIKey
+	}
+	cfg.APIKeys = append(cfg.APIKeys[:idx:idx], cfg.APIKeys[idx+1:]...)
+	cfgLock.Unlock()
+
+	return SaveConfig()
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,11 +15,93 @@ type Config struct {
 	ChromePath     string `json:"chromePath"`
 	DataDir        string `json:"dataDir"`
 	LoginURL       string `json:"loginUrl"`
-	RefreshTime    string `json:"refreshTime"` // 凌晨刷新时间 "00:05"
+	RefreshTime    string `json:"refreshTime"` // 凌晨刷新时间 "00:05"，也支持 cron 表达式
 	PreventTime    string `json:"preventTime"` // 预防刷新时间 "19:30"
 	MaxRetries     int    `json:"maxRetries"`
 	RetryDelay     int    `json:"retryDelay"`     // 毫秒
 	RequestTimeout int    `json:"requestTimeout"` // 秒
+
+	// Token 刷新
+	RefreshCooldown int      `json:"refreshCooldown"` // 刷新失败后的冷却时间 (秒)
+	LoginStrategies []string `json:"loginStrategies"` // 依次尝试的登录方式: zbox | profile | cookie | qr
+	LoginQRWebhooks []string `json:"loginQrWebhooks"` // 扫码登录二维码额外推送到的告警渠道名称
+	ZBoxProcesses   []string `json:"zboxProcesses"`   // 宝盒进程名匹配模式，不区分大小写，支持 * ?
+
+	// 登录流程各步骤超时
+	LoginTimeouts LoginTimeouts `json:"loginTimeouts"`
+
+	// 登录诊断包
+	DiagnosticsRetentionDays int `json:"diagnosticsRetentionDays"` // 诊断包保留天数
+	DiagnosticsMaxFiles      int `json:"diagnosticsMaxFiles"`      // 每个账号最多保留的诊断包数量
+
+	// 登录状态探测
+	ProbeURL  string `json:"probeUrl"`  // 验证登录状态的轻量接口
+	ProbeBody string `json:"probeBody"` // 探测请求体 (JSON)
+
+	// 加密存储
+	EncryptSecrets bool   `json:"encryptSecrets"` // 加密保存 Token 文件
+	SecretKeyFile  string `json:"secretKeyFile"`  // 口令文件，为空时使用本机绑定密钥
+
+	// 接口鉴权
+	APIKeys        []APIKey `json:"apiKeys"`        // 配置任意 Key 后启用鉴权
+	AuthAllowLocal bool     `json:"authAllowLocal"` // 本机 (loopback) 访问免鉴权
+
+	// 透传安全策略
+	AllowedHosts        []string `json:"allowedHosts"`        // /proxy 允许访问的主机，支持 *.example.com
+	AllowPrivateTargets bool     `json:"allowPrivateTargets"` // 允许访问内网/回环地址
+	CookieDomains       []string `json:"cookieDomains"`       // 允许附带登录 Cookie 的域名
+
+	// 多账号
+	Accounts         []AccountConfig `json:"accounts"`
+	AccountSelection string          `json:"accountSelection"` // 未指定账号时的选择策略: round-robin | lru
+
+	// 请求队列
+	HostConcurrency    map[string]int `json:"hostConcurrency"`    // 各上游主机并发上限
+	DefaultConcurrency int            `json:"defaultConcurrency"` // 未配置主机的并发上限
+	QueueMaxDepth      int            `json:"queueMaxDepth"`      // 每个主机最大排队数
+	QueueWaitTimeout   int            `json:"queueWaitTimeout"`   // 排队超时 (秒)
+
+	// 自动翻页
+	PaginateMaxRecords int `json:"paginateMaxRecords"` // 单次聚合的最大记录数
+
+	// 响应缓存
+	CacheTTL        map[string]int `json:"cacheTtl"`        // 各接口路径的缓存时间 (秒)，未配置的路径不缓存
+	CacheStale      int            `json:"cacheStale"`      // 过期后仍可返回旧数据并后台刷新的时间 (秒)
+	CacheMaxEntries int            `json:"cacheMaxEntries"` // 最大缓存条数
+	CachePersist    bool           `json:"cachePersist"`    // 同时保存到 DataDir/cache，重启后可用
+
+	// 请求历史
+	HistoryRetentionDays int `json:"historyRetentionDays"` // 请求历史保留天数，0 表示不限
+	HistoryMaxSizeMB     int `json:"historyMaxSizeMB"`     // 请求历史总大小上限 (MB)，0 表示不限
+
+	// 定时任务
+	Jobs map[string]string `json:"jobs"` // 任务名 -> 调度表达式 (cron / @every / HH:MM)，"off" 表示禁用
+
+	// 数据快照
+	SnapshotJobs          []SnapshotJob `json:"snapshotJobs"`
+	SnapshotRetentionDays int           `json:"snapshotRetentionDays"` // 快照默认保留天数，0 表示不限
+
+	// 待办数量采样
+	TodoMetricsRetentionDays int `json:"todoMetricsRetentionDays"` // 采样保留天数，0 表示不限
+
+	// 日志
+	LogLevel         string            `json:"logLevel"`         // 默认日志级别: DEBUG | INFO | WARN | ERROR
+	LogLevels        map[string]string `json:"logLevels"`        // 按分类单独设置级别: APP | TOKEN | API | HTTP | Chrome
+	LogMaxSizeMB     int               `json:"logMaxSizeMB"`     // 单个日志文件上限 (MB)，超过后滚动并压缩
+	LogRetentionDays int               `json:"logRetentionDays"` // 日志保留天数，0 表示不限
+	LogMaxTotalMB    int               `json:"logMaxTotalMB"`    // 日志目录总大小上限 (MB)，0 表示不限
+
+	// 告警
+	AlertRules    []AlertRule    `json:"alertRules"`
+	AlertWebhooks []AlertWebhook `json:"alertWebhooks"`
+	AlertCooldown int            `json:"alertCooldown"` // 同一告警重复通知的最小间隔 (分钟)
+}
+
+// LoginTimeouts 登录流程各步骤超时 (秒)
+type LoginTimeouts struct {
+	PageLoad int `json:"pageLoad"` // 打开登录页
+	Button   int `json:"button"`   // 等待「一键登录」按钮出现
+	Cookie   int `json:"cookie"`   // 点击后等待登录 Cookie 写入
 }
 
 // TokenData Token 存储结构
@@ -51,6 +134,72 @@ func DefaultConfig() *Config {
 		MaxRetries:     3,
 		RetryDelay:     1000,
 		RequestTimeout: 30,
+
+		RefreshCooldown: 300,
+		LoginStrategies: []string{"zbox"},
+		ZBoxProcesses:   []string{"*zbox*", "lite.exe"},
+
+		LoginTimeouts: LoginTimeouts{PageLoad: 30, Button: 20, Cookie: 45},
+
+		DiagnosticsRetentionDays: 7,
+		DiagnosticsMaxFiles:      20,
+
+		ProbeURL:  "https://preorder-query-center.gw.zt-express.com/preOrderQuery/getSiteCardFilterCount",
+		ProbeBody: `{"traceQueryChannel":"ALL_PICK_CHANNEL"}`,
+
+		EncryptSecrets: true,
+
+		AuthAllowLocal: true,
+
+		AllowedHosts:  []string{"*.zt-express.com"},
+		CookieDomains: []string{"*.zt-express.com"},
+
+		AccountSelection: "round-robin",
+
+		HostConcurrency: map[string]int{
+			"preorder-query-center.gw.zt-express.com": 4,
+			"orderapi.zt-express.com":                 2,
+		},
+		DefaultConcurrency: 4,
+		QueueMaxDepth:      50,
+		QueueWaitTimeout:   30,
+
+		PaginateMaxRecords: 10000,
+
+		CacheTTL: map[string]int{
+			"/preOrderQuery/getTodoCenterList":               10,
+			"/preOrderQuery/getSiteOrderTraceList":           10,
+			"/opsApi/zjProvinceReport/queryZjPreOrderReport": 30,
+		},
+		CacheStale:      60,
+		CacheMaxEntries: 500,
+
+		HistoryRetentionDays: 30,
+		HistoryMaxSizeMB:     200,
+
+		Jobs: map[string]string{
+			"token-check":     "* * * * *",
+			"history-cleanup": "30 3 * * *",
+			"todo-metrics":    "*/5 * * * *",
+			"alert-check":     "* * * * *",
+			"token-probe":     "*/10 * * * *",
+		},
+
+		SnapshotRetentionDays: 90,
+
+		TodoMetricsRetentionDays: 30,
+
+		LogLevel:         "INFO",
+		LogMaxSizeMB:     20,
+		LogRetentionDays: 30,
+		LogMaxTotalMB:    500,
+
+		AlertRules: []AlertRule{
+			{Name: "Token 失效", Type: AlertTokenInvalid, Level: "critical"},
+			{Name: "Token 即将过期", Type: AlertTokenExpiring, Threshold: 15},
+			{Name: "自动刷新失败", Type: AlertRefreshFailed, Level: "critical"},
+		},
+		AlertCooldown: 60,
 	}
 }
 
@@ -73,6 +222,46 @@ func GetConfig() *Config {
 	return cfg
 }
 
+// RedactedConfig 返回隐藏了 API Key 与告警渠道密钥的配置副本，供管理接口展示
+func RedactedConfig() Config {
+	c := GetConfig()
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+
+	out := *c
+	out.APIKeys = make([]APIKey, len(c.APIKeys))
+	for i, k := range c.APIKeys {
+		k.Key, k.KeyHash = "", ""
+		out.APIKeys[i] = k
+	}
+	out.AlertWebhooks = make([]AlertWebhook, len(c.AlertWebhooks))
+	for i, h := range c.AlertWebhooks {
+		h.URL = redactURL(h.URL)
+		if h.Secret != "" {
+			h.Secret = redacted
+		}
+		if len(h.Headers) > 0 {
+			headers := make(map[string]string, len(h.Headers))
+			for name := range h.Headers {
+				headers[name] = redacted
+			}
+			h.Headers = headers
+		}
+		out.AlertWebhooks[i] = h
+	}
+	return out
+}
+
+const redacted = "******"
+
+// redactURL 隐藏 Webhook 地址中的查询参数 (机器人 access_token / key)
+func redactURL(raw string) string {
+	if i := strings.IndexByte(raw, '?'); i >= 0 {
+		return raw[:i+1] + redacted
+	}
+	return raw
+}
+
 func loadConfig() {
 	configPath := filepath.Join(cfg.DataDir, "config.json")
 	data, err := os.ReadFile(configPath)
@@ -86,17 +275,17 @@ func loadConfig() {
 func SaveConfig() error {
 	cfgLock.RLock()
 	defer cfgLock.RUnlock()
+	return writeConfig()
+}
 
-	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
-		return err
-	}
-
-	configPath := filepath.Join(cfg.DataDir, "config.json")
+// writeConfig 写入 config.json，调用方需持有 cfgLock。
+// 文件中含手工配置的 API Key 与 Webhook 密钥且未加密，仅当前用户可读
+func writeConfig() error {
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, data, 0644)
+	return writeFileAtomic(filepath.Join(cfg.DataDir, "config.json"), data)
 }
 
 // SetCustomConfig 更新并保存配置
@@ -109,22 +298,29 @@ func SetCustomConfig(newCfg *Config) error {
 	cfg.PreventTime = newCfg.PreventTime
 	cfg.MaxRetries = newCfg.MaxRetries
 
-	// 保存到文件
-	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
-		return err
-	}
-	configPath := filepath.Join(cfg.DataDir, "config.json")
-	data, err := json.MarshalIndent(cfg, "", "  ")
-	if err != nil {
-		return err
+	return writeConfig()
+}
+
+// JobSpec 返回定时任务的调度表达式，Token 定时刷新任务使用 refreshTime / preventTime
+func JobSpec(name string) string {
+	cfg := GetConfig()
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+
+	switch name {
+	case "token-refresh":
+		return cfg.RefreshTime
+	case "token-prevent":
+		return cfg.PreventTime
 	}
-	return os.WriteFile(configPath, data, 0644)
+	return cfg.Jobs[name]
 }
 
 // GetTokenData 获取 Token 数据
 func GetTokenData() *TokenData {
-	tokenLock.RLock()
-	defer tokenLock.RUnlock()
+	// 首次访问会写入 tokenData，需要写锁
+	tokenLock.Lock()
+	defer tokenLock.Unlock()
 
 	if tokenData == nil {
 		loadTokenData()
@@ -143,68 +339,20 @@ func SetTokenData(data *TokenData) error {
 
 func loadTokenData() {
 	cfg := GetConfig()
-	tokenPath := filepath.Join(cfg.DataDir, "token.json")
-	data, err := os.ReadFile(tokenPath)
-	if err != nil {
-		tokenData = &TokenData{
-			Cookies: make(map[string]string),
-		}
-		return
-	}
-	tokenData = &TokenData{}
-	json.Unmarshal(data, tokenData)
-	if tokenData.Cookies == nil {
-		tokenData.Cookies = make(map[string]string)
-	}
+	tokenData = readTokenFile(accountTokenPath(cfg.DataDir, DefaultAccount))
 }
 
 func saveTokenData() error {
 	cfg := GetConfig()
-	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
-		return err
-	}
-
-	tokenPath := filepath.Join(cfg.DataDir, "token.json")
-	data, err := json.MarshalIndent(tokenData, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(tokenPath, data, 0644)
+	return writeTokenFile(accountTokenPath(cfg.DataDir, DefaultAccount), tokenData)
 }
 
 // IsTokenValid 检查 Token 是否有效
 func IsTokenValid() bool {
-	token := GetTokenData()
-	if token == nil || len(token.Cookies) == 0 {
-		return false
-	}
-
-	// 检查是否有必需的 cookies
-	if _, ok := token.Cookies["wyzdzjxhdnh"]; !ok {
-		return false
-	}
-
-	// 检查是否过期
-	if token.ExpiresAt.IsZero() {
-		return false
-	}
-
-	return time.Now().Before(token.ExpiresAt)
+	return tokenValid(GetTokenData())
 }
 
 // GetCookieString 获取 Cookie 字符串
 func GetCookieString() string {
-	token := GetTokenData()
-	if token == nil {
-		return ""
-	}
-
-	result := ""
-	for name, value := range token.Cookies {
-		if result != "" {
-			result += "; "
-		}
-		result += name + "=" + value
-	}
-	return result
+	return cookieString(GetTokenData())
 }
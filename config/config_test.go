@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -23,6 +24,25 @@ func TestDefaultConfig(t *testing.T) {
 	}
 }
 
+func TestSaveConfig_Permissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不使用 Unix 文件权限")
+	}
+	cfg := GetConfig()
+	oldDir := cfg.DataDir
+	cfg.DataDir = t.TempDir()
+	defer func() { cfg.DataDir = oldDir }()
+
+	path := filepath.Join(cfg.DataDir, "config.json")
+	os.WriteFile(path, []byte("{}"), 0644)
+	if err := SaveConfig(); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm()&0077 != 0 {
+		t.Errorf("config.json 含明文密钥，权限过宽: %v", info.Mode().Perm())
+	}
+}
+
 func TestTokenDataSaveLoad(t *testing.T) {
 	// 使用临时目录
 	tmpDir := filepath.Join(os.TempDir(), "zto-api-proxy-test")
@@ -103,3 +123,22 @@ func TestGetCookieString(t *testing.T) {
 		t.Error("Cookie 字符串长度不正确")
 	}
 }
+
+func TestAccountTokenPath_NoCollision(t *testing.T) {
+	dir := t.TempDir()
+	if got := accountTokenPath(dir, "site2"); got != filepath.Join(dir, "tokens", "site2.json") {
+		t.Errorf("无需替换的账号名应保持原文件名: %s", got)
+	}
+
+	paths := map[string]string{}
+	for _, name := range []string{"a/b", "a_b", `a\b`, "a:b"} {
+		p := accountTokenPath(dir, name)
+		if other, ok := paths[p]; ok {
+			t.Errorf("账号 %q 与 %q 使用了同一个文件 %s", name, other, p)
+		}
+		if filepath.Dir(p) != filepath.Join(dir, "tokens") {
+			t.Errorf("账号 %q 的文件不应在 tokens 目录之外: %s", name, p)
+		}
+		paths[p] = name
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// machineID 读取 systemd/dbus 的机器标识
+func machineID() (string, error) {
+	for _, p := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(p); err == nil {
+			if id := strings.TrimSpace(string(data)); id != "" {
+				return id, nil
+			}
+		}
+	}
+	return "", errors.New("未找到机器标识")
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package config
+
+import "golang.org/x/sys/windows/registry"
+
+// machineID 读取 Windows 安装时生成的 MachineGuid
+func machineID() (string, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`,
+		registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return "", err
+	}
+	defer k.Close()
+
+	id, _, err := k.GetStringValue("MachineGuid")
+	return id, err
+}
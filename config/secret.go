@@ -0,0 +1,344 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"zto-api-proxy/logger"
+)
+
+// secretMagic 加密文件头
+const secretMagic = "ZTOENC1\n"
+
+// rotateSuffix 更换密钥时重新加密的临时文件后缀
+const rotateSuffix = ".rotate"
+
+var (
+	// ErrSecretCorrupted 密文损坏或密钥不匹配
+	ErrSecretCorrupted = errors.New("加密文件已损坏或密钥不匹配")
+	// ErrSecretEncrypted 明文存储读取到了加密文件
+	ErrSecretEncrypted = errors.New("文件已加密，请启用 encryptSecrets")
+)
+
+// SecretStore 敏感文件的读写方式
+type SecretStore interface {
+	Read(path string) ([]byte, error)
+	Write(path string, data []byte) error
+}
+
+var (
+	store     SecretStore
+	storeLock sync.Mutex
+)
+
+// getStore 按配置创建存储（成功后只创建一次）。
+// 无法获取密钥时返回错误而不是退回明文，避免 Token 被写成明文
+func getStore() (SecretStore, error) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	if store == nil {
+		s, err := NewStoreFromConfig(GetConfig())
+		if err != nil {
+			logger.Error("初始化加密存储失败: %v", err)
+			return nil, fmt.Errorf("初始化加密存储失败: %w", err)
+		}
+		store = s
+	}
+	return store, nil
+}
+
+// SetSecretStore 替换当前使用的存储
+func SetSecretStore(s SecretStore) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	store = s
+}
+
+// NewStoreFromConfig 根据配置创建存储
+func NewStoreFromConfig(cfg *Config) (SecretStore, error) {
+	if !cfg.EncryptSecrets {
+		return PlainStore{}, nil
+	}
+	salt, fresh, err := loadSalt(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(cfg.SecretKeyFile, salt)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewAESStore(key)
+	if err != nil {
+		return nil, err
+	}
+	if fresh {
+		path := saltPath(cfg.DataDir)
+		s.persist = func() error { return writeFileAtomic(path, salt) }
+	}
+	return s, nil
+}
+
+// PlainStore 明文存储
+type PlainStore struct{}
+
+// Read 读取明文文件
+func (PlainStore) Read(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte(secretMagic)) {
+		return nil, ErrSecretEncrypted
+	}
+	return data, nil
+}
+
+// Write 写入明文文件（仅当前用户可读）
+func (PlainStore) Write(path string, data []byte) error {
+	return writeFileAtomic(path, data)
+}
+
+// AESStore AES-GCM 加密存储
+type AESStore struct {
+	aead cipher.AEAD
+
+	persistMu sync.Mutex
+	persist   func() error // 首次写入前保存新生成的盐值
+}
+
+// NewAESStore 使用 32 字节密钥创建加密存储
+func NewAESStore(key []byte) (*AESStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESStore{aead: aead}, nil
+}
+
+// Read 读取并解密文件，遇到旧版明文文件时自动加密迁移
+func (s *AESStore) Read(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(data, []byte(secretMagic)) {
+		if err := s.Write(path, data); err != nil {
+			return nil, fmt.Errorf("迁移明文文件失败: %w", err)
+		}
+		return data, nil
+	}
+
+	sealed := data[len(secretMagic):]
+	nonceSize := s.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, ErrSecretCorrupted
+	}
+	plain, err := s.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(secretMagic))
+	if err != nil {
+		return nil, ErrSecretCorrupted
+	}
+	return plain, nil
+}
+
+// Write 加密并写入文件
+func (s *AESStore) Write(path string, data []byte) error {
+	if err := s.persistSalt(); err != nil {
+		return fmt.Errorf("保存密钥盐值失败: %w", err)
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	out := make([]byte, 0, len(secretMagic)+len(nonce)+len(data)+s.aead.Overhead())
+	out = append(out, secretMagic...)
+	out = append(out, nonce...)
+	out = s.aead.Seal(out, nonce, data, []byte(secretMagic))
+	return writeFileAtomic(path, out)
+}
+
+func (s *AESStore) persistSalt() error {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	if s.persist == nil {
+		return nil
+	}
+	if err := s.persist(); err != nil {
+		return err
+	}
+	s.persist = nil
+	return nil
+}
+
+// writeFileAtomic 先写临时文件再替换，避免写到一半损坏
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func saltPath(dataDir string) string {
+	return filepath.Join(dataDir, "secret.salt")
+}
+
+// loadSalt 读取密钥盐值，不存在时生成新盐值 (fresh)，由首次写入时保存
+func loadSalt(dataDir string) ([]byte, bool, error) {
+	salt, err := os.ReadFile(saltPath(dataDir))
+	if err == nil && len(salt) >= 16 {
+		return salt, false, nil
+	}
+	salt = make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, false, err
+	}
+	return salt, true, nil
+}
+
+// deriveKey 从口令文件或本机标识派生密钥
+func deriveKey(keyFile string, salt []byte) ([]byte, error) {
+	if keyFile != "" {
+		pass, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取口令文件失败: %w", err)
+		}
+		pass = bytes.TrimSpace(pass)
+		if len(pass) == 0 {
+			return nil, fmt.Errorf("口令文件为空: %s", keyFile)
+		}
+		return pbkdf2.Key(sha256.New, string(pass), salt, 200000, 32)
+	}
+
+	id, err := machineID()
+	if err != nil {
+		return nil, fmt.Errorf("获取本机标识失败: %w", err)
+	}
+	return hkdf.Key(sha256.New, []byte(id), salt, "zto-api-proxy secret store", 32)
+}
+
+// RotateSecretKey 更换加密密钥并重新加密所有 Token 文件
+// newKeyFile 为空时使用新的本机绑定密钥
+func RotateSecretKey(newKeyFile string) error {
+	cfg := GetConfig()
+	oldStore, err := getStore()
+	if err != nil {
+		return err
+	}
+
+	// 先用旧密钥解密所有文件
+	paths := tokenFiles(cfg.DataDir)
+	contents := make(map[string][]byte, len(paths))
+	for _, p := range paths {
+		data, err := oldStore.Read(p)
+		if err != nil {
+			return fmt.Errorf("解密 %s 失败: %w", filepath.Base(p), err)
+		}
+		contents[p] = data
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key, err := deriveKey(newKeyFile, salt)
+	if err != nil {
+		return err
+	}
+	newStore, err := NewAESStore(key)
+	if err != nil {
+		return err
+	}
+
+	// 先写入临时文件，全部成功后再提交盐值与配置，最后替换原文件
+	staged := make([]string, 0, len(contents))
+	discard := func() {
+		for _, p := range staged {
+			os.Remove(p + rotateSuffix)
+		}
+	}
+	for p, data := range contents {
+		if err := newStore.Write(p+rotateSuffix, data); err != nil {
+			discard()
+			return fmt.Errorf("重新加密 %s 失败: %w", filepath.Base(p), err)
+		}
+		staged = append(staged, p)
+	}
+
+	// 旧盐值保留到所有文件替换完成，中途失败时可用于恢复
+	sp := saltPath(cfg.DataDir)
+	backup := sp + ".bak"
+	oldSalt, err := os.ReadFile(sp)
+	if err == nil {
+		if err := writeFileAtomic(backup, oldSalt); err != nil {
+			discard()
+			return fmt.Errorf("备份盐值失败: %w", err)
+		}
+	}
+	restore := func() {
+		if oldSalt != nil {
+			writeFileAtomic(sp, oldSalt)
+		}
+		discard()
+	}
+
+	if err := writeFileAtomic(sp, salt); err != nil {
+		restore()
+		return err
+	}
+	cfgLock.Lock()
+	oldEncrypt, oldKeyFile := cfg.EncryptSecrets, cfg.SecretKeyFile
+	cfg.EncryptSecrets = true
+	cfg.SecretKeyFile = newKeyFile
+	cfgLock.Unlock()
+	if err := SaveConfig(); err != nil {
+		cfgLock.Lock()
+		cfg.EncryptSecrets, cfg.SecretKeyFile = oldEncrypt, oldKeyFile
+		cfgLock.Unlock()
+		restore()
+		return err
+	}
+
+	for _, p := range staged {
+		if err := os.Rename(p+rotateSuffix, p); err != nil {
+			return fmt.Errorf("替换 %s 失败 (旧盐值已备份到 %s): %w", filepath.Base(p), filepath.Base(backup), err)
+		}
+	}
+	SetSecretStore(newStore)
+	os.Remove(backup)
+	return nil
+}
+
+// tokenFiles 列出数据目录下的所有 Token 文件
+func tokenFiles(dataDir string) []string {
+	var paths []string
+	if p := accountTokenPath(dataDir, DefaultAccount); fileExists(p) {
+		paths = append(paths, p)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dataDir, "tokens", "*.json"))
+	return append(paths, matches...)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func testKey() []byte {
+	return bytes.Repeat([]byte{0x42}, 32)
+}
+
+func TestAESStore_RoundTrip(t *testing.T) {
+	s, err := NewAESStore(testKey())
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "token.json")
+	plain := []byte(`{"cookies":{"wyzdzjxhdnh":"secret-jwt"}}`)
+
+	if err := s.Write(path, plain); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if bytes.Contains(raw, []byte("secret-jwt")) {
+		t.Error("文件中不应出现明文 Token")
+	}
+	if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		t.Errorf("文件权限过宽: %v", info.Mode().Perm())
+	}
+
+	got, err := s.Read(path)
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("解密结果不一致: %s", got)
+	}
+}
+
+func TestAESStore_Corrupted(t *testing.T) {
+	s, _ := NewAESStore(testKey())
+	path := filepath.Join(t.TempDir(), "token.json")
+	s.Write(path, []byte(`{"cookies":{}}`))
+
+	raw, _ := os.ReadFile(path)
+	raw[len(raw)-1] ^= 0xff
+	os.WriteFile(path, raw, 0600)
+
+	if _, err := s.Read(path); err != ErrSecretCorrupted {
+		t.Errorf("期望 ErrSecretCorrupted, 实际 %v", err)
+	}
+
+	// 错误的密钥同样无法解密
+	s.Write(path, []byte(`{"cookies":{}}`))
+	other, _ := NewAESStore(bytes.Repeat([]byte{0x24}, 32))
+	if _, err := other.Read(path); err != ErrSecretCorrupted {
+		t.Errorf("错误密钥期望 ErrSecretCorrupted, 实际 %v", err)
+	}
+}
+
+func TestAESStore_MigratePlaintext(t *testing.T) {
+	s, _ := NewAESStore(testKey())
+	path := filepath.Join(t.TempDir(), "token.json")
+	plain := []byte(`{"cookies":{"wyzdzjxhdnh":"legacy"}}`)
+	os.WriteFile(path, plain, 0644)
+
+	got, err := s.Read(path)
+	if err != nil {
+		t.Fatalf("读取旧版明文失败: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("迁移读取结果不一致: %s", got)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(raw, []byte(secretMagic)) {
+		t.Error("读取后应自动迁移为加密文件")
+	}
+	if _, err := (PlainStore{}).Read(path); err != ErrSecretEncrypted {
+		t.Errorf("明文存储读取加密文件期望 ErrSecretEncrypted, 实际 %v", err)
+	}
+}
+
+// withSecretDir 使用临时数据目录与按配置创建的加密存储
+func withSecretDir(t *testing.T) string {
+	cfg := GetConfig()
+	oldDir, oldEncrypt, oldKeyFile := cfg.DataDir, cfg.EncryptSecrets, cfg.SecretKeyFile
+	cfg.DataDir, cfg.EncryptSecrets, cfg.SecretKeyFile = t.TempDir(), true, ""
+	SetSecretStore(nil)
+	t.Cleanup(func() {
+		cfg.DataDir, cfg.EncryptSecrets, cfg.SecretKeyFile = oldDir, oldEncrypt, oldKeyFile
+		SetSecretStore(nil)
+	})
+	return cfg.DataDir
+}
+
+func TestRotateSecretKey(t *testing.T) {
+	dir := withSecretDir(t)
+	path := filepath.Join(dir, "tokens", "a.json")
+	plain := []byte(`{"cookies":{"wyzdzjxhdnh":"a"}}`)
+	if err := mustStore(t).Write(path, plain); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	keyFile := filepath.Join(dir, "pass.txt")
+	os.WriteFile(keyFile, []byte("new-passphrase"), 0600)
+
+	// 配置无法保存时不应改动盐值与已加密文件
+	oldSalt, _ := os.ReadFile(saltPath(dir))
+	os.Mkdir(filepath.Join(dir, "config.json"), 0755)
+	if err := RotateSecretKey(keyFile); err == nil {
+		t.Fatal("配置保存失败时应返回错误")
+	}
+	if salt, _ := os.ReadFile(saltPath(dir)); !bytes.Equal(salt, oldSalt) {
+		t.Error("失败后应恢复旧盐值")
+	}
+	if got, err := mustStore(t).Read(path); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("失败后旧密钥应仍可解密: %s %v", got, err)
+	}
+	if GetConfig().SecretKeyFile != "" {
+		t.Error("失败后不应修改配置")
+	}
+
+	os.Remove(filepath.Join(dir, "config.json"))
+	if err := RotateSecretKey(keyFile); err != nil {
+		t.Fatalf("更换密钥失败: %v", err)
+	}
+	SetSecretStore(nil)
+	if got, err := mustStore(t).Read(path); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("新密钥解密失败: %s %v", got, err)
+	}
+	for _, leftover := range []string{path + rotateSuffix, saltPath(dir) + ".bak"} {
+		if fileExists(leftover) {
+			t.Errorf("不应残留临时文件 %s", filepath.Base(leftover))
+		}
+	}
+}
+
+func mustStore(t *testing.T) SecretStore {
+	s, err := getStore()
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	return s
+}
+
+func TestSaltCreatedOnWrite(t *testing.T) {
+	dir := withSecretDir(t)
+
+	if tok := readTokenFile(filepath.Join(dir, "token.json")); len(tok.Cookies) != 0 {
+		t.Errorf("不存在的文件应返回空 Token: %v", tok.Cookies)
+	}
+	if fileExists(saltPath(dir)) {
+		t.Error("只读取时不应生成盐值文件")
+	}
+
+	if err := writeTokenFile(filepath.Join(dir, "token.json"), &TokenData{Cookies: map[string]string{"k": "v"}}); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if !fileExists(saltPath(dir)) {
+		t.Error("首次写入时应保存盐值文件")
+	}
+}
+
+func TestGetStore_NoFallback(t *testing.T) {
+	withSecretDir(t)
+	GetConfig().SecretKeyFile = filepath.Join(t.TempDir(), "missing.txt")
+
+	if _, err := getStore(); err == nil {
+		t.Fatal("无法获取密钥时应返回错误")
+	}
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := writeTokenFile(path, &TokenData{Cookies: map[string]string{"k": "v"}}); err == nil {
+		t.Error("加密存储不可用时应拒绝写入")
+	}
+	if fileExists(path) {
+		t.Error("不应退回明文写入")
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+// SnapshotJob 定时数据采集任务
+type SnapshotJob struct {
+	Name          string      `json:"name"`
+	Schedule      string      `json:"schedule"`                // 调度表达式 (cron / @every / HH:MM)
+	Endpoint      string      `json:"endpoint"`                // 便捷接口路径及参数，如 /province-report?date={{yesterday}}
+	Method        string      `json:"method,omitempty"`        // 为空时有 Body 用 POST，否则 GET
+	Body          interface{} `json:"body,omitempty"`          // 请求体模板，字符串中的占位符会被替换
+	Account       string      `json:"account,omitempty"`       // 使用的账号，为空时自动选择
+	Paginate      bool        `json:"paginate,omitempty"`      // 拉取全部分页 (all=true)
+	Format        string      `json:"format,omitempty"`        // json | csv | xlsx | ndjson，默认 json
+	Columns       string      `json:"columns,omitempty"`       // 导出列，见 export.ParseColumns
+	CatchUp       bool        `json:"catchUp,omitempty"`       // 休眠/关机错过后补执行
+	RetentionDays int         `json:"retentionDays,omitempty"` // 保留天数，0 使用 snapshotRetentionDays
+	MaxFiles      int         `json:"maxFiles,omitempty"`      // 最多保留的快照数，0 表示不限
+}
+
+// GetSnapshotJob 按名称查找采集任务
+func GetSnapshotJob(name string) (SnapshotJob, bool) {
+	cfg := GetConfig()
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+
+	for _, job := range cfg.SnapshotJobs {
+		if job.Name == name {
+			return job, true
+		}
+	}
+	return SnapshotJob{}, false
+}
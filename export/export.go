@@ -0,0 +1,184 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"zto-api-proxy/proxy"
+)
+
+// 支持的导出格式
+const (
+	FormatCSV    = "csv"
+	FormatXLSX   = "xlsx"
+	FormatNDJSON = "ndjson"
+)
+
+// Column 导出列
+type Column struct {
+	Key   string // 扁平化后的字段路径，如 sender.name
+	Title string // 表头
+}
+
+// Table 扁平化后的表格数据
+type Table struct {
+	Columns []Column
+	Rows    []map[string]interface{}
+}
+
+// ValidFormat 检查导出格式是否支持
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX || format == FormatNDJSON
+}
+
+// ContentType 返回导出格式对应的 MIME 类型
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/x-ndjson; charset=utf-8"
+	}
+}
+
+// ParseColumns 解析列选择参数 "billCode,siteName:网点" ，冒号后为自定义表头
+func ParseColumns(spec string) []Column {
+	var cols []Column
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, title, _ := strings.Cut(part, ":")
+		key = strings.TrimSpace(key)
+		title = strings.TrimSpace(title)
+		if title == "" {
+			title = HeaderTitle(key)
+		}
+		cols = append(cols, Column{Key: key, Title: title})
+	}
+	return cols
+}
+
+// ExtractRows 从代理响应数据中取出数据行
+func ExtractRows(data interface{}) []interface{} {
+	switch v := data.(type) {
+	case *proxy.PageResult:
+		return v.Rows
+	case []interface{}:
+		return v
+	}
+	if rows, _, ok := proxy.ExtractPage(data); ok {
+		return rows
+	}
+
+	// 无分页信息时查找常见包装层中的列表，否则整个对象作为一行
+	if obj, ok := data.(map[string]interface{}); ok {
+		for _, k := range []string{"result", "data", "list", "records", "rows"} {
+			switch inner := obj[k].(type) {
+			case []interface{}:
+				return inner
+			case map[string]interface{}:
+				return ExtractRows(inner)
+			}
+		}
+		return []interface{}{obj}
+	}
+	return nil
+}
+
+// Flatten 将嵌套对象展开为 a.b.c 形式的单层字段，数组序列化为 JSON
+func Flatten(row interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	flatten("", row, out)
+	return out
+}
+
+func flatten(prefix string, v interface{}, out map[string]interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 && prefix != "" {
+			out[prefix] = nil
+		}
+		for k, inner := range val {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flatten(key, inner, out)
+		}
+	case []interface{}:
+		b, _ := json.Marshal(val)
+		out[prefix] = string(b)
+	default:
+		if prefix == "" {
+			prefix = "value"
+		}
+		out[prefix] = val
+	}
+}
+
+// NewTable 扁平化数据行；未指定列时按已知字段优先、其余按字母顺序排列
+func NewTable(rows []interface{}, columns []Column) *Table {
+	t := &Table{Rows: make([]map[string]interface{}, 0, len(rows))}
+	seen := make(map[string]bool)
+	var keys []string
+	for _, r := range rows {
+		flat := Flatten(r)
+		t.Rows = append(t.Rows, flat)
+		for k := range flat {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	if len(columns) > 0 {
+		t.Columns = columns
+		return t
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		oi, iKnown := headerOrder[keys[i]]
+		oj, jKnown := headerOrder[keys[j]]
+		switch {
+		case iKnown && jKnown:
+			return oi < oj
+		case iKnown != jKnown:
+			return iKnown
+		default:
+			return keys[i] < keys[j]
+		}
+	})
+	for _, k := range keys {
+		t.Columns = append(t.Columns, Column{Key: k, Title: HeaderTitle(k)})
+	}
+	return t
+}
+
+// FormatValue 将字段值转换为单元格文本
+func FormatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		if val {
+			return "是"
+		}
+		return "否"
+	case json.Number:
+		return val.String()
+	default:
+		return fmt.Sprint(val)
+	}
+}
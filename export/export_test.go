@@ -0,0 +1,153 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func loadFixture(t *testing.T, name string) interface{} {
+	raw, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("读取样例失败: %v", err)
+	}
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatalf("解析样例失败: %v", err)
+	}
+	return data
+}
+
+func TestWriteCSV_OrderTrace(t *testing.T) {
+	rows := ExtractRows(loadFixture(t, "order_trace.json"))
+	if len(rows) != 2 {
+		t.Fatalf("期望 2 行, 实际 %d", len(rows))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, NewTable(rows, nil)); err != nil {
+		t.Fatalf("写入 CSV 失败: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "\uFEFF") {
+		t.Error("CSV 应以 BOM 开头")
+	}
+
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\uFEFF"))).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV 失败: %v", err)
+	}
+	header := strings.Join(records[0], ",")
+	if !strings.HasPrefix(header, "运单号,订单号,订单状态,网点编码,网点名称") {
+		t.Errorf("表头顺序不正确: %s", header)
+	}
+	if !strings.Contains(header, "sender.name") {
+		t.Errorf("嵌套字段应展开: %s", header)
+	}
+	if records[1][0] != "78912345678901" {
+		t.Errorf("单号不正确: %v", records[1])
+	}
+}
+
+func TestWriteCSV_Injection(t *testing.T) {
+	rows := []interface{}{map[string]interface{}{
+		"a": "=HYPERLINK(\"http://x\")", "b": "+1+1", "c": "-2+3", "d": "@SUM(A1)",
+		"e": "\tcmd", "f": "\rcmd", "g": -12.5, "h": "普通文本",
+	}}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, NewTable(rows, nil)); err != nil {
+		t.Fatalf("写入 CSV 失败: %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\uFEFF"))).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV 失败: %v", err)
+	}
+
+	got := map[string]string{}
+	for i, key := range records[0] {
+		got[key] = records[1][i]
+	}
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		if !strings.HasPrefix(got[key], "'") {
+			t.Errorf("%s: 公式开头的单元格应加 ' 前缀, 实际 %q", key, got[key])
+		}
+	}
+	if got["g"] != "-12.5" || got["h"] != "普通文本" {
+		t.Errorf("数字与普通文本应保持原样: %q %q", got["g"], got["h"])
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	rows := ExtractRows(loadFixture(t, "order_trace.json"))
+	table := NewTable(rows, ParseColumns("empName, sender.name:寄件人,weight"))
+
+	var buf bytes.Buffer
+	WriteCSV(&buf, table)
+	records, _ := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\uFEFF"))).ReadAll()
+
+	want := [][]string{
+		{"业务员", "寄件人", "重量"},
+		{"张三", "李四", "1.25"},
+		{"王五", `赵六, "小店"`, "0.5"},
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("第 %d 行: 期望 %v, 实际 %v", i, want[i], records[i])
+		}
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	rows := ExtractRows(loadFixture(t, "province_report.json"))
+	table := NewTable(rows, ParseColumns("streetName,pickRate"))
+
+	var buf bytes.Buffer
+	if err := WriteNDJSON(&buf, table); err != nil {
+		t.Fatalf("写入 NDJSON 失败: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != `{"pickRate":0.9833,"streetName":"民治街道"}` {
+		t.Errorf("NDJSON 输出不正确: %q", lines)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	rows := ExtractRows(loadFixture(t, "province_report.json"))
+
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, NewTable(rows, nil)); err != nil {
+		t.Fatalf("写入 XLSX 失败: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("XLSX 不是有效的 zip: %v", err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			b, _ := io.ReadAll(rc)
+			rc.Close()
+			sheet = string(b)
+		}
+	}
+	if !strings.Contains(sheet, "<t xml:space=\"preserve\">省份</t>") {
+		t.Error("工作表缺少中文表头")
+	}
+	if !strings.Contains(sheet, "<v>120</v>") {
+		t.Error("数字应写为数值单元格")
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, 期望 %s", i, got, want)
+		}
+	}
+}
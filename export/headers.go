@@ -0,0 +1,76 @@
+package export
+
+// 常见字段的中文表头，顺序即默认列顺序
+var knownHeaders = []Column{
+	{"billCode", "运单号"},
+	{"orderCode", "订单号"},
+	{"orderId", "订单ID"},
+	{"orderStatus", "订单状态"},
+	{"orderStatusName", "订单状态"},
+	{"orderType", "订单类型"},
+	{"orderTypeName", "订单类型"},
+	{"orderSource", "订单来源"},
+	{"partnerName", "合作商"},
+	{"siteCode", "网点编码"},
+	{"siteName", "网点名称"},
+	{"empCode", "业务员编码"},
+	{"empName", "业务员"},
+	{"senderName", "寄件人"},
+	{"senderMobile", "寄件人电话"},
+	{"senderProv", "寄件省"},
+	{"senderCity", "寄件市"},
+	{"senderCounty", "寄件区县"},
+	{"senderAddress", "寄件地址"},
+	{"receiverName", "收件人"},
+	{"receiverMobile", "收件人电话"},
+	{"receiverProv", "收件省"},
+	{"receiverCity", "收件市"},
+	{"receiverCounty", "收件区县"},
+	{"receiverAddress", "收件地址"},
+	{"provinceName", "省份"},
+	{"cityName", "城市"},
+	{"countyName", "区县"},
+	{"streetName", "街道"},
+	{"batchName", "班次"},
+	{"orderCount", "订单量"},
+	{"pickCount", "揽收量"},
+	{"unPickCount", "未揽收量"},
+	{"pickRate", "揽收率"},
+	{"timelyCount", "及时揽收量"},
+	{"timelyRate", "及时揽收率"},
+	{"complianceResult", "合规结果"},
+	{"weight", "重量"},
+	{"payStatus", "支付状态"},
+	{"pickUpCode", "取件码"},
+	{"createTime", "下单时间"},
+	{"orderCreateTime", "下单时间"},
+	{"appointmentTime", "预约时间"},
+	{"pickTime", "揽收时间"},
+	{"signTime", "签收时间"},
+	{"updateTime", "更新时间"},
+	{"remark", "备注"},
+	{"todoType", "待办类型"},
+	{"todoName", "待办事项"},
+	{"count", "数量"},
+	{"total", "总数"},
+}
+
+var (
+	headerTitles = make(map[string]string, len(knownHeaders))
+	headerOrder  = make(map[string]int, len(knownHeaders))
+)
+
+func init() {
+	for i, h := range knownHeaders {
+		headerTitles[h.Key] = h.Title
+		headerOrder[h.Key] = i
+	}
+}
+
+// HeaderTitle 返回字段的中文表头，未知字段使用原字段名
+func HeaderTitle(key string) string {
+	if t, ok := headerTitles[key]; ok {
+		return t
+	}
+	return key
+}
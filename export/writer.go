@@ -0,0 +1,174 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Write 按格式输出表格
+func Write(w io.Writer, format string, t *Table) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, t)
+	case FormatXLSX:
+		return WriteXLSX(w, t)
+	case FormatNDJSON:
+		return WriteNDJSON(w, t)
+	}
+	return fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+// WriteCSV 输出带 BOM 的 UTF-8 CSV，Excel 可直接打开中文
+func WriteCSV(w io.Writer, t *Table) error {
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = csvSafe(c.Title)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, c := range t.Columns {
+			record[i] = csvSafe(FormatValue(row[c.Key]))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe 防止 CSV 注入：以 = + - @ 制表符或回车开头的单元格会被 Excel 当作公式，
+// 前面加 ' 按文本显示；负数等纯数字保持原样
+func csvSafe(v string) string {
+	if v == "" || !strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return v
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return v
+	}
+	return "'" + v
+}
+
+// WriteNDJSON 每行输出一个 JSON 对象，键为所选列
+func WriteNDJSON(w io.Writer, t *Table) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, row := range t.Rows {
+		obj := make(map[string]interface{}, len(t.Columns))
+		for _, c := range t.Columns {
+			obj[c.Key] = row[c.Key]
+		}
+		if err := enc.Encode(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+)
+
+// WriteXLSX 输出单工作表的 xlsx 文件（纯 Go 实现，使用内联字符串）
+func WriteXLSX(w io.Writer, t *Table) error {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if err := writeSheet(f, t); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeSheet(w io.Writer, t *Table) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	bw.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c.Title
+	}
+	writeRow(bw, 1, header)
+
+	values := make([]interface{}, len(t.Columns))
+	for r, row := range t.Rows {
+		for i, c := range t.Columns {
+			values[i] = row[c.Key]
+		}
+		writeRow(bw, r+2, values)
+	}
+
+	bw.WriteString(`</sheetData></worksheet>`)
+	return bw.Flush()
+}
+
+func writeRow(bw *bufio.Writer, rowNum int, values []interface{}) {
+	fmt.Fprintf(bw, `<row r="%d">`, rowNum)
+	for i, v := range values {
+		ref := columnName(i) + strconv.Itoa(rowNum)
+		// 超过 15 位的数字（如单号）按文本写入，避免 Excel 丢失精度
+		if n, ok := v.(float64); ok && math.Abs(n) < 1e15 {
+			fmt.Fprintf(bw, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(n, 'f', -1, 64))
+			continue
+		}
+		s := FormatValue(v)
+		if s == "" {
+			continue
+		}
+		fmt.Fprintf(bw, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		xml.EscapeText(bw, []byte(s))
+		bw.WriteString(`</t></is></c>`)
+	}
+	bw.WriteString(`</row>`)
+}
+
+// columnName 将从 0 开始的列序号转换为 A、B、...、AA 形式
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
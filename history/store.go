@@ -0,0 +1,308 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dayLayout = "2006-01-02"
+
+// Record 一次代理请求的记录
+type Record struct {
+	ID           string    `json:"id"`
+	RequestID    string    `json:"requestId,omitempty"` // 调用方的 X-Request-ID
+	Time         time.Time `json:"time"`
+	Method       string    `json:"method"`
+	URL          string    `json:"url"`
+	Caller       string    `json:"caller"`  // 发起调用的 API Key 名称
+	Account      string    `json:"account"` // 使用的中通账号
+	StatusCode   int       `json:"statusCode"`
+	Duration     int64     `json:"duration"`     // 毫秒
+	RequestSize  int64     `json:"requestSize"`  // 上游请求体字节数
+	ResponseSize int64     `json:"responseSize"` // 上游响应体字节数
+	Cache        string    `json:"cache,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Query 查询条件，零值字段不参与过滤
+type Query struct {
+	From      time.Time
+	To        time.Time
+	Status    string // 200 / 4xx / 5xx / error (>=400)
+	Path      string // URL 路径包含
+	Text      string // URL、调用方、账号、错误信息包含（不区分大小写）
+	Caller    string
+	Account   string
+	RequestID string
+	Page      int // 从 1 开始
+	Size      int
+}
+
+// Result 分页查询结果，按时间倒序
+type Result struct {
+	Total   int      `json:"total"`
+	Errors  int      `json:"errors"` // 命中记录中的失败数 (状态码 >=400 或无响应)
+	Page    int      `json:"page"`
+	Size    int      `json:"size"`
+	Records []Record `json:"records"`
+}
+
+// Store 按天分文件的 JSON Lines 请求历史存储
+type Store struct {
+	mu            sync.Mutex
+	dir           string
+	RetentionDays int   // 保留天数，0 表示不限
+	MaxBytes      int64 // 总大小上限，0 表示不限
+	lastPrune     time.Time
+	seq           int64
+}
+
+// Open 打开历史存储目录
+func Open(dir string, retentionDays int, maxBytes int64) *Store {
+	return &Store{dir: dir, RetentionDays: retentionDays, MaxBytes: maxBytes}
+}
+
+// Add 追加一条记录
+func (s *Store) Add(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	if rec.ID == "" {
+		s.seq++
+		rec.ID = fmt.Sprintf("%d-%d", rec.Time.UnixNano(), s.seq)
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.file(rec.Time), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	f.Close()
+
+	// 每小时清理一次过期文件
+	if time.Since(s.lastPrune) > time.Hour {
+		s.lastPrune = time.Now()
+		s.prune()
+	}
+	return err
+}
+
+func (s *Store) file(t time.Time) string {
+	return filepath.Join(s.dir, t.Format(dayLayout)+".jsonl")
+}
+
+// days 返回已有的日期文件，按日期倒序
+func (s *Store) days() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var days []string
+	for _, e := range entries {
+		name := e.Name()
+		if day, ok := strings.CutSuffix(name, ".jsonl"); ok {
+			if _, err := time.Parse(dayLayout, day); err == nil {
+				days = append(days, day)
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+	return days
+}
+
+// Query 按条件查询记录
+func (s *Store) Query(q Query) (*Result, error) {
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.Size <= 0 {
+		q.Size = 50
+	}
+	text := strings.ToLower(q.Text)
+	start := (q.Page - 1) * q.Size
+
+	s.mu.Lock()
+	days := s.days()
+	s.mu.Unlock()
+
+	res := &Result{Page: q.Page, Size: q.Size, Records: []Record{}}
+	for _, day := range days {
+		if !q.From.IsZero() && day < q.From.Format(dayLayout) {
+			break
+		}
+		if !q.To.IsZero() && day > q.To.Format(dayLayout) {
+			continue
+		}
+
+		records, err := s.readDay(day)
+		if err != nil {
+			return nil, err
+		}
+		// 文件内按时间顺序追加，倒序遍历得到最新记录在前
+		for i := len(records) - 1; i >= 0; i-- {
+			if !q.match(&records[i], text) {
+				continue
+			}
+			if res.Total >= start && len(res.Records) < q.Size {
+				res.Records = append(res.Records, records[i])
+			}
+			res.Total++
+			if matchStatus(records[i].StatusCode, "error") {
+				res.Errors++
+			}
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) readDay(day string) ([]Record, error) {
+	f, err := os.Open(filepath.Join(s.dir, day+".jsonl"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if json.Unmarshal(scanner.Bytes(), &rec) == nil {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
+
+func (q *Query) match(r *Record, text string) bool {
+	if !q.From.IsZero() && r.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && r.Time.After(q.To) {
+		return false
+	}
+	if q.Caller != "" && r.Caller != q.Caller {
+		return false
+	}
+	if q.Account != "" && r.Account != q.Account {
+		return false
+	}
+	if q.RequestID != "" && r.RequestID != q.RequestID {
+		return false
+	}
+	if q.Status != "" && !matchStatus(r.StatusCode, q.Status) {
+		return false
+	}
+	if q.Path != "" && !strings.Contains(urlPath(r.URL), q.Path) {
+		return false
+	}
+	if text != "" {
+		hay := strings.ToLower(r.URL + " " + r.Caller + " " + r.Account + " " + r.Error)
+		if !strings.Contains(hay, text) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchStatus 支持精确状态码、2xx/4xx 形式的区间以及 error (>=400)
+func matchStatus(code int, status string) bool {
+	switch {
+	case status == "error":
+		return code >= 400 || code == 0
+	case len(status) == 3 && strings.HasSuffix(strings.ToLower(status), "xx"):
+		return strconv.Itoa(code/100) == status[:1]
+	default:
+		n, err := strconv.Atoi(status)
+		return err == nil && n == code
+	}
+}
+
+func urlPath(u string) string {
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+		if j := strings.IndexByte(u, '/'); j >= 0 {
+			u = u[j:]
+		} else {
+			u = "/"
+		}
+	}
+	if i := strings.IndexByte(u, '?'); i >= 0 {
+		u = u[:i]
+	}
+	return u
+}
+
+// Clear 删除全部历史记录
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, day := range s.days() {
+		if err := os.Remove(filepath.Join(s.dir, day+".jsonl")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune 按保留天数与总大小清理旧文件
+func (s *Store) Prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+}
+
+func (s *Store) prune() {
+	days := s.days()
+	if s.RetentionDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -s.RetentionDays).Format(dayLayout)
+		kept := days[:0]
+		for _, day := range days {
+			if day < cutoff {
+				os.Remove(filepath.Join(s.dir, day+".jsonl"))
+				continue
+			}
+			kept = append(kept, day)
+		}
+		days = kept
+	}
+
+	if s.MaxBytes <= 0 {
+		return
+	}
+	var total int64
+	for _, day := range days {
+		if info, err := os.Stat(filepath.Join(s.dir, day+".jsonl")); err == nil {
+			total += info.Size()
+		}
+	}
+	// 从最旧的文件开始删除，至少保留当天
+	for i := len(days) - 1; i > 0 && total > s.MaxBytes; i-- {
+		path := filepath.Join(s.dir, days[i]+".jsonl")
+		if info, err := os.Stat(path); err == nil {
+			total -= info.Size()
+		}
+		os.Remove(path)
+	}
+}
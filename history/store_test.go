@@ -0,0 +1,101 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_Query(t *testing.T) {
+	s := Open(t.TempDir(), 0, 0)
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+
+	records := []Record{
+		{Time: yesterday, Method: "POST", URL: "https://a.zt-express.com/preOrderQuery/getTodoCenterList", Caller: "dash", StatusCode: 200},
+		{Time: now.Add(-time.Minute), Method: "POST", URL: "https://a.zt-express.com/opsApi/report?x=1", Caller: "ops", Account: "b", StatusCode: 503, Error: "队列已满"},
+		{Time: now, Method: "GET", URL: "https://a.zt-express.com/preOrderQuery/getTodoCenterList", Caller: "dash", Account: "a", StatusCode: 200},
+	}
+	for _, r := range records {
+		if err := s.Add(r); err != nil {
+			t.Fatalf("写入失败: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name string
+		q    Query
+		want int
+	}{
+		{"全部", Query{}, 3},
+		{"时间范围", Query{From: now.Add(-time.Hour)}, 2},
+		{"状态区间", Query{Status: "5xx"}, 1},
+		{"失败", Query{Status: "error"}, 1},
+		{"精确状态", Query{Status: "200"}, 2},
+		{"路径", Query{Path: "/preOrderQuery/"}, 2},
+		{"路径不含查询参数", Query{Path: "x=1"}, 0},
+		{"文本", Query{Text: "队列"}, 1},
+		{"调用方", Query{Caller: "dash"}, 2},
+		{"账号", Query{Account: "a"}, 1},
+	}
+	for _, c := range cases {
+		res, err := s.Query(c.q)
+		if err != nil {
+			t.Fatalf("%s: 查询失败: %v", c.name, err)
+		}
+		if res.Total != c.want {
+			t.Errorf("%s: 期望 %d 条, 实际 %d", c.name, c.want, res.Total)
+		}
+	}
+
+	res, _ := s.Query(Query{Size: 1, Page: 2})
+	if res.Total != 3 || len(res.Records) != 1 || res.Records[0].Caller != "ops" {
+		t.Errorf("分页结果应按时间倒序: %+v", res)
+	}
+	if res.Errors != 1 {
+		t.Errorf("失败数应统计所有命中记录, 实际 %d", res.Errors)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	dir := t.TempDir()
+	s := Open(dir, 7, 0)
+
+	old := time.Now().AddDate(0, 0, -10)
+	s.Add(Record{Time: old, URL: "https://a/old"})
+	s.Add(Record{Time: time.Now(), URL: "https://a/new"})
+	s.Prune()
+
+	if _, err := os.Stat(filepath.Join(dir, old.Format(dayLayout)+".jsonl")); !os.IsNotExist(err) {
+		t.Error("超过保留天数的文件应被删除")
+	}
+	if res, _ := s.Query(Query{}); res.Total != 1 {
+		t.Errorf("期望保留 1 条, 实际 %d", res.Total)
+	}
+}
+
+func TestStore_PruneSize(t *testing.T) {
+	dir := t.TempDir()
+	s := Open(dir, 0, 1)
+
+	for i := 3; i >= 0; i-- {
+		s.Add(Record{Time: time.Now().AddDate(0, 0, -i), URL: "https://a/x"})
+	}
+	s.Prune()
+
+	if res, _ := s.Query(Query{}); res.Total != 1 {
+		t.Errorf("超出大小上限时应只保留当天, 实际 %d 条", res.Total)
+	}
+}
+
+func TestStore_Clear(t *testing.T) {
+	s := Open(t.TempDir(), 0, 0)
+	s.Add(Record{URL: "https://a/x"})
+	if err := s.Clear(); err != nil {
+		t.Fatalf("清空失败: %v", err)
+	}
+	if res, _ := s.Query(Query{}); res.Total != 0 {
+		t.Errorf("清空后应无记录, 实际 %d", res.Total)
+	}
+}
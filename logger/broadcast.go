@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry 一条已输出的日志，供实时订阅
+type Entry struct {
+	ID       uint64                 `json:"id"`
+	Time     time.Time              `json:"time"`
+	Level    string                 `json:"level"`
+	Category string                 `json:"category"`
+	Message  string                 `json:"message"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+	Text     string                 `json:"text"` // 与文本日志相同的整行内容
+}
+
+// Broadcaster 把日志分发给订阅者，并保留最近的若干条用于断线续传
+type Broadcaster struct {
+	mu     sync.Mutex
+	nextID uint64
+	ring   []Entry
+	size   int
+	subs   map[chan Entry]struct{}
+}
+
+// subscriberBuffer 订阅者缓冲，写满时断开该订阅者，由客户端携带最后的 ID 重连补齐
+const subscriberBuffer = 256
+
+// NewBroadcaster 创建广播器，size 为保留的最近日志条数
+func NewBroadcaster(size int) *Broadcaster {
+	return &Broadcaster{size: size, subs: make(map[chan Entry]struct{})}
+}
+
+// Publish 分配 ID 并分发日志
+func (b *Broadcaster) Publish(e Entry) Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	b.ring = append(b.ring, e)
+	if len(b.ring) > b.size {
+		b.ring = b.ring[len(b.ring)-b.size:]
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return e
+}
+
+// Subscribe 订阅新日志，同时返回 ID 大于 lastID 的已保留日志。
+// lastID 为 0 时返回最近 tail 条。订阅者处理过慢时通道会被关闭
+func (b *Broadcaster) Subscribe(lastID uint64, tail int) ([]Entry, <-chan Entry, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Entry
+	if lastID > 0 {
+		for _, e := range b.ring {
+			if e.ID > lastID {
+				backlog = append(backlog, e)
+			}
+		}
+	} else if tail > 0 {
+		start := len(b.ring) - tail
+		if start < 0 {
+			start = 0
+		}
+		backlog = append(backlog, b.ring[start:]...)
+	}
+
+	ch := make(chan Entry, subscriberBuffer)
+	b.subs[ch] = struct{}{}
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return backlog, ch, cancel
+}
+
+// Recent 返回最近 n 条日志
+func (b *Broadcaster) Recent(n int) []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	start := len(b.ring) - n
+	if start < 0 {
+		start = 0
+	}
+	return append([]Entry(nil), b.ring[start:]...)
+}
+
+// Recent 返回默认日志器最近 n 条日志
+func Recent(n int) []Entry {
+	if defaultLogger == nil {
+		return nil
+	}
+	return defaultLogger.hub.Recent(n)
+}
+
+// Subscribe 订阅默认日志器的实时日志，日志系统未初始化时返回 nil
+func Subscribe(lastID uint64, tail int) ([]Entry, <-chan Entry, func()) {
+	if defaultLogger == nil {
+		return nil, nil, func() {}
+	}
+	return defaultLogger.hub.Subscribe(lastID, tail)
+}
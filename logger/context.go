@@ -0,0 +1,22 @@
+package logger
+
+import "context"
+
+// RequestIDKey 结构化日志中的请求 ID 字段
+const RequestIDKey = "request_id"
+
+type requestIDKey struct{}
+
+// WithRequestID 在 ctx 中携带请求 ID，使用该 ctx 的 *Context 日志方法会自动附带此字段
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回 ctx 中的请求 ID
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -1,11 +1,12 @@
 package logger
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -27,16 +28,88 @@ var levelNames = map[LogLevel]string{
 	ERROR: "ERROR",
 }
 
-// Logger 日志记录器
+func (l LogLevel) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// ParseLevel 解析级别名称，不区分大小写
+func ParseLevel(s string) (LogLevel, error) {
+	for level, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	return INFO, fmt.Errorf("未知的日志级别: %s", s)
+}
+
+func (l LogLevel) slogLevel() slog.Level {
+	switch l {
+	case DEBUG:
+		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+func fromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARN
+	}
+	return ERROR
+}
+
+// 日志分类，可分别设置级别
+const (
+	CategoryApp    = "APP"
+	CategoryToken  = "TOKEN"
+	CategoryAPI    = "API"
+	CategoryHTTP   = "HTTP"
+	CategoryChrome = "Chrome"
+)
+
+// Categories 全部日志分类
+var Categories = []string{CategoryApp, CategoryToken, CategoryAPI, CategoryHTTP, CategoryChrome}
+
+// categoryKey 结构化日志中的分类字段
+const categoryKey = "category"
+
+// Logger 日志记录器。控制台与 service_<日期>.log 输出便于阅读的文本，
+// service_<日期>.jsonl 输出每行一条 JSON，包含全部结构化字段
 type Logger struct {
 	level      LogLevel
+	levels     map[string]LogLevel // 分类 -> 级别，未设置时使用 level
 	file       *os.File
-	logger     *log.Logger
+	jsonFile   *os.File
+	size       int64 // 当前文本文件字节数
+	jsonSize   int64 // 当前 JSON 文件字节数
+	slog       *slog.Logger
+	hub        *Broadcaster
 	mu         sync.Mutex
 	logDir     string
 	currentDay string
+
+	// 滚动与保留策略，见 SetRetention
+	maxFileBytes  int64
+	retentionDays int
+	maxTotalBytes int64
+	archiveMu     sync.Mutex     // 串行执行压缩与清理
+	archiving     sync.WaitGroup // 后台压缩任务
 }
 
+// recentEntries 内存中保留的最近日志条数
+const recentEntries = 1000
+
 var (
 	defaultLogger *Logger
 	once          sync.Once
@@ -59,102 +132,366 @@ func NewLogger(logDir string, level LogLevel) (*Logger, error) {
 
 	l := &Logger{
 		level:  level,
+		levels: make(map[string]LogLevel),
+		hub:    NewBroadcaster(recentEntries),
 		logDir: logDir,
 	}
 
 	if err := l.rotateIfNeeded(); err != nil {
 		return nil, err
 	}
+	l.slog = slog.New(&handler{l: l, json: slog.NewJSONHandler(jsonWriter{l}, &slog.HandlerOptions{Level: slog.LevelDebug})})
 
 	return l, nil
 }
 
+// rotateIfNeeded 跨天或文件超过大小上限时归档当前文件并打开新文件
 func (l *Logger) rotateIfNeeded() error {
-	today := time.Now().Format("2006-01-02")
-	if l.currentDay == today && l.file != nil {
-		return nil
-	}
+	today := time.Now().Format(dayLayout)
 
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.file != nil && l.currentDay == today && (l.maxFileBytes <= 0 || max(l.size, l.jsonSize) < l.maxFileBytes) {
+		return nil
+	}
+
 	if l.file != nil {
 		l.file.Close()
+		l.jsonFile.Close()
+		l.archive(l.currentDay)
+	} else {
+		l.archiveStale(today)
 	}
 
-	logPath := filepath.Join(l.logDir, fmt.Sprintf("service_%s.log", today))
-	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	file, err := os.OpenFile(activePath(l.logDir, today, false), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	jsonFile, err := os.OpenFile(activePath(l.logDir, today, true), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
+		file.Close()
 		return err
 	}
 
 	l.file = file
+	l.jsonFile = jsonFile
 	l.currentDay = today
+	l.size, l.jsonSize = 0, 0
+	if info, err := file.Stat(); err == nil {
+		l.size = info.Size()
+	}
+	if info, err := jsonFile.Stat(); err == nil {
+		l.jsonSize = info.Size()
+	}
+
+	return nil
+}
+
+// jsonWriter 写入当天的 JSON 日志文件
+type jsonWriter struct {
+	l *Logger
+}
+
+func (w jsonWriter) Write(p []byte) (int, error) {
+	w.l.mu.Lock()
+	defer w.l.mu.Unlock()
+	n, err := w.l.jsonFile.Write(p)
+	w.l.jsonSize += int64(n)
+	return n, err
+}
+
+// levelFor 返回分类的生效级别
+func (l *Logger) levelFor(category string) LogLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level, ok := l.levels[category]; ok {
+		return level
+	}
+	return l.level
+}
+
+// minLevel 所有分类中最低的级别，用于快速过滤
+func (l *Logger) minLevel() LogLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	min := l.level
+	for _, level := range l.levels {
+		if level < min {
+			min = level
+		}
+	}
+	return min
+}
+
+// handler 按分类级别过滤，同时写文本与 JSON
+type handler struct {
+	l        *Logger
+	json     slog.Handler
+	attrs    []slog.Attr
+	group    string // 文本输出中字段名的前缀
+	category string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	if h.category != "" {
+		return fromSlogLevel(level) >= h.l.levelFor(h.category)
+	}
+	return fromSlogLevel(level) >= h.l.minLevel()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	category := h.category
+	var fields []slog.Attr
+	for _, a := range h.attrs {
+		fields = flatten(fields, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == categoryKey && h.group == "" {
+			category = a.Value.String()
+			return true
+		}
+		fields = flatten(fields, h.group, a)
+		return true
+	})
+	if category == "" {
+		category = CategoryApp
+		r.AddAttrs(slog.String(categoryKey, category))
+	}
+	if id := RequestID(ctx); id != "" && !hasField(fields, RequestIDKey) {
+		fields = append(fields, slog.String(RequestIDKey, id))
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	level := fromSlogLevel(r.Level)
+	if level < h.l.levelFor(category) {
+		return nil
+	}
+
+	h.l.rotateIfNeeded()
+
+	entry := Entry{Time: r.Time, Level: level.String(), Category: category, Message: r.Message}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] [%s] ", r.Time.Format("2006-01-02 15:04:05"), level)
+	if category != CategoryApp {
+		fmt.Fprintf(&b, "[%s] ", category)
+	}
+	b.WriteString(r.Message)
+	for _, f := range fields {
+		value := f.Value.String()
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		b.WriteString(" " + f.Key + "=" + value)
+		if entry.Fields == nil {
+			entry.Fields = make(map[string]interface{}, len(fields))
+		}
+		if err, ok := f.Value.Any().(error); ok {
+			entry.Fields[f.Key] = err.Error()
+		} else {
+			entry.Fields[f.Key] = f.Value.Any()
+		}
+	}
+	entry.Text = b.String()
 
 	// 同时输出到控制台和文件
-	multiWriter := io.MultiWriter(os.Stdout, file)
-	l.logger = log.New(multiWriter, "", 0)
+	h.l.mu.Lock()
+	os.Stdout.WriteString(entry.Text + "\n")
+	n, _ := h.l.file.WriteString(entry.Text + "\n")
+	h.l.size += int64(n)
+	h.l.mu.Unlock()
+	h.l.hub.Publish(entry)
 
-	return nil
+	return h.json.Handle(ctx, r)
+}
+
+func hasField(fields []slog.Attr, key string) bool {
+	for _, f := range fields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten 展开分组字段，字段名以 "." 连接
+func flatten(fields []slog.Attr, prefix string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			fields = flatten(fields, key, ga)
+		}
+		return fields
+	}
+	return append(fields, slog.Attr{Key: key, Value: a.Value})
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = append([]slog.Attr(nil), h.attrs...)
+	var rest []slog.Attr
+	for _, a := range attrs {
+		if a.Key == categoryKey && h.group == "" {
+			next.category = a.Value.String()
+		} else if h.group != "" {
+			next.attrs = append(next.attrs, slog.Group(h.group, a))
+		} else {
+			next.attrs = append(next.attrs, a)
+		}
+		rest = append(rest, a)
+	}
+	next.json = h.json.WithAttrs(rest)
+	return &next
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	if h.group != "" {
+		name = h.group + "." + name
+	}
+	next.group = name
+	next.json = h.json.WithGroup(name)
+	return &next
 }
 
-func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
-	if level < l.level {
+func (l *Logger) logf(level LogLevel, category, format string, args ...interface{}) {
+	if level < l.levelFor(category) {
 		return
 	}
+	l.slog.Log(context.Background(), level.slogLevel(), fmt.Sprintf(format, args...), categoryKey, category)
+}
 
-	l.rotateIfNeeded()
+// With 返回指定分类的结构化日志记录器，args 为附加字段 (如 "account", name)
+func With(category string, args ...any) *slog.Logger {
+	if defaultLogger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return defaultLogger.slog.With(append([]any{categoryKey, category}, args...)...)
+}
 
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	prefix := fmt.Sprintf("[%s] [%s] ", timestamp, levelNames[level])
-	message := fmt.Sprintf(format, args...)
+// SetLevel 设置分类的日志级别，category 为空时设置默认级别
+func SetLevel(category string, level LogLevel) {
+	if defaultLogger == nil {
+		return
+	}
+	defaultLogger.mu.Lock()
+	defer defaultLogger.mu.Unlock()
+	if category == "" {
+		defaultLogger.level = level
+	} else {
+		defaultLogger.levels[category] = level
+	}
+}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.logger.Println(prefix + message)
+// ResetLevel 移除分类的单独设置，恢复使用默认级别
+func ResetLevel(category string) {
+	if defaultLogger == nil {
+		return
+	}
+	defaultLogger.mu.Lock()
+	defer defaultLogger.mu.Unlock()
+	delete(defaultLogger.levels, category)
+}
+
+// Configure 按配置设置默认级别与各分类级别
+func Configure(level string, categories map[string]string) error {
+	if level != "" {
+		lv, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		SetLevel("", lv)
+	}
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lv, err := ParseLevel(categories[name])
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		SetLevel(name, lv)
+	}
+	return nil
+}
+
+// Levels 返回默认级别与各分类当前生效的级别
+func Levels() (string, map[string]string) {
+	levels := make(map[string]string)
+	if defaultLogger == nil {
+		return INFO.String(), levels
+	}
+	for _, c := range Categories {
+		levels[c] = defaultLogger.levelFor(c).String()
+	}
+	defaultLogger.mu.Lock()
+	defer defaultLogger.mu.Unlock()
+	for c, lv := range defaultLogger.levels {
+		levels[c] = lv.String()
+	}
+	return defaultLogger.level.String(), levels
 }
 
 // Debug 调试日志
 func Debug(format string, args ...interface{}) {
 	if defaultLogger != nil {
-		defaultLogger.log(DEBUG, format, args...)
+		defaultLogger.logf(DEBUG, CategoryApp, format, args...)
 	}
 }
 
 // Info 信息日志
 func Info(format string, args ...interface{}) {
 	if defaultLogger != nil {
-		defaultLogger.log(INFO, format, args...)
+		defaultLogger.logf(INFO, CategoryApp, format, args...)
 	}
 }
 
 // Warn 警告日志
 func Warn(format string, args ...interface{}) {
 	if defaultLogger != nil {
-		defaultLogger.log(WARN, format, args...)
+		defaultLogger.logf(WARN, CategoryApp, format, args...)
 	}
 }
 
 // Error 错误日志
 func Error(format string, args ...interface{}) {
 	if defaultLogger != nil {
-		defaultLogger.log(ERROR, format, args...)
+		defaultLogger.logf(ERROR, CategoryApp, format, args...)
 	}
 }
 
 // Token Token 相关日志
 func Token(format string, args ...interface{}) {
-	Info("[TOKEN] "+format, args...)
+	if defaultLogger != nil {
+		defaultLogger.logf(INFO, CategoryToken, format, args...)
+	}
 }
 
 // API API 相关日志
 func API(format string, args ...interface{}) {
-	Info("[API] "+format, args...)
+	if defaultLogger != nil {
+		defaultLogger.logf(INFO, CategoryAPI, format, args...)
+	}
 }
 
-// Close 关闭日志器
+// Close 关闭日志器，等待后台压缩完成
 func Close() {
 	if defaultLogger != nil && defaultLogger.file != nil {
+		defaultLogger.mu.Lock()
 		defaultLogger.file.Close()
+		defaultLogger.jsonFile.Close()
+		defaultLogger.mu.Unlock()
+		defaultLogger.archiving.Wait()
 	}
 }
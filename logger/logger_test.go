@@ -0,0 +1,268 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTempLogger 使用临时目录中的日志器，返回当天文本与 JSON 日志路径
+func useTempLogger(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	l, err := NewLogger(dir, INFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := defaultLogger
+	defaultLogger = l
+	t.Cleanup(func() {
+		l.archiving.Wait()
+		l.file.Close()
+		l.jsonFile.Close()
+		defaultLogger = old
+	})
+	day := time.Now().Format("2006-01-02")
+	return filepath.Join(dir, "service_"+day+".log"), filepath.Join(dir, "service_"+day+".jsonl")
+}
+
+func readJSONLines(t *testing.T, path string) []map[string]interface{} {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("JSON 日志格式不正确: %s", line)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestStructuredOutput(t *testing.T) {
+	textPath, jsonPath := useTempLogger(t)
+
+	Info("服务启动: %d", 8765)
+	With(CategoryAPI, "account", "site2", "host", "a.zt-express.com").Info("上游请求完成", "status", 200, "duration_ms", 35)
+
+	text, _ := os.ReadFile(textPath)
+	if !strings.Contains(string(text), "[INFO] 服务启动: 8765\n") ||
+		!strings.Contains(string(text), "[INFO] [API] 上游请求完成 account=site2 host=a.zt-express.com status=200 duration_ms=35") {
+		t.Errorf("文本日志格式不正确:\n%s", text)
+	}
+
+	entries := readJSONLines(t, jsonPath)
+	if len(entries) != 2 {
+		t.Fatalf("应写入 2 条 JSON 日志, 实际 %d", len(entries))
+	}
+	if entries[0]["category"] != CategoryApp || entries[0]["msg"] != "服务启动: 8765" {
+		t.Errorf("printf 日志应带默认分类: %v", entries[0])
+	}
+	e := entries[1]
+	if e["category"] != CategoryAPI || e["account"] != "site2" || e["status"] != float64(200) || e["level"] != "INFO" {
+		t.Errorf("结构化字段不正确: %v", e)
+	}
+}
+
+func TestCategoryLevels(t *testing.T) {
+	textPath, _ := useTempLogger(t)
+
+	With(CategoryHTTP).Debug("默认级别下不应输出")
+	SetLevel(CategoryHTTP, DEBUG)
+	With(CategoryHTTP).Debug("单独开启 HTTP 调试", "path", "/status")
+	Debug("其他分类仍不输出调试日志")
+	SetLevel(CategoryToken, ERROR)
+	Token("TOKEN 分类提高级别后不输出")
+
+	text, _ := os.ReadFile(textPath)
+	s := string(text)
+	if strings.Contains(s, "默认级别下不应输出") || strings.Contains(s, "其他分类") || strings.Contains(s, "TOKEN 分类") {
+		t.Errorf("不应输出低于分类级别的日志:\n%s", s)
+	}
+	if !strings.Contains(s, "[DEBUG] [HTTP] 单独开启 HTTP 调试 path=/status") {
+		t.Errorf("应输出已开启调试的分类:\n%s", s)
+	}
+
+	def, levels := Levels()
+	if def != "INFO" || levels[CategoryHTTP] != "DEBUG" || levels[CategoryToken] != "ERROR" || levels[CategoryAPI] != "INFO" {
+		t.Errorf("级别列表不正确: %s %v", def, levels)
+	}
+
+	ResetLevel(CategoryHTTP)
+	if _, levels := Levels(); levels[CategoryHTTP] != "INFO" {
+		t.Errorf("恢复后应使用默认级别: %v", levels)
+	}
+
+	if err := Configure("warn", map[string]string{CategoryAPI: "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if def, levels := Levels(); def != "WARN" || levels[CategoryAPI] != "DEBUG" {
+		t.Errorf("配置级别不正确: %s %v", def, levels)
+	}
+	if err := Configure("verbose", nil); err == nil {
+		t.Error("未知级别应返回错误")
+	}
+}
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster(3)
+	for i := 0; i < 5; i++ {
+		b.Publish(Entry{Message: fmt.Sprint(i)})
+	}
+
+	backlog, ch, cancel := b.Subscribe(3, 0)
+	defer cancel()
+	if len(backlog) != 2 || backlog[0].ID != 4 || backlog[1].Message != "4" {
+		t.Errorf("应补发 Last-Event-ID 之后的日志: %+v", backlog)
+	}
+	if tail, _, c := b.Subscribe(0, 2); len(tail) != 2 || tail[1].ID != 5 {
+		t.Errorf("首次连接应返回最近 tail 条: %+v", tail)
+	} else {
+		c()
+	}
+
+	b.Publish(Entry{Message: "new"})
+	if e := <-ch; e.ID != 6 || e.Message != "new" {
+		t.Errorf("订阅者应收到新日志: %+v", e)
+	}
+
+	// 订阅者不读取时写满缓冲后被断开
+	for i := 0; i < subscriberBuffer+1; i++ {
+		b.Publish(Entry{})
+	}
+	for range ch {
+	}
+	if len(b.subs) != 0 {
+		t.Error("处理过慢的订阅者应被移除")
+	}
+}
+
+func TestPublishEntries(t *testing.T) {
+	useTempLogger(t)
+	_, ch, cancel := Subscribe(0, 0)
+	defer cancel()
+
+	With(CategoryToken, "account", "site2").Warn("刷新失败", "error", fmt.Errorf("宝盒未运行"))
+	e := <-ch
+	if e.Level != "WARN" || e.Category != CategoryToken || e.Fields["account"] != "site2" || e.Fields["error"] != "宝盒未运行" ||
+		!strings.HasSuffix(e.Text, "[WARN] [TOKEN] 刷新失败 account=site2 error=宝盒未运行") {
+		t.Errorf("推送的日志不正确: %+v", e)
+	}
+	if recent := Recent(10); len(recent) != 1 || recent[0].ID != e.ID {
+		t.Errorf("最近日志不正确: %+v", recent)
+	}
+}
+
+func TestRotateAndSearch(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "service_2020-01-01.log"),
+		[]byte("[2020-01-01 08:00:00] [INFO] [TOKEN] 旧版日志\n[2020-01-01 08:00:01] [ERROR] 旧的错误\n  堆栈第二行\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "service_2020-01-01.jsonl"), []byte("{}\n"), 0644)
+
+	l, err := NewLogger(dir, INFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := defaultLogger
+	defaultLogger = l
+	defer func() { defaultLogger = old }()
+	l.maxFileBytes = 400
+
+	for i := 0; i < 20; i++ {
+		With(CategoryAPI, "seq", i).Info("上游请求完成")
+	}
+	Warn("队列繁忙")
+	l.archiving.Wait()
+
+	today := time.Now().Format(dayLayout)
+	var parts int
+	for _, f := range listLogFiles(dir) {
+		if f.part > 0 && !f.gz {
+			t.Errorf("归档应压缩: %s", f.name)
+		}
+		if f.day == today && f.part > 0 && !f.json {
+			parts++
+		}
+	}
+	if parts < 2 {
+		t.Errorf("超过大小上限应滚动, 实际归档 %d 个", parts)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "service_2020-01-01.1.log.gz")); err != nil {
+		t.Errorf("启动时应归档之前的文件: %v", err)
+	}
+
+	res, err := Search(dir, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Lines) != 24 || res.Truncated || res.Lines[0].Text != "[2020-01-01 08:00:00] [INFO] [TOKEN] 旧版日志" ||
+		!strings.HasSuffix(res.Lines[3].Text, "seq=0") || !strings.HasSuffix(res.Lines[23].Text, "队列繁忙") {
+		t.Fatalf("应按时间顺序返回全部日志: %d %+v", len(res.Lines), res.Lines)
+	}
+
+	res, _ = Search(dir, Query{Limit: 5, Category: "api"})
+	if len(res.Lines) != 5 || !res.Truncated || !strings.HasSuffix(res.Lines[4].Text, "seq=19") {
+		t.Errorf("超过 Limit 应只返回最近的部分: %+v", res)
+	}
+	res, _ = Search(dir, Query{Level: ERROR, Text: "堆栈"})
+	if len(res.Lines) != 1 || res.Lines[0].Level != "ERROR" {
+		t.Errorf("多行内容应沿用上一行的级别: %+v", res.Lines)
+	}
+	from, _ := time.ParseInLocation(dayLayout, today, time.Local)
+	if res, _ = Search(dir, Query{From: from, Text: "旧"}); len(res.Lines) != 0 {
+		t.Errorf("应按日期范围过滤: %+v", res.Lines)
+	}
+
+	l.retentionDays = 30
+	l.prune()
+	if _, err := os.Stat(filepath.Join(dir, "service_2020-01-01.1.log.gz")); !os.IsNotExist(err) {
+		t.Error("超过保留天数的归档应删除")
+	}
+	l.maxTotalBytes = 1
+	l.prune()
+	for _, f := range listLogFiles(dir) {
+		if f.part > 0 {
+			t.Errorf("超过总大小时应删除归档: %s", f.name)
+		}
+	}
+	if _, err := os.Stat(activePath(dir, today, false)); err != nil {
+		t.Error("正在写入的文件不应删除")
+	}
+	l.file.Close()
+	l.jsonFile.Close()
+}
+
+func TestRequestID(t *testing.T) {
+	textPath, jsonPath := useTempLogger(t)
+	ctx := WithRequestID(context.Background(), "abc")
+
+	With(CategoryAPI, "account", "site2").InfoContext(ctx, "上游请求完成")
+	With(CategoryAPI).InfoContext(WithRequestID(context.Background(), "abcd"), "其他请求")
+	Info("无请求 ID")
+
+	text, _ := os.ReadFile(textPath)
+	if !strings.Contains(string(text), "上游请求完成 account=site2 request_id=abc\n") {
+		t.Errorf("文本日志应带请求 ID:\n%s", text)
+	}
+	if entries := readJSONLines(t, jsonPath); entries[0][RequestIDKey] != "abc" || entries[2][RequestIDKey] != nil {
+		t.Errorf("JSON 日志的请求 ID 不正确: %v", entries)
+	}
+
+	res, err := Search(filepath.Dir(textPath), Query{RequestID: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Lines) != 1 || !strings.Contains(res.Lines[0].Text, "上游请求完成") {
+		t.Errorf("应只返回该请求 ID 的日志: %+v", res.Lines)
+	}
+}
@@ -0,0 +1,260 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 日志文件命名：当天正在写入的为 service_<日期>.log / .jsonl，
+// 超过大小上限或跨天后改名为 service_<日期>.<序号>.log 并压缩为 .gz
+const (
+	filePrefix = "service_"
+	dayLayout  = "2006-01-02"
+)
+
+// logFile 日志目录中的一个文件
+type logFile struct {
+	name string
+	day  string
+	part int // 0 表示正在写入的文件
+	json bool
+	gz   bool
+	size int64
+}
+
+// parseLogFile 解析日志文件名，不是日志文件时返回 false
+func parseLogFile(name string) (logFile, bool) {
+	f := logFile{name: name}
+	rest, ok := strings.CutPrefix(name, filePrefix)
+	if !ok {
+		return f, false
+	}
+	rest, f.gz = strings.CutSuffix(rest, ".gz")
+	if r, ok := strings.CutSuffix(rest, ".jsonl"); ok {
+		rest, f.json = r, true
+	} else if r, ok := strings.CutSuffix(rest, ".log"); ok {
+		rest = r
+	} else {
+		return f, false
+	}
+	day, part, hasPart := strings.Cut(rest, ".")
+	if _, err := time.Parse(dayLayout, day); err != nil {
+		return f, false
+	}
+	f.day = day
+	if hasPart {
+		n, err := strconv.Atoi(part)
+		if err != nil || n <= 0 {
+			return f, false
+		}
+		f.part = n
+	}
+	return f, true
+}
+
+// order 排序键，同一天内正在写入的文件排在最后
+func (f logFile) order() int {
+	if f.part == 0 {
+		return math.MaxInt
+	}
+	return f.part
+}
+
+// listLogFiles 返回日志目录中的文件，按日期与序号从旧到新排列
+func listLogFiles(dir string) []logFile {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []logFile
+	for _, e := range entries {
+		f, ok := parseLogFile(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			f.size = info.Size()
+		}
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].day != files[j].day {
+			return files[i].day < files[j].day
+		}
+		return files[i].order() < files[j].order()
+	})
+	return files
+}
+
+func activePath(dir, day string, json bool) string {
+	if json {
+		return filepath.Join(dir, filePrefix+day+".jsonl")
+	}
+	return filepath.Join(dir, filePrefix+day+".log")
+}
+
+// SetRetention 设置滚动与保留策略：单个文件超过 maxFileBytes 时滚动，
+// 删除 retentionDays 天前的文件，总大小超过 maxTotalBytes 时从最旧的归档开始删除。0 表示不限
+func SetRetention(maxFileBytes int64, retentionDays int, maxTotalBytes int64) {
+	if defaultLogger == nil {
+		return
+	}
+	l := defaultLogger
+	l.mu.Lock()
+	l.maxFileBytes, l.retentionDays, l.maxTotalBytes = maxFileBytes, retentionDays, maxTotalBytes
+	l.mu.Unlock()
+
+	l.archiving.Add(1)
+	go func() {
+		defer l.archiving.Done()
+		l.archiveMu.Lock()
+		defer l.archiveMu.Unlock()
+		l.prune()
+	}()
+}
+
+// archive 把某天正在写入的文件改名为下一个序号，并在后台压缩，调用方持有 l.mu
+func (l *Logger) archive(day string) {
+	part := 0
+	for _, f := range listLogFiles(l.logDir) {
+		if f.day == day && f.part > part {
+			part = f.part
+		}
+	}
+	part++
+
+	var archived []string
+	for _, json := range []bool{false, true} {
+		src := activePath(l.logDir, day, json)
+		ext := ".log"
+		if json {
+			ext = ".jsonl"
+		}
+		dst := filepath.Join(l.logDir, fmt.Sprintf("%s%s.%d%s", filePrefix, day, part, ext))
+		if err := os.Rename(src, dst); err == nil {
+			archived = append(archived, dst)
+		}
+	}
+
+	l.archiving.Add(1)
+	go func() {
+		defer l.archiving.Done()
+		l.archiveMu.Lock()
+		defer l.archiveMu.Unlock()
+		for _, path := range archived {
+			if err := gzipFile(path); err != nil {
+				fmt.Fprintf(os.Stderr, "压缩日志失败: %s: %v\n", path, err)
+			}
+		}
+		l.prune()
+	}()
+}
+
+// archiveStale 启动时归档之前遗留的未归档文件，调用方持有 l.mu
+func (l *Logger) archiveStale(today string) {
+	days := make(map[string]bool)
+	var pending []string
+	for _, f := range listLogFiles(l.logDir) {
+		switch {
+		case f.part == 0 && f.day != today:
+			days[f.day] = true
+		case f.part > 0 && !f.gz:
+			// 上次退出时尚未压缩完成
+			pending = append(pending, filepath.Join(l.logDir, f.name))
+		}
+	}
+	for day := range days {
+		l.archive(day)
+	}
+	if len(pending) > 0 {
+		l.archiving.Add(1)
+		go func() {
+			defer l.archiving.Done()
+			l.archiveMu.Lock()
+			defer l.archiveMu.Unlock()
+			for _, path := range pending {
+				gzipFile(path)
+			}
+		}()
+	}
+}
+
+// gzipFile 压缩为 path.gz 后删除原文件
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	src.Close()
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// prune 按保留天数与总大小删除归档，正在写入的文件不删除，调用方持有 l.archiveMu
+func (l *Logger) prune() {
+	l.mu.Lock()
+	retentionDays, maxTotal := l.retentionDays, l.maxTotalBytes
+	l.mu.Unlock()
+
+	files := listLogFiles(l.logDir)
+	if retentionDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -retentionDays).Format(dayLayout)
+		kept := files[:0]
+		for _, f := range files {
+			if f.day < cutoff && f.part > 0 {
+				os.Remove(filepath.Join(l.logDir, f.name))
+				continue
+			}
+			kept = append(kept, f)
+		}
+		files = kept
+	}
+
+	if maxTotal <= 0 {
+		return
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	for _, f := range files {
+		if total <= maxTotal {
+			break
+		}
+		if f.part == 0 {
+			continue
+		}
+		if os.Remove(filepath.Join(l.logDir, f.name)) == nil {
+			total -= f.size
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Query 日志检索条件，零值字段不参与过滤
+type Query struct {
+	From      time.Time
+	To        time.Time
+	Level     LogLevel // 最低级别
+	Category  string
+	Text      string // 整行包含（不区分大小写）
+	RequestID string // 只返回带有该请求 ID 的行
+	Limit     int    // 返回最近的若干条，默认 200
+}
+
+// Line 检索到的一行日志
+type Line struct {
+	Time     time.Time `json:"time"`
+	Level    string    `json:"level"`
+	Category string    `json:"category"`
+	Text     string    `json:"text"`
+}
+
+// Result 检索结果，按时间顺序排列
+type Result struct {
+	Lines     []Line `json:"lines"`
+	Truncated bool   `json:"truncated"` // 匹配条数超过 Limit，只返回了最近的部分
+}
+
+// Search 从新到旧检索日志目录中的文本日志 (含已压缩的归档)
+func Search(dir string, q Query) (*Result, error) {
+	if q.Limit <= 0 {
+		q.Limit = 200
+	}
+	text := strings.ToLower(q.Text)
+
+	files := listLogFiles(dir)
+	res := &Result{Lines: []Line{}}
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		if f.json {
+			continue
+		}
+		if !q.From.IsZero() && f.day < q.From.Format(dayLayout) {
+			break
+		}
+		if !q.To.IsZero() && f.day > q.To.Format(dayLayout) {
+			continue
+		}
+
+		lines, err := readLogFile(filepath.Join(dir, f.name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				// 检索期间被归档或清理
+				continue
+			}
+			return nil, err
+		}
+		var matched []Line
+		for _, line := range lines {
+			if q.match(line, text) {
+				matched = append(matched, line)
+			}
+		}
+		res.Lines = append(matched, res.Lines...)
+		if len(res.Lines) > q.Limit {
+			res.Lines = res.Lines[len(res.Lines)-q.Limit:]
+			res.Truncated = true
+			break
+		}
+	}
+	return res, nil
+}
+
+func (q *Query) match(line Line, text string) bool {
+	if !q.From.IsZero() && line.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && line.Time.After(q.To) {
+		return false
+	}
+	if level, err := ParseLevel(line.Level); err == nil && level < q.Level {
+		return false
+	}
+	if q.Category != "" && !strings.EqualFold(line.Category, q.Category) {
+		return false
+	}
+	if q.RequestID != "" && !hasRequestID(line.Text, q.RequestID) {
+		return false
+	}
+	return text == "" || strings.Contains(strings.ToLower(line.Text), text)
+}
+
+// hasRequestID 判断行中是否有 request_id=<id> 字段，避免匹配到以 id 开头的其他请求
+func hasRequestID(text, id string) bool {
+	field := " " + RequestIDKey + "=" + id
+	for {
+		i := strings.Index(text, field)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(field):]
+		if text == "" || text[0] == ' ' {
+			return true
+		}
+	}
+}
+
+// readLogFile 读取并解析文本日志，.gz 归档自动解压
+func readLogFile(path string) ([]Line, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var lines []Line
+	prev := Line{Level: INFO.String(), Category: CategoryApp}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		s := scanner.Text()
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		line, ok := parseLine(s)
+		if !ok {
+			// 多行内容的后续行沿用上一行的时间与级别
+			line = Line{Time: prev.Time, Level: prev.Level, Category: prev.Category, Text: s}
+		}
+		lines = append(lines, line)
+		prev = line
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine 解析 "[时间] [级别] [分类] 内容"，分类省略时为 APP
+func parseLine(s string) (Line, bool) {
+	line := Line{Text: s, Category: CategoryApp}
+	ts, rest, ok := cutBracket(s)
+	if !ok {
+		return line, false
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", ts, time.Local)
+	if err != nil {
+		return line, false
+	}
+	level, rest, ok := cutBracket(rest)
+	if !ok {
+		return line, false
+	}
+	if _, err := ParseLevel(level); err != nil {
+		return line, false
+	}
+	line.Time, line.Level = t, level
+	if category, _, ok := cutBracket(rest); ok {
+		for _, c := range Categories {
+			if c == category {
+				line.Category = c
+			}
+		}
+	}
+	return line, true
+}
+
+// cutBracket 取出开头 "[...] " 中的内容
+func cutBracket(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, "[") {
+		return "", s, false
+	}
+	inner, rest, ok := strings.Cut(s[1:], "]")
+	return inner, strings.TrimPrefix(rest, " "), ok
+}
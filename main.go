@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"zto-api-proxy/config"
 	"zto-api-proxy/logger"
 	"zto-api-proxy/proxy"
+	"zto-api-proxy/refresh"
 	"zto-api-proxy/scheduler"
 	"zto-api-proxy/server"
 	"zto-api-proxy/tray"
@@ -25,12 +27,16 @@ var (
 	noTray     bool
 	testMode   bool
 	refreshNow bool
+	rotateKey  bool
+	keyFile    string
 )
 
 func init() {
 	flag.BoolVar(&noTray, "no-tray", false, "禁用系统托盘")
 	flag.BoolVar(&testMode, "test", false, "测试模式（仅检查配置）")
 	flag.BoolVar(&refreshNow, "refresh", false, "立即刷新 Token")
+	flag.BoolVar(&rotateKey, "rotate-key", false, "更换 Token 加密密钥并重新加密")
+	flag.StringVar(&keyFile, "key-file", "", "配合 -rotate-key 使用的口令文件（为空则使用本机绑定密钥）")
 }
 
 func main() {
@@ -55,11 +61,25 @@ func main() {
 		os.Exit(1)
 	}
 	defer logger.Close()
+	logger.SetRetention(int64(cfg.LogMaxSizeMB)<<20, cfg.LogRetentionDays, int64(cfg.LogMaxTotalMB)<<20)
+	if err := logger.Configure(cfg.LogLevel, cfg.LogLevels); err != nil {
+		logger.Warn("日志级别配置无效: %v", err)
+	}
 
 	logger.Info("ZTO API Proxy 启动中...")
 	logger.Info("数据目录: %s", cfg.DataDir)
 	logger.Info("监听端口: %d", cfg.Port)
 
+	// 更换加密密钥
+	if rotateKey {
+		if err := config.RotateSecretKey(keyFile); err != nil {
+			logger.Error("更换密钥失败: %v", err)
+			os.Exit(1)
+		}
+		logger.Info("加密密钥已更换")
+		return
+	}
+
 	// 测试模式
 	if testMode {
 		runTestMode()
@@ -73,18 +93,21 @@ func main() {
 	var srv *server.Server
 
 	// 创建刷新函数
-	refreshFunc := func() error {
-		err := browserInstance.RefreshToken()
+	refreshFunc := func(ctx context.Context, account string, names ...string) error {
+		err := browserInstance.RefreshAccountWith(ctx, account, names...)
 		if err == nil && srv != nil {
 			srv.CheckZBox()
 		}
 		return err
 	}
 
+	// 刷新协调器：合并代理、调度器、托盘和 /refresh 的并发刷新
+	refresher := refresh.NewCoordinator(refreshFunc, time.Duration(cfg.RefreshCooldown)*time.Second)
+
 	// 立即刷新模式
 	if refreshNow {
 		logger.Info("执行立即刷新...")
-		if err := refreshFunc(); err != nil {
+		if err := refresher.RefreshAll(); err != nil {
 			logger.Error("刷新失败: %v", err)
 			os.Exit(1)
 		}
@@ -93,16 +116,19 @@ func main() {
 	}
 
 	// 创建代理客户端
-	proxyClient := proxy.NewClient(refreshFunc)
+	proxyClient := proxy.NewClient(refresher.RefreshAccountContext)
+
+	// 创建服务器
+	srv = server.NewServer(proxyClient, refresher)
+	srv.SetBrowser(browserInstance)
 
 	// 创建调度器
-	sched := scheduler.NewScheduler(refreshFunc)
+	sched := scheduler.NewScheduler()
+	registerJobs(sched, refresher, srv)
+	srv.SetScheduler(sched)
 	sched.Start()
 	defer sched.Stop()
 
-	// 创建服务器
-	srv = server.NewServer(proxyClient, refreshFunc)
-
 	// 停止函数
 	stopFunc := func() {
 		logger.Info("正在停止服务...")
@@ -151,11 +177,54 @@ func main() {
 		select {} // 永久等待
 	} else {
 		// 启动系统托盘（阻塞）
-		t := tray.NewTray(refreshFunc, stopFunc)
+		t := tray.NewTray(refresher.RefreshAll, stopFunc)
 		t.Run()
 	}
 }
 
+// registerJobs 注册内置定时任务，调度表达式从配置实时读取
+func registerJobs(sched *scheduler.Scheduler, refresher *refresh.Coordinator, srv *server.Server) {
+	jobs := []scheduler.Job{
+		{Name: scheduler.JobTokenRefresh, Description: "每日定时刷新 Token", CatchUp: true, Run: refresher.RefreshAll},
+		{Name: scheduler.JobTokenPrevent, Description: "预防型刷新 (wyandyy 10h 周期)", Run: refresher.RefreshAll},
+		{Name: scheduler.JobTokenCheck, Description: "Token 即将失效时提前刷新", Run: scheduler.TokenCheckJob(refresher.RefreshAccount)},
+		{Name: scheduler.JobHistoryCleanup, Description: "清理过期请求历史", CatchUp: true, Run: srv.PruneHistory},
+		{Name: scheduler.JobTodoMetrics, Description: "采样各账号待办数量", Run: srv.CollectTodo},
+		{Name: scheduler.JobAlertCheck, Description: "检查告警规则并发送通知", Run: srv.CheckAlerts},
+		{Name: scheduler.JobTokenProbe, Description: "探测登录状态是否已被服务端注销", Run: srv.ProbeTokens},
+	}
+	for _, job := range jobs {
+		name := job.Name
+		job.SpecFunc = func() string { return config.JobSpec(name) }
+		if err := sched.AddJob(job); err != nil {
+			logger.Error("注册定时任务失败: %v", err)
+		}
+	}
+
+	// 数据采集任务，从配置中删除后视为禁用
+	for _, snap := range config.GetConfig().SnapshotJobs {
+		name := snap.Name
+		err := sched.AddJob(scheduler.Job{
+			Name:        scheduler.JobSnapshotPrefix + name,
+			Description: "数据采集 " + snap.Endpoint,
+			CatchUp:     snap.CatchUp,
+			SpecFunc: func() string {
+				if job, ok := config.GetSnapshotJob(name); ok {
+					return job.Schedule
+				}
+				return "off"
+			},
+			Run: func() error {
+				_, err := srv.RunSnapshot(name)
+				return err
+			},
+		})
+		if err != nil {
+			logger.Error("注册采集任务失败: %v", err)
+		}
+	}
+}
+
 func runTestMode() {
 	fmt.Println("\n[测试模式]")
 
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestExtractCounts(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want map[string]int
+	}{
+		{"列表", `{"status":true,"result":[{"todoName":"待揽收","count":12},{"todoName":"超时","count":"3"}]}`,
+			map[string]int{"待揽收": 12, "超时": 3}},
+		{"对象", `{"status":true,"result":{"waitPick":5,"overtime":2,"desc":"x"}}`,
+			map[string]int{"waitPick": 5, "overtime": 2}},
+		{"空", `{"status":true,"result":[]}`, map[string]int{}},
+	}
+	for _, c := range cases {
+		if got := ExtractCounts(decode(t, c.data)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: 期望 %v, 实际 %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestDiffSamples(t *testing.T) {
+	t0 := time.Date(2025, 12, 3, 8, 0, 0, 0, time.Local)
+	prev := NewSample("default", "S1", map[string]int{"a": 5, "b": 2, "c": 1}, t0)
+	cur := NewSample("default", "S1", map[string]int{"a": 9, "b": 2, "d": 1}, t0.Add(5*time.Minute))
+
+	d := DiffSamples(prev, cur)
+	if d.Total.Before != 8 || d.Total.After != 12 || d.Total.Delta != 4 {
+		t.Errorf("合计变化不正确: %+v", d.Total)
+	}
+	want := []Change{
+		{Key: "a", Before: 5, After: 9, Delta: 4},
+		{Key: "c", Before: 1, After: 0, Delta: -1},
+		{Key: "d", Before: 0, After: 1, Delta: 1},
+	}
+	if !reflect.DeepEqual(d.Changes, want) {
+		t.Errorf("期望 %+v, 实际 %+v", want, d.Changes)
+	}
+}
+
+func TestStore_RangeAndSeries(t *testing.T) {
+	s := Open(t.TempDir(), 30)
+	base := time.Now().Truncate(time.Hour)
+	for i := 0; i < 6; i++ {
+		s.Add(NewSample("default", "", map[string]int{"a": i}, base.Add(time.Duration(i)*10*time.Minute)))
+		s.Add(NewSample("other", "", map[string]int{"a": 10 * i}, base.Add(time.Duration(i)*10*time.Minute)))
+	}
+
+	all, err := s.Range(base, base.Add(time.Hour), "")
+	if err != nil || len(all) != 12 {
+		t.Fatalf("应返回 12 次采样: %d %v", len(all), err)
+	}
+	one, _ := s.Range(base.Add(15*time.Minute), time.Time{}, "other")
+	if len(one) != 4 || one[0].Total != 20 {
+		t.Errorf("按时间与账号过滤不正确: %+v", one)
+	}
+
+	series := BuildSeries(all, 30*time.Minute)
+	if len(series) != 2 || len(series[0].Points) != 2 {
+		t.Fatalf("应按账号分组并按 30 分钟聚合: %+v", series)
+	}
+	if p := series[0].Points[0]; !p.Time.Equal(base) || p.Total != 2 {
+		t.Errorf("窗口内应取最后一次采样: %+v", p)
+	}
+
+	last, _ := s.Last(2)
+	if len(last["default"]) != 2 || last["default"][1].Total != 5 {
+		t.Errorf("应返回每个账号最近两次采样: %+v", last)
+	}
+}
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dayLayout = "2006-01-02"
+
+// Store 按天分文件的 JSON Lines 采样存储
+type Store struct {
+	mu            sync.Mutex
+	dir           string
+	RetentionDays int // 保留天数，0 表示不限
+	lastPrune     time.Time
+}
+
+// Open 打开采样存储目录
+func Open(dir string, retentionDays int) *Store {
+	return &Store{dir: dir, RetentionDays: retentionDays}
+}
+
+// Add 追加一次采样
+func (s *Store) Add(sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sample.Time.IsZero() {
+		sample.Time = time.Now()
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.file(sample.Time), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	f.Close()
+
+	if time.Since(s.lastPrune) > time.Hour {
+		s.lastPrune = time.Now()
+		s.prune()
+	}
+	return err
+}
+
+func (s *Store) file(t time.Time) string {
+	return filepath.Join(s.dir, t.Format(dayLayout)+".jsonl")
+}
+
+// days 返回已有的日期文件，按日期正序
+func (s *Store) days() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var days []string
+	for _, e := range entries {
+		if day, ok := strings.CutSuffix(e.Name(), ".jsonl"); ok {
+			if _, err := time.Parse(dayLayout, day); err == nil {
+				days = append(days, day)
+			}
+		}
+	}
+	sort.Strings(days)
+	return days
+}
+
+// Range 返回时间范围内的采样，按时间正序；account 为空表示全部账号
+func (s *Store) Range(from, to time.Time, account string) ([]Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := []Sample{}
+	for _, day := range s.days() {
+		if !from.IsZero() && day < from.Format(dayLayout) {
+			continue
+		}
+		if !to.IsZero() && day > to.Format(dayLayout) {
+			break
+		}
+		daySamples, err := s.readDay(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, sm := range daySamples {
+			if (!from.IsZero() && sm.Time.Before(from)) || (!to.IsZero() && sm.Time.After(to)) {
+				continue
+			}
+			if account != "" && sm.Account != account {
+				continue
+			}
+			samples = append(samples, sm)
+		}
+	}
+	return samples, nil
+}
+
+// Last 返回每个账号最近的 n 次采样，按时间正序
+func (s *Store) Last(n int) (map[string][]Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string][]Sample)
+	days := s.days()
+	// 最多回看两天，跨零点时仍能取到上一次采样
+	if len(days) > 2 {
+		days = days[len(days)-2:]
+	}
+	for _, day := range days {
+		daySamples, err := s.readDay(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, sm := range daySamples {
+			list := append(result[sm.Account], sm)
+			if len(list) > n {
+				list = list[len(list)-n:]
+			}
+			result[sm.Account] = list
+		}
+	}
+	return result, nil
+}
+
+func (s *Store) readDay(day string) ([]Sample, error) {
+	f, err := os.Open(filepath.Join(s.dir, day+".jsonl"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var sm Sample
+		if json.Unmarshal(scanner.Bytes(), &sm) == nil {
+			samples = append(samples, sm)
+		}
+	}
+	return samples, scanner.Err()
+}
+
+// Prune 删除超过保留天数的文件
+func (s *Store) Prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+}
+
+func (s *Store) prune() {
+	if s.RetentionDays <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -s.RetentionDays).Format(dayLayout)
+	for _, day := range s.days() {
+		if day < cutoff {
+			os.Remove(filepath.Join(s.dir, day+".jsonl"))
+		}
+	}
+}
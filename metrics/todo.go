@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"zto-api-proxy/export"
+)
+
+// Sample 一次待办数量采样
+type Sample struct {
+	Time     time.Time      `json:"time"`
+	Account  string         `json:"account"`
+	SiteCode string         `json:"siteCode,omitempty"`
+	Total    int            `json:"total"`
+	Counts   map[string]int `json:"counts"` // 待办事项 -> 数量
+}
+
+// Change 单个待办事项的变化
+type Change struct {
+	Key    string `json:"key"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Delta  int    `json:"delta"`
+}
+
+// Diff 相邻两次采样的差异，Changes 只包含数量有变化的事项
+type Diff struct {
+	Account  string    `json:"account"`
+	SiteCode string    `json:"siteCode,omitempty"`
+	From     time.Time `json:"from,omitzero"`
+	To       time.Time `json:"to"`
+	Total    Change    `json:"total"`
+	Changes  []Change  `json:"changes"`
+}
+
+// Point 时间序列中的一个点
+type Point struct {
+	Time   time.Time      `json:"time"`
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+}
+
+// Series 单个账号的时间序列
+type Series struct {
+	Account  string  `json:"account"`
+	SiteCode string  `json:"siteCode,omitempty"`
+	Points   []Point `json:"points"`
+}
+
+var (
+	nameKeys  = []string{"todoName", "todoType", "name", "title", "label", "type"}
+	countKeys = []string{"count", "num", "total", "todoCount", "value", "quantity"}
+)
+
+// ExtractCounts 从待办中心响应中提取各事项数量。
+// 列表形式取每行的名称与数量字段；单个对象时取其中的数值字段。
+func ExtractCounts(data interface{}) map[string]int {
+	counts := make(map[string]int)
+	rows := export.ExtractRows(data)
+
+	for _, r := range rows {
+		row, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := firstString(row, nameKeys)
+		n, hasCount := firstInt(row, countKeys)
+		if name != "" && hasCount {
+			counts[name] += n
+			continue
+		}
+		// 没有名称/数量字段时按 {事项: 数量} 处理
+		for k, v := range export.Flatten(row) {
+			if n, ok := toInt(v); ok {
+				counts[k] += n
+			}
+		}
+	}
+	return counts
+}
+
+func firstString(row map[string]interface{}, keys []string) string {
+	for _, k := range keys {
+		if s, ok := row[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func firstInt(row map[string]interface{}, keys []string) (int, bool) {
+	for _, k := range keys {
+		if n, ok := toInt(row[k]); ok {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// NewSample 根据各事项数量生成采样
+func NewSample(account, siteCode string, counts map[string]int, t time.Time) Sample {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return Sample{Time: t, Account: account, SiteCode: siteCode, Total: total, Counts: counts}
+}
+
+// DiffSamples 比较两次采样，prev 为零值时视为全部新增
+func DiffSamples(prev, cur Sample) Diff {
+	d := Diff{
+		Account:  cur.Account,
+		SiteCode: cur.SiteCode,
+		From:     prev.Time,
+		To:       cur.Time,
+		Total:    Change{Key: "total", Before: prev.Total, After: cur.Total, Delta: cur.Total - prev.Total},
+		Changes:  []Change{},
+	}
+
+	keys := make(map[string]bool)
+	for k := range prev.Counts {
+		keys[k] = true
+	}
+	for k := range cur.Counts {
+		keys[k] = true
+	}
+	for k := range keys {
+		before, after := prev.Counts[k], cur.Counts[k]
+		if before != after {
+			d.Changes = append(d.Changes, Change{Key: k, Before: before, After: after, Delta: after - before})
+		}
+	}
+	// 变化大的在前
+	sort.Slice(d.Changes, func(i, j int) bool {
+		a, b := abs(d.Changes[i].Delta), abs(d.Changes[j].Delta)
+		if a != b {
+			return a > b
+		}
+		return d.Changes[i].Key < d.Changes[j].Key
+	})
+	return d
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// BuildSeries 按账号分组，step > 0 时每个时间窗口保留最后一次采样
+func BuildSeries(samples []Sample, step time.Duration) []Series {
+	index := make(map[string]int)
+	var series []Series
+	for _, s := range samples {
+		i, ok := index[s.Account]
+		if !ok {
+			i = len(series)
+			index[s.Account] = i
+			series = append(series, Series{Account: s.Account, Points: []Point{}})
+		}
+		sr := &series[i]
+		if s.SiteCode != "" {
+			sr.SiteCode = s.SiteCode
+		}
+
+		p := Point{Time: s.Time, Total: s.Total, Counts: s.Counts}
+		if step > 0 {
+			p.Time = s.Time.Truncate(step)
+			if n := len(sr.Points); n > 0 && sr.Points[n-1].Time.Equal(p.Time) {
+				sr.Points[n-1] = p
+				continue
+			}
+		}
+		sr.Points = append(sr.Points, p)
+	}
+	if series == nil {
+		series = []Series{}
+	}
+	return series
+}
@@ -0,0 +1,59 @@
+// Package procdetect 跨平台的进程枚举与按名称查找
+package procdetect
+
+import (
+	"path"
+	"strings"
+)
+
+// Process 进程信息
+type Process struct {
+	PID  int    `json:"pid"`
+	Name string `json:"name"` // 可执行文件名，如 zbox.exe
+}
+
+// Lister 枚举当前运行的进程
+type Lister interface {
+	Processes() ([]Process, error)
+}
+
+// System 返回当前操作系统的原生进程枚举
+func System() Lister {
+	return systemLister{}
+}
+
+// Match 判断进程名是否匹配任一模式，模式为不区分大小写的通配符 (* ?)
+func Match(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Find 返回名称匹配任一模式的进程
+func Find(l Lister, patterns []string) ([]Process, error) {
+	procs, err := l.Processes()
+	if err != nil {
+		return nil, err
+	}
+	var found []Process
+	for _, p := range procs {
+		if Match(p.Name, patterns) {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+// Fake 返回固定结果，用于测试
+type Fake struct {
+	Procs []Process
+	Err   error
+}
+
+func (f *Fake) Processes() ([]Process, error) {
+	return f.Procs, f.Err
+}
@@ -0,0 +1,40 @@
+package procdetect
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	fake := &Fake{Procs: []Process{{1, "System"}, {20, "ZBox.exe"}, {30, "sqlite.exe"}, {40, "lite.exe"}}}
+	found, err := Find(fake, []string{"*zbox*", "lite.exe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].PID != 20 || found[1].PID != 40 {
+		t.Errorf("应不区分大小写按模式匹配: %+v", found)
+	}
+
+	fake.Err = errors.New("拒绝访问")
+	if _, err := Find(fake, []string{"*"}); err == nil {
+		t.Error("枚举失败时应返回错误")
+	}
+}
+
+func TestSystem(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		t.Skip("当前系统不支持进程枚举")
+	}
+	procs, err := System().Processes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range procs {
+		if p.PID == os.Getpid() {
+			return
+		}
+	}
+	t.Errorf("进程列表中应包含当前进程 (共 %d 个)", len(procs))
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package procdetect
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+type systemLister struct{}
+
+// Processes 读取 /proc/<pid>/comm
+func (systemLister) Processes() ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var procs []Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		comm, err := os.ReadFile("/proc/" + e.Name() + "/comm")
+		if err != nil {
+			continue // 进程已退出
+		}
+		procs = append(procs, Process{PID: pid, Name: strings.TrimSpace(string(comm))})
+	}
+	return procs, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !windows
+
+package procdetect
+
+import "errors"
+
+type systemLister struct{}
+
+func (systemLister) Processes() ([]Process, error) {
+	return nil, errors.ErrUnsupported
+}
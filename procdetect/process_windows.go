@@ -0,0 +1,39 @@
+//go:build windows
+
+package procdetect
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type systemLister struct{}
+
+// Processes 通过 CreateToolhelp32Snapshot 枚举进程，不依赖 tasklist / wmic
+func (systemLister) Processes() ([]Process, error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(snapshot)
+
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	if err := windows.Process32First(snapshot, &entry); err != nil {
+		return nil, err
+	}
+
+	var procs []Process
+	for {
+		procs = append(procs, Process{PID: int(entry.ProcessID), Name: windows.UTF16ToString(entry.ExeFile[:])})
+		err := windows.Process32Next(snapshot, &entry)
+		if errors.Is(err, windows.ERROR_NO_MORE_FILES) {
+			return procs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"zto-api-proxy/config"
+)
+
+// ErrUnknownAccount 请求指定了不存在的账号
+var ErrUnknownAccount = errors.New("未知账号")
+
+const (
+	SelectRoundRobin = "round-robin"
+	SelectLRU        = "lru"
+)
+
+// AccountUsage 账号使用统计
+type AccountUsage struct {
+	LastUsed time.Time `json:"lastUsed,omitzero"`
+	Requests int64     `json:"requests"`
+}
+
+// accountSelector 未指定账号时在有效账号间分配请求
+type accountSelector struct {
+	mu    sync.Mutex
+	next  int
+	usage map[string]*AccountUsage
+}
+
+func newAccountSelector() *accountSelector {
+	return &accountSelector{usage: make(map[string]*AccountUsage)}
+}
+
+// Select 解析请求使用的账号：指定时校验存在性，未指定时按策略选择
+func (s *accountSelector) Select(name, strategy string) (string, error) {
+	if name != "" {
+		if !config.HasAccount(name) {
+			return "", ErrUnknownAccount
+		}
+		return name, nil
+	}
+
+	names := config.AccountNames()
+	candidates := make([]string, 0, len(names))
+	for _, n := range names {
+		if config.IsAccountTokenValid(n) {
+			candidates = append(candidates, n)
+		}
+	}
+	// 没有有效账号时仍使用全部账号，交给刷新流程处理
+	if len(candidates) == 0 {
+		candidates = names
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var chosen string
+	if strategy == SelectLRU {
+		for _, n := range candidates {
+			u := s.usage[n]
+			if u == nil {
+				chosen = n
+				break
+			}
+			if chosen == "" || u.LastUsed.Before(s.usage[chosen].LastUsed) {
+				chosen = n
+			}
+		}
+	} else {
+		chosen = candidates[s.next%len(candidates)]
+		s.next++
+	}
+	return chosen, nil
+}
+
+// touch 记录账号的一次实际使用
+func (s *accountSelector) touch(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.usage[name]
+	if !ok {
+		u = &AccountUsage{}
+		s.usage[name] = u
+	}
+	u.LastUsed = time.Now()
+	u.Requests++
+}
+
+// Usage 返回账号使用统计
+func (s *accountSelector) Usage(name string) AccountUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.usage[name]; ok {
+		return *u
+	}
+	return AccountUsage{}
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"zto-api-proxy/config"
+)
+
+func setupAccounts(t *testing.T) {
+	cfg := config.GetConfig()
+	oldDir, oldAccounts := cfg.DataDir, cfg.Accounts
+	cfg.DataDir = t.TempDir()
+	cfg.Accounts = []config.AccountConfig{{Name: "a"}, {Name: "b"}}
+	t.Cleanup(func() {
+		cfg.DataDir, cfg.Accounts = oldDir, oldAccounts
+	})
+
+	for _, name := range []string{"a", "b"} {
+		config.SetAccountToken(name, &config.TokenData{
+			Cookies:   map[string]string{"wyzdzjxhdnh": name},
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+	}
+}
+
+func TestSelectAccount_RoundRobin(t *testing.T) {
+	setupAccounts(t)
+	s := newAccountSelector()
+
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		name, err := s.Select("", SelectRoundRobin)
+		if err != nil {
+			t.Fatalf("选择账号失败: %v", err)
+		}
+		seen[name]++
+	}
+
+	// 默认账号没有有效 Token，不参与选择
+	if seen["a"] != 2 || seen["b"] != 2 || seen[config.DefaultAccount] != 0 {
+		t.Errorf("轮询分配不均: %v", seen)
+	}
+}
+
+func TestSelectAccount_LRU(t *testing.T) {
+	setupAccounts(t)
+	s := newAccountSelector()
+
+	s.touch("a")
+	if name, _ := s.Select("", SelectLRU); name != "b" {
+		t.Errorf("期望选择未使用过的 b, 实际 %s", name)
+	}
+
+	time.Sleep(time.Millisecond)
+	s.touch("b")
+	if name, _ := s.Select("", SelectLRU); name != "a" {
+		t.Errorf("期望选择最久未使用的 a, 实际 %s", name)
+	}
+}
+
+func TestSelectAccount_Unknown(t *testing.T) {
+	setupAccounts(t)
+	s := newAccountSelector()
+
+	if _, err := s.Select("nobody", SelectRoundRobin); err != ErrUnknownAccount {
+		t.Errorf("期望 ErrUnknownAccount, 实际 %v", err)
+	}
+	if name, err := s.Select("b", SelectRoundRobin); err != nil || name != "b" {
+		t.Errorf("指定账号应直接返回: %s %v", name, err)
+	}
+}
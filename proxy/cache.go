@@ -0,0 +1,395 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+// 缓存结果，用于 X-Cache 响应头
+const (
+	CacheHit    = "HIT"    // 命中未过期的缓存
+	CacheStale  = "STALE"  // 返回过期数据，后台刷新中
+	CacheMiss   = "MISS"   // 未命中，已请求上游
+	CacheBypass = "BYPASS" // 调用方要求跳过缓存
+)
+
+// CacheStats 缓存统计
+type CacheStats struct {
+	Entries   int   `json:"entries"`
+	Hits      int64 `json:"hits"`
+	Stale     int64 `json:"stale"`
+	Misses    int64 `json:"misses"`
+	Bypass    int64 `json:"bypass"`
+	Evictions int64 `json:"evictions"`
+}
+
+type cacheEntry struct {
+	Response   *ProxyResponse `json:"response"`
+	StoredAt   time.Time      `json:"storedAt"`
+	ExpiresAt  time.Time      `json:"expiresAt"`
+	StaleUntil time.Time      `json:"staleUntil"`
+}
+
+// Cache 上游响应缓存，只保存成功的响应
+type Cache struct {
+	mu           sync.Mutex
+	entries      map[string]*cacheEntry
+	revalidating map[string]bool
+	maxEntries   int
+	dir          string // 为空时仅内存缓存
+	stats        CacheStats
+	now          func() time.Time
+}
+
+// NewCache 创建缓存，dir 不为空时同时落盘，并清理目录中过期或超出上限的文件
+func NewCache(maxEntries int, dir string) *Cache {
+	c := &Cache{
+		entries:      make(map[string]*cacheEntry),
+		revalidating: make(map[string]bool),
+		maxEntries:   maxEntries,
+		dir:          dir,
+		now:          time.Now,
+	}
+	if dir != "" {
+		c.sweep()
+	}
+	return c
+}
+
+// sweep 启动时载入缓存目录，删除损坏、过期的文件，超出上限的按淘汰规则删除
+func (c *Cache) sweep() {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	now := c.now()
+	for _, f := range files {
+		key, ok := strings.CutSuffix(f.Name(), ".json")
+		if f.IsDir() || !ok {
+			continue
+		}
+		e := c.load(key)
+		if e == nil || now.After(e.StaleUntil) {
+			os.Remove(c.path(key))
+			continue
+		}
+		c.entries[key] = e
+	}
+	c.evict()
+}
+
+// Fingerprint 计算请求指纹：方法 + 规范化 URL + 规范化 Body + 请求头 + 账号 + 翻页选项
+func Fingerprint(req *ProxyRequest) string {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	h := sha256.New()
+	for _, part := range []string{
+		method,
+		normalizeURL(req.URL),
+		normalizeBody(req.Body),
+		normalizeHeaders(req.Headers),
+		req.Account,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if req.Paginate != nil {
+		b, _ := json.Marshal(req.Paginate)
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeURL 查询参数按键排序
+func normalizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	u.RawQuery = u.Query().Encode()
+	u.Host = strings.ToLower(u.Host)
+	return u.String()
+}
+
+// normalizeBody JSON 重新编码使键有序，非 JSON 字符串原样使用
+func normalizeBody(body interface{}) string {
+	if body == nil {
+		return ""
+	}
+	if s, ok := body.(string); ok {
+		var v interface{}
+		if json.Unmarshal([]byte(s), &v) != nil {
+			return s
+		}
+		body = v
+	}
+	b, _ := json.Marshal(body)
+	return string(b)
+}
+
+func normalizeHeaders(headers map[string]string) string {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, strings.ToLower(k))
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		for hk, v := range headers {
+			if strings.ToLower(hk) == k {
+				sb.WriteString(k + ":" + v + "\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// cacheTTL 返回 URL 路径配置的缓存时间
+func cacheTTL(rawURL string) time.Duration {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(config.GetConfig().CacheTTL[u.Path]) * time.Second
+}
+
+// Get 查找缓存，返回响应与是否已过期（处于 stale 窗口）
+func (c *Cache) Get(key string) (*ProxyResponse, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = c.load(key)
+		if e == nil {
+			return nil, false, false
+		}
+		c.entries[key] = e
+	}
+
+	now := c.now()
+	if now.After(e.StaleUntil) {
+		c.remove(key)
+		return nil, false, false
+	}
+	return e.Response, now.After(e.ExpiresAt), true
+}
+
+// Set 保存响应，返回的错误仅表示持久化失败，内存缓存已生效
+func (c *Cache) Set(key string, resp *ProxyResponse, ttl, stale time.Duration) error {
+	now := c.now()
+	e := &cacheEntry{
+		Response:   resp,
+		StoredAt:   now,
+		ExpiresAt:  now.Add(ttl),
+		StaleUntil: now.Add(ttl + stale),
+	}
+
+	c.mu.Lock()
+	c.entries[key] = e
+	c.evict()
+	_, kept := c.entries[key]
+	c.mu.Unlock()
+
+	if !kept {
+		return nil
+	}
+	return c.save(key, e)
+}
+
+// evict 超出上限时先清理过期条目，再淘汰最早过期的条目
+func (c *Cache) evict() {
+	if c.maxEntries <= 0 || len(c.entries) <= c.maxEntries {
+		return
+	}
+	now := c.now()
+	for k, e := range c.entries {
+		if now.After(e.StaleUntil) {
+			c.remove(k)
+			c.stats.Evictions++
+		}
+	}
+	for len(c.entries) > c.maxEntries {
+		var oldest string
+		for k, e := range c.entries {
+			if oldest == "" || e.StaleUntil.Before(c.entries[oldest].StaleUntil) {
+				oldest = k
+			}
+		}
+		c.remove(oldest)
+		c.stats.Evictions++
+	}
+}
+
+// remove 删除条目及其缓存文件，调用方需持有 c.mu
+func (c *Cache) remove(key string) {
+	delete(c.entries, key)
+	if c.dir != "" {
+		os.Remove(c.path(key))
+	}
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *Cache) load(key string) *cacheEntry {
+	if c.dir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil
+	}
+	var e cacheEntry
+	if json.Unmarshal(data, &e) != nil || e.Response == nil {
+		return nil
+	}
+	return &e
+}
+
+func (c *Cache) save(key string, e *cacheEntry) error {
+	if c.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path(key), data, 0600)
+}
+
+// Stats 返回缓存统计
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Entries = len(c.entries)
+	return st
+}
+
+func (c *Cache) record(status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch status {
+	case CacheHit:
+		c.stats.Hits++
+	case CacheStale:
+		c.stats.Stale++
+	case CacheMiss:
+		c.stats.Misses++
+	case CacheBypass:
+		c.stats.Bypass++
+	}
+}
+
+// startRevalidate 标记后台刷新，已有刷新在进行时返回 false
+func (c *Cache) startRevalidate(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.revalidating[key] {
+		return false
+	}
+	c.revalidating[key] = true
+	return true
+}
+
+func (c *Cache) endRevalidate(key string) {
+	c.mu.Lock()
+	delete(c.revalidating, key)
+	c.mu.Unlock()
+}
+
+// DoCached 对配置了缓存时间的接口走缓存，noCache 为 true 时跳过读取但仍更新缓存。
+// 返回的缓存状态为空表示该接口不缓存。
+func (c *Client) DoCached(req *ProxyRequest, noCache bool) (*ProxyResponse, string) {
+	return c.DoCachedContext(context.Background(), req, noCache)
+}
+
+// DoCachedContext 同 DoCached，ctx 中的请求 ID 会写入日志与响应
+func (c *Client) DoCachedContext(ctx context.Context, req *ProxyRequest, noCache bool) (*ProxyResponse, string) {
+	resp, status := c.doCached(ctx, req, noCache)
+	if status == CacheHit || status == CacheStale {
+		// 缓存中的响应为共享对象，复制后再写入本次请求 ID
+		r := *resp
+		r.RequestID = logger.RequestID(ctx)
+		resp = &r
+	}
+	return resp, status
+}
+
+func (c *Client) doCached(ctx context.Context, req *ProxyRequest, noCache bool) (*ProxyResponse, string) {
+	ttl := cacheTTL(req.URL)
+	if ttl <= 0 {
+		return c.DoRequestContext(ctx, req), ""
+	}
+	stale := time.Duration(config.GetConfig().CacheStale) * time.Second
+
+	// 未指定账号时先选定账号再计算指纹，避免不同账号的数据互相命中
+	account, err := c.SelectAccount(req.Account)
+	if err != nil {
+		return c.DoRequestContext(ctx, req), ""
+	}
+	pinned := *req
+	pinned.Account = account
+	req = &pinned
+	key := Fingerprint(req)
+
+	if noCache {
+		c.cache.record(CacheBypass)
+		return c.fetchAndStore(ctx, key, req, ttl, stale), CacheBypass
+	}
+
+	if resp, expired, ok := c.cache.Get(key); ok {
+		if !expired {
+			c.cache.record(CacheHit)
+			return resp, CacheHit
+		}
+		c.cache.record(CacheStale)
+		if c.cache.startRevalidate(key) {
+			r := *req
+			go func() {
+				defer c.cache.endRevalidate(key)
+				c.fetchAndStore(ctx, key, &r, ttl, stale)
+			}()
+		}
+		return resp, CacheStale
+	}
+
+	c.cache.record(CacheMiss)
+	return c.fetchAndStore(ctx, key, req, ttl, stale), CacheMiss
+}
+
+func (c *Client) fetchAndStore(ctx context.Context, key string, req *ProxyRequest, ttl, stale time.Duration) *ProxyResponse {
+	resp := c.DoRequestContext(ctx, req)
+	if resp.Success {
+		if err := c.cache.Set(key, resp, ttl, stale); err != nil {
+			logger.With(logger.CategoryAPI).WarnContext(ctx, "写入缓存文件失败", "error", err)
+		}
+	}
+	return resp
+}
+
+// CacheStats 返回响应缓存统计
+func (c *Client) CacheStats() CacheStats {
+	return c.cache.Stats()
+}
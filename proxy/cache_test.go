@@ -0,0 +1,261 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+func TestFingerprint(t *testing.T) {
+	a := &ProxyRequest{URL: "https://a.com/x?b=2&a=1", Method: "post", Body: `{"y":1,"x":2}`}
+	b := &ProxyRequest{URL: "https://A.com/x?a=1&b=2", Method: "POST", Body: map[string]interface{}{"x": 2, "y": 1}}
+	if Fingerprint(a) != Fingerprint(b) {
+		t.Error("参数顺序不同的等价请求应得到相同指纹")
+	}
+
+	b.Account = "other"
+	if Fingerprint(a) == Fingerprint(b) {
+		t.Error("不同账号应得到不同指纹")
+	}
+}
+
+func TestCache_Expiry(t *testing.T) {
+	now := time.Now()
+	c := NewCache(10, "")
+	c.now = func() time.Time { return now }
+
+	c.Set("k", &ProxyResponse{Success: true}, 10*time.Second, 20*time.Second)
+
+	if _, expired, ok := c.Get("k"); !ok || expired {
+		t.Error("未过期时应命中")
+	}
+	now = now.Add(15 * time.Second)
+	if _, expired, ok := c.Get("k"); !ok || !expired {
+		t.Error("stale 窗口内应返回过期数据")
+	}
+	now = now.Add(20 * time.Second)
+	if _, _, ok := c.Get("k"); ok {
+		t.Error("超过 stale 窗口后不应命中")
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	c := NewCache(2, "")
+	for i, k := range []string{"a", "b", "c"} {
+		c.Set(k, &ProxyResponse{Success: true}, time.Duration(i+1)*time.Minute, 0)
+	}
+	if _, _, ok := c.Get("a"); ok {
+		t.Error("最早过期的条目应被淘汰")
+	}
+	if st := c.Stats(); st.Entries != 2 || st.Evictions != 1 {
+		t.Errorf("统计不正确: %+v", st)
+	}
+}
+
+func TestCache_Persist(t *testing.T) {
+	dir := t.TempDir()
+	NewCache(10, dir).Set("k", &ProxyResponse{Success: true, StatusCode: 200}, time.Minute, 0)
+
+	resp, _, ok := NewCache(10, dir).Get("k")
+	if !ok || resp.StatusCode != 200 {
+		t.Error("重启后应从磁盘读取缓存")
+	}
+}
+
+func TestCache_EvictRemovesFiles(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(2, dir)
+	for i, k := range []string{"a", "b", "c"} {
+		c.Set(k, &ProxyResponse{Success: true}, time.Duration(i+1)*time.Minute, 0)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.json")); !os.IsNotExist(err) {
+		t.Error("淘汰的条目应同时删除缓存文件")
+	}
+
+	// 启动时清理过期、损坏与超出上限的文件
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600)
+	expired := NewCache(10, "")
+	expired.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	expired.dir = dir
+	expired.Set("old", &ProxyResponse{Success: true}, time.Minute, 0)
+
+	NewCache(1, dir)
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || files[0].Name() != "c.json" {
+		names := []string{}
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("启动清理后应只保留最晚过期的 c.json, 实际 %v", names)
+	}
+}
+
+func TestDoCached(t *testing.T) {
+	cfg := config.GetConfig()
+	old := cfg.CacheTTL
+	cfg.CacheTTL = map[string]int{"/todo": 60}
+	t.Cleanup(func() { cfg.CacheTTL = old })
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer server.Close()
+
+	client := NewClient(nil)
+	req := &ProxyRequest{URL: server.URL + "/todo", Method: "POST", Body: map[string]interface{}{"a": 1}}
+
+	steps := []struct {
+		noCache bool
+		want    string
+	}{
+		{false, CacheMiss},
+		{false, CacheHit},
+		{true, CacheBypass},
+		{false, CacheHit},
+	}
+	for i, s := range steps {
+		if _, status := client.DoCached(req, s.noCache); status != s.want {
+			t.Errorf("第 %d 次: 期望 %s, 实际 %s", i+1, s.want, status)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("期望请求上游 2 次, 实际 %d", calls)
+	}
+
+	// 未配置 TTL 的路径不缓存
+	other := &ProxyRequest{URL: server.URL + "/other", Method: "GET"}
+	if _, status := client.DoCached(other, false); status != "" {
+		t.Errorf("未配置缓存的接口不应返回缓存状态, 实际 %s", status)
+	}
+}
+
+func TestDoCached_AccountIsolation(t *testing.T) {
+	setupAccounts(t)
+	cfg := config.GetConfig()
+	oldTTL, oldSel := cfg.CacheTTL, cfg.AccountSelection
+	cfg.CacheTTL = map[string]int{"/todo": 60}
+	cfg.AccountSelection = SelectRoundRobin
+	t.Cleanup(func() { cfg.CacheTTL, cfg.AccountSelection = oldTTL, oldSel })
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]int32{"call": atomic.AddInt32(&calls, 1)})
+	}))
+	defer server.Close()
+
+	client := NewClient(nil)
+	owner := map[string]string{} // 响应数据 -> 请求时选定的账号
+	steps := []string{CacheMiss, CacheMiss, CacheHit, CacheHit}
+	for i, want := range steps {
+		resp, status := client.DoCached(&ProxyRequest{URL: server.URL + "/todo", Method: "GET"}, false)
+		if status != want {
+			t.Errorf("第 %d 次: 期望 %s, 实际 %s", i+1, want, status)
+		}
+		data, _ := json.Marshal(resp.Data)
+		if prev, ok := owner[string(data)]; ok && prev != resp.Account {
+			t.Errorf("账号 %s 命中了账号 %s 的缓存", resp.Account, prev)
+		}
+		owner[string(data)] = resp.Account
+	}
+	if calls != 2 {
+		t.Errorf("两个账号应各请求上游一次, 实际 %d", calls)
+	}
+}
+
+func TestDoCached_StaleWhileRevalidate(t *testing.T) {
+	cfg := config.GetConfig()
+	oldTTL, oldStale := cfg.CacheTTL, cfg.CacheStale
+	cfg.CacheTTL, cfg.CacheStale = map[string]int{"/report": 1}, 60
+	t.Cleanup(func() { cfg.CacheTTL, cfg.CacheStale = oldTTL, oldStale })
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(map[string]int32{"version": n})
+	}))
+	defer server.Close()
+
+	client := NewClient(nil)
+	now := time.Now()
+	client.cache.now = func() time.Time { return now }
+	req := &ProxyRequest{URL: server.URL + "/report", Method: "GET"}
+
+	client.DoCached(req, false)
+	now = now.Add(2 * time.Second)
+
+	resp, status := client.DoCached(req, false)
+	if status != CacheStale {
+		t.Fatalf("期望 STALE, 实际 %s", status)
+	}
+	if v := resp.Data.(map[string]interface{})["version"]; v != float64(1) {
+		t.Errorf("应先返回旧数据, 实际 version=%v", v)
+	}
+
+	// 等待后台刷新完成
+	for i := 0; i < 100 && atomic.LoadInt32(&calls) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	resp, status = client.DoCached(req, false)
+	if status != CacheHit || resp.Data.(map[string]interface{})["version"] != float64(2) {
+		t.Errorf("后台刷新后应命中新数据: %s %v", status, resp.Data)
+	}
+}
+
+func TestDoCachedContext_RequestID(t *testing.T) {
+	cfg := config.GetConfig()
+	old := cfg.CacheTTL
+	cfg.CacheTTL = map[string]int{"/todo": 60}
+	t.Cleanup(func() { cfg.CacheTTL = old })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer server.Close()
+
+	client := NewClient(nil)
+	req := &ProxyRequest{URL: server.URL + "/todo", Method: "POST"}
+
+	first, _ := client.DoCachedContext(logger.WithRequestID(context.Background(), "req-1"), req, false)
+	hit, status := client.DoCachedContext(logger.WithRequestID(context.Background(), "req-2"), req, false)
+	// first 即缓存中的对象，命中时不应被改写
+	if first.RequestID != "req-1" || status != CacheHit || hit.RequestID != "req-2" {
+		t.Errorf("响应应带有本次请求 ID: %s %s %s", first.RequestID, status, hit.RequestID)
+	}
+	if resp := client.DoRequest(&ProxyRequest{URL: server.URL + "/other"}); resp.RequestID != "" {
+		t.Errorf("未携带请求 ID 时响应不应有 requestId: %s", resp.RequestID)
+	}
+}
+
+func TestDoRequestContext_RefreshReceivesRequestID(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer server.Close()
+
+	var gotID string
+	client := NewClient(func(ctx context.Context, account string) error {
+		gotID = logger.RequestID(ctx)
+		return nil
+	})
+	resp := client.DoRequestContext(logger.WithRequestID(context.Background(), "req-9"), &ProxyRequest{URL: server.URL})
+	if !resp.Success || gotID != "req-9" {
+		t.Errorf("Token 刷新应收到本次请求 ID: %v %q", resp.Success, gotID)
+	}
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"zto-api-proxy/config"
+)
+
+// URLRejectedError 目标 URL 被安全策略拒绝
+type URLRejectedError struct {
+	URL    string
+	Reason string
+}
+
+func (e *URLRejectedError) Error() string {
+	return fmt.Sprintf("目标地址被拒绝 (%s): %s", e.Reason, e.URL)
+}
+
+type guardCtxKey struct{}
+
+// withGuard 标记请求需要在建立连接时校验目标 IP
+func withGuard(ctx context.Context) context.Context {
+	return context.WithValue(ctx, guardCtxKey{}, true)
+}
+
+func guarded(ctx context.Context) bool {
+	v, _ := ctx.Value(guardCtxKey{}).(bool)
+	return v
+}
+
+// MatchHost 判断主机名是否匹配模式，"*.example.com" 同时匹配 example.com 及其子域名
+func MatchHost(host, pattern string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		base := pattern[2:]
+		return host == base || strings.HasSuffix(host, "."+base)
+	}
+	return host == pattern
+}
+
+func matchAny(host string, patterns []string) bool {
+	for _, p := range patterns {
+		if MatchHost(host, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrivateIP 内网、回环、链路本地及未指定地址
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsInterfaceLocalMulticast()
+}
+
+// CheckURL 按白名单与内网策略校验外部调用方提交的 URL
+func CheckURL(rawURL string) error {
+	cfg := config.GetConfig()
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return &URLRejectedError{URL: rawURL, Reason: "无法解析的 URL"}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &URLRejectedError{URL: rawURL, Reason: "仅支持 http/https 协议"}
+	}
+
+	host := u.Hostname()
+	if !matchAny(host, cfg.AllowedHosts) {
+		return &URLRejectedError{URL: rawURL,
+			Reason: fmt.Sprintf("主机 %s 不在白名单 %v 中", host, cfg.AllowedHosts)}
+	}
+
+	if cfg.AllowPrivateTargets {
+		return nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return &URLRejectedError{URL: rawURL, Reason: "无法解析主机: " + err.Error()}
+	}
+	for _, ip := range ips {
+		if isPrivateIP(ip) {
+			return &URLRejectedError{URL: rawURL,
+				Reason: fmt.Sprintf("主机 %s 解析到内网/回环地址 %s", host, ip)}
+		}
+	}
+	return nil
+}
+
+// dialControl 在建立连接前再次校验实际连接的 IP，防止 DNS 重绑定
+func dialControl(ctx context.Context, network, address string, c syscall.RawConn) error {
+	if !guarded(ctx) || config.GetConfig().AllowPrivateTargets {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+		return &URLRejectedError{URL: address, Reason: "连接目标为内网/回环地址"}
+	}
+	return nil
+}
+
+// checkRedirect 受保护的请求在每次重定向时重新校验目标
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("重定向次数过多")
+	}
+	if guarded(req.Context()) {
+		return CheckURL(req.URL.String())
+	}
+	return nil
+}
+
+// cookieAllowed 只向配置的中通域名发送登录 Cookie
+func cookieAllowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "https" && matchAny(u.Hostname(), config.GetConfig().CookieDomains)
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+
+	"zto-api-proxy/config"
+)
+
+func TestMatchHost(t *testing.T) {
+	cases := []struct {
+		host, pattern string
+		want          bool
+	}{
+		{"orderapi.zt-express.com", "*.zt-express.com", true},
+		{"zt-express.com", "*.zt-express.com", true},
+		{"evilzt-express.com", "*.zt-express.com", false},
+		{"zt-express.com.evil.io", "*.zt-express.com", false},
+		{"ORDERAPI.ZT-EXPRESS.COM", "*.zt-express.com", true},
+		{"127.0.0.1", "127.0.0.1", true},
+	}
+	for _, c := range cases {
+		if got := MatchHost(c.host, c.pattern); got != c.want {
+			t.Errorf("MatchHost(%q, %q) = %v, 期望 %v", c.host, c.pattern, got, c.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	cfg := config.GetConfig()
+	oldHosts := cfg.AllowedHosts
+	cfg.AllowedHosts = []string{"*.zt-express.com", "localhost"}
+	defer func() { cfg.AllowedHosts = oldHosts }()
+
+	var rejected *URLRejectedError
+	for _, u := range []string{
+		"https://attacker.example.com/",
+		"ftp://orderapi.zt-express.com/",
+		"http://localhost:8765/admin/config",
+		"not a url",
+	} {
+		if err := CheckURL(u); !errors.As(err, &rejected) {
+			t.Errorf("%s 应被拒绝, 实际 %v", u, err)
+		}
+	}
+}
+
+func TestCookieAllowed(t *testing.T) {
+	if !cookieAllowed("https://orderapi.zt-express.com/opsApi") {
+		t.Error("中通域名应附带 Cookie")
+	}
+	if cookieAllowed("http://orderapi.zt-express.com/opsApi") {
+		t.Error("非 https 不应附带 Cookie")
+	}
+	if cookieAllowed("https://attacker.example.com/") {
+		t.Error("外部域名不应附带 Cookie")
+	}
+}
@@ -0,0 +1,296 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+// PaginateOptions 自动翻页选项
+type PaginateOptions struct {
+	PageField   string `json:"pageField"`   // 页码字段，为空时自动识别 pageNum/pageIndex/pageNo/current
+	SizeField   string `json:"sizeField"`   // 每页条数字段，默认 pageSize
+	MaxRecords  int    `json:"maxRecords"`  // 最多聚合的记录数，不超过配置 paginateMaxRecords
+	Concurrency int    `json:"concurrency"` // 并行翻页数 (最多 8)，实际并发仍受请求队列限制
+}
+
+// PageResult 聚合后的分页结果
+type PageResult struct {
+	Rows      []interface{} `json:"rows"`
+	Total     int           `json:"total"`     // 上游报告的总数
+	Pages     int           `json:"pages"`     // 实际请求的页数
+	PageSize  int           `json:"pageSize"`  // 每页条数
+	Fetched   int           `json:"fetched"`   // 返回的记录数
+	Truncated bool          `json:"truncated"` // 是否因 maxRecords 截断
+}
+
+// 并行翻页数：默认值与调用方可指定的上限
+const (
+	defaultPageConcurrency = 4
+	maxPageConcurrency     = 8
+)
+
+var (
+	defaultPageFields = []string{"pageNum", "pageIndex", "pageNo", "current"}
+	totalKeys         = []string{"total", "totalCount", "totalNum", "totalSize", "count"}
+	listKeys          = []string{"list", "records", "rows", "items", "dataList", "data", "result"}
+)
+
+// ExtractPage 从上游响应中识别数据行与总数
+func ExtractPage(data interface{}) ([]interface{}, int, bool) {
+	return extractPage(data, 0)
+}
+
+func extractPage(data interface{}, depth int) ([]interface{}, int, bool) {
+	obj, ok := data.(map[string]interface{})
+	if !ok || depth > 3 {
+		return nil, 0, false
+	}
+
+	for _, lk := range listKeys {
+		rows, ok := obj[lk].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, tk := range totalKeys {
+			if n, ok := toInt(obj[tk]); ok {
+				return rows, n, true
+			}
+		}
+	}
+
+	// 常见的包装层: result / data / body
+	for _, k := range []string{"result", "data", "body"} {
+		if rows, total, ok := extractPage(obj[k], depth+1); ok {
+			return rows, total, true
+		}
+	}
+	return nil, 0, false
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	case string:
+		var i int
+		_, err := fmt.Sscanf(n, "%d", &i)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// requestBody 将请求体转换为可修改的 JSON 对象
+func requestBody(body interface{}) (map[string]interface{}, error) {
+	var raw []byte
+	switch v := body.(type) {
+	case map[string]interface{}:
+		raw, _ = json.Marshal(v) // 深拷贝，避免修改调用方的 body
+	case string:
+		raw = []byte(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil || m == nil {
+		return nil, fmt.Errorf("分页模式需要 JSON 对象请求体")
+	}
+	return m, nil
+}
+
+// pageRequest 生成指定页码的请求
+func pageRequest(req *ProxyRequest, base map[string]interface{}, pageFields []string, page int) *ProxyRequest {
+	body := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		body[k] = v
+	}
+	for _, f := range pageFields {
+		body[f] = page
+	}
+	r := *req
+	r.Body = body
+	r.Paginate = nil
+	return &r
+}
+
+// doPaginated 依次/并行拉取所有分页并合并
+func (c *Client) doPaginated(ctx context.Context, req *ProxyRequest) *ProxyResponse {
+	startTime := time.Now()
+	opts := *req.Paginate
+	fail := func(statusCode int, format string, args ...interface{}) *ProxyResponse {
+		return &ProxyResponse{
+			Success:     false,
+			StatusCode:  statusCode,
+			Account:     req.Account,
+			Error:       fmt.Sprintf(format, args...),
+			RequestTime: startTime.Format(time.RFC3339),
+			Duration:    time.Since(startTime).Milliseconds(),
+		}
+	}
+
+	base, err := requestBody(req.Body)
+	if err != nil {
+		return fail(400, "%v", err)
+	}
+
+	pageFields := defaultPageFields
+	if opts.PageField != "" {
+		pageFields = []string{opts.PageField}
+	} else {
+		var present []string
+		for _, f := range defaultPageFields {
+			if _, ok := base[f]; ok {
+				present = append(present, f)
+			}
+		}
+		if len(present) == 0 {
+			return fail(400, "无法识别分页字段，请指定 paginate.pageField")
+		}
+		pageFields = present
+	}
+	sizeField := opts.SizeField
+	if sizeField == "" {
+		sizeField = "pageSize"
+	}
+	// 调用方只能在配置上限内调小记录数与并发
+	maxRecords := config.GetConfig().PaginateMaxRecords
+	if opts.MaxRecords > 0 {
+		maxRecords = min(opts.MaxRecords, maxRecords)
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultPageConcurrency
+	}
+	concurrency = min(concurrency, maxPageConcurrency)
+
+	// 第一页：确定总数与每页条数
+	first := c.doRequest(ctx, pageRequest(req, base, pageFields, 1))
+	if !first.Success {
+		return first
+	}
+	rows, total, ok := ExtractPage(first.Data)
+	if !ok {
+		return fail(502, "无法从上游响应中识别分页数据")
+	}
+	// 上游可能限制每页条数，以实际返回的条数为准
+	pageSize, _ := toInt(base[sizeField])
+	if pageSize <= 0 || (len(rows) > 0 && len(rows) < pageSize && total > len(rows)) {
+		pageSize = len(rows)
+	}
+
+	// 后续页面固定使用第一页选中的账号
+	pinned := *req
+	pinned.Account = first.Account
+
+	target := total
+	if target > maxRecords {
+		target = maxRecords
+	}
+	pages := 1
+	if pageSize > 0 && target > len(rows) {
+		pages = (target + pageSize - 1) / pageSize
+	}
+
+	results := make([][]interface{}, pages)
+	results[0] = rows
+	errs := make([]string, pages)
+	reqSizes := make([]int64, pages)
+	respSizes := make([]int64, pages)
+	reqSizes[0], respSizes[0] = first.RequestSize, first.ResponseSize
+
+	// 其余页面并行拉取，任一页失败后取消排队中与进行中的页面。
+	// ctx 已由 DoRequestContext 脱离调用方，调用方断开时不中断聚合
+	pageCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for p := 2; p <= pages; p++ {
+		select {
+		case sem <- struct{}{}:
+		case <-pageCtx.Done():
+		}
+		if pageCtx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resp := c.doRequest(pageCtx, pageRequest(&pinned, base, pageFields, p))
+			reqSizes[p-1], respSizes[p-1] = resp.RequestSize, resp.ResponseSize
+			if !resp.Success {
+				// 因其它页失败而被取消的页面不覆盖真正的错误
+				if pageCtx.Err() == nil {
+					errs[p-1] = fmt.Sprintf("第 %d 页失败: %s", p, resp.Error)
+				}
+				cancel()
+				return
+			}
+			pageRows, _, ok := ExtractPage(resp.Data)
+			if !ok {
+				errs[p-1] = fmt.Sprintf("第 %d 页无法识别分页数据", p)
+				cancel()
+				return
+			}
+			results[p-1] = pageRows
+		}(p)
+	}
+	wg.Wait()
+
+	for _, e := range errs {
+		if e != "" {
+			return fail(502, "%s", e)
+		}
+	}
+
+	merged := make([]interface{}, 0, target)
+	var reqSize, respSize int64
+	for i, r := range results {
+		merged = append(merged, r...)
+		reqSize += reqSizes[i]
+		respSize += respSizes[i]
+	}
+	truncated := total > maxRecords
+	if len(merged) > maxRecords {
+		merged = merged[:maxRecords]
+		truncated = true
+	}
+
+	duration := time.Since(startTime).Milliseconds()
+	logger.With(logger.CategoryAPI, "account", first.Account).InfoContext(ctx, "分页聚合完成",
+		"url", truncateURL(req.URL), "pages", pages, "rows", len(merged), "total", total, "duration_ms", duration)
+
+	return &ProxyResponse{
+		Success:    true,
+		StatusCode: first.StatusCode,
+		Account:    first.Account,
+		Data: &PageResult{
+			Rows:      merged,
+			Total:     total,
+			Pages:     pages,
+			PageSize:  pageSize,
+			Fetched:   len(merged),
+			Truncated: truncated,
+		},
+		RequestTime: startTime.Format(time.RFC3339),
+		Duration:    duration,
+
+		RequestSize:  reqSize,
+		ResponseSize: respSize,
+	}
+}
@@ -0,0 +1,216 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"zto-api-proxy/config"
+)
+
+// newPagedServer 模拟 {"result":{"total":N,"list":[...]}} 结构的分页接口
+func newPagedServer(total, maxSize int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		page, _ := toInt(body["pageNum"])
+		size, _ := toInt(body["pageSize"])
+		if size > maxSize {
+			size = maxSize
+		}
+
+		list := []int{}
+		for i := (page - 1) * size; i < page*size && i < total; i++ {
+			list = append(list, i)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": true,
+			"result": map[string]interface{}{"total": total, "list": list},
+		})
+	}))
+}
+
+func TestDoRequest_Paginate(t *testing.T) {
+	server := newPagedServer(23, 10)
+	defer server.Close()
+
+	// 请求 50 条/页，上游限制为 10 条/页
+	resp := NewClient(nil).DoRequest(&ProxyRequest{
+		URL:      server.URL,
+		Method:   "POST",
+		Body:     map[string]interface{}{"pageNum": 1, "pageSize": 50},
+		Paginate: &PaginateOptions{},
+	})
+	if !resp.Success {
+		t.Fatalf("分页请求应成功: %s", resp.Error)
+	}
+
+	result := resp.Data.(*PageResult)
+	if result.Total != 23 || result.Fetched != 23 || result.Pages != 3 || result.Truncated {
+		t.Errorf("聚合结果不正确: %+v", result)
+	}
+	for i, row := range result.Rows {
+		if n, _ := toInt(row); n != i {
+			t.Fatalf("第 %d 行顺序错误: %v", i, row)
+		}
+	}
+}
+
+func TestDoRequest_PaginateMaxRecords(t *testing.T) {
+	server := newPagedServer(100, 10)
+	defer server.Close()
+
+	resp := NewClient(nil).DoRequest(&ProxyRequest{
+		URL:      server.URL,
+		Method:   "POST",
+		Body:     map[string]interface{}{"pageNum": 1, "pageSize": 10},
+		Paginate: &PaginateOptions{MaxRecords: 25},
+	})
+	if !resp.Success {
+		t.Fatalf("分页请求应成功: %s", resp.Error)
+	}
+
+	result := resp.Data.(*PageResult)
+	if result.Fetched != 25 || result.Pages != 3 || !result.Truncated {
+		t.Errorf("应截断到 25 条: %+v", result)
+	}
+}
+
+func TestDoRequest_PaginateLimits(t *testing.T) {
+	cfg := config.GetConfig()
+	old := cfg.PaginateMaxRecords
+	cfg.PaginateMaxRecords = 30
+	t.Cleanup(func() { cfg.PaginateMaxRecords = old })
+
+	server := newPagedServer(100, 10)
+	defer server.Close()
+
+	resp := NewClient(nil).DoRequest(&ProxyRequest{
+		URL:      server.URL,
+		Method:   "POST",
+		Body:     map[string]interface{}{"pageNum": 1, "pageSize": 10},
+		Paginate: &PaginateOptions{MaxRecords: 1000, Concurrency: 1000},
+	})
+	if !resp.Success {
+		t.Fatalf("分页请求应成功: %s", resp.Error)
+	}
+	if result := resp.Data.(*PageResult); result.Fetched != 30 || !result.Truncated {
+		t.Errorf("调用方的 maxRecords 不应超过配置上限: %+v", result)
+	}
+}
+
+func TestDoRequest_PaginateCancelOnFailure(t *testing.T) {
+	cfg := config.GetConfig()
+	old := cfg.MaxRetries
+	cfg.MaxRetries = 0
+	t.Cleanup(func() { cfg.MaxRetries = old })
+
+	var later int32
+	paged := newPagedServer(100, 10)
+	defer paged.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]interface{}
+		json.Unmarshal(body, &req)
+		switch page, _ := toInt(req["pageNum"]); {
+		case page == 2:
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case page > 2:
+			atomic.AddInt32(&later, 1)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		paged.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	resp := NewClient(nil).DoRequest(&ProxyRequest{
+		URL:      server.URL,
+		Method:   "POST",
+		Body:     map[string]interface{}{"pageNum": 1, "pageSize": 10},
+		Paginate: &PaginateOptions{Concurrency: 1},
+	})
+	if resp.Success {
+		t.Fatal("某页失败时聚合应失败")
+	}
+	if later != 0 {
+		t.Errorf("失败后不应继续请求剩余页面, 实际请求了 %d 页", later)
+	}
+}
+
+func TestDoRequest_PaginateCancelsInFlight(t *testing.T) {
+	cfg := config.GetConfig()
+	old := cfg.MaxRetries
+	cfg.MaxRetries = 0
+	t.Cleanup(func() { cfg.MaxRetries = old })
+
+	paged := newPagedServer(100, 10)
+	defer paged.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]interface{}
+		json.Unmarshal(body, &req)
+		switch page, _ := toInt(req["pageNum"]); page {
+		case 2:
+			// 慢页面，只有被取消才会提前结束
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		case 3:
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		paged.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	resp := NewClient(nil).DoRequest(&ProxyRequest{
+		URL:      server.URL,
+		Method:   "POST",
+		Body:     map[string]interface{}{"pageNum": 1, "pageSize": 10},
+		Paginate: &PaginateOptions{Concurrency: 2},
+	})
+	if resp.Success || !strings.Contains(resp.Error, "第 3 页") {
+		t.Fatalf("应返回第 3 页的错误, 实际 %q", resp.Error)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("某页失败后应取消进行中的页面, 实际耗时 %v", d)
+	}
+}
+
+func TestDoRequest_PaginateNoPageField(t *testing.T) {
+	resp := NewClient(nil).DoRequest(&ProxyRequest{
+		URL:      "http://127.0.0.1:1",
+		Method:   "POST",
+		Body:     map[string]interface{}{"foo": 1},
+		Paginate: &PaginateOptions{},
+	})
+	if resp.Success || resp.StatusCode != 400 {
+		t.Errorf("缺少分页字段应返回 400, 实际 %d", resp.StatusCode)
+	}
+}
+
+func TestExtractPage(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`{"data":{"records":[1,2],"totalCount":"7"}}`), &data)
+
+	rows, total, ok := ExtractPage(data)
+	if !ok || len(rows) != 2 || total != 7 {
+		t.Errorf("识别失败: rows=%v total=%d ok=%v", rows, total, ok)
+	}
+
+	if _, _, ok := ExtractPage(map[string]interface{}{"list": []interface{}{}}); ok {
+		t.Error("缺少总数时不应识别为分页数据")
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"zto-api-proxy/config"
+)
+
+// ErrSessionRevoked 上游判定登录状态已失效 (如宝盒异地登录导致 Token 被注销)
+var ErrSessionRevoked = errors.New("登录状态已失效")
+
+// Probe 使用指定 Cookie 请求探测接口，验证登录状态在服务端是否有效。
+// 不重试也不触发刷新，登录失效时返回 ErrSessionRevoked
+func (c *Client) Probe(cookie string) error {
+	cfg := config.GetConfig()
+	if cfg.ProbeURL == "" {
+		return fmt.Errorf("未配置 probeUrl")
+	}
+	req := &ProxyRequest{URL: cfg.ProbeURL, Method: "POST", Cookie: cookie}
+	if cfg.ProbeBody != "" {
+		req.Body = cfg.ProbeBody
+	} else {
+		req.Method = "GET"
+	}
+
+	release, err := c.queue.Acquire(context.Background(), requestHost(req.URL))
+	if err != nil {
+		return err
+	}
+	resp, err := c.doSingleRequest(context.Background(), req, config.DefaultAccount)
+	release()
+	if err != nil {
+		return err
+	}
+	return probeResult(resp)
+}
+
+// probeResult 判断探测响应: 认证失败状态码、非 JSON 响应 (登录页) 或
+// 业务错误信息中提到登录时视为登录失效
+func probeResult(resp *ProxyResponse) error {
+	switch resp.StatusCode {
+	case 301, 302, 401, 403:
+		return fmt.Errorf("%w (HTTP %d)", ErrSessionRevoked, resp.StatusCode)
+	}
+	if !resp.Success {
+		return fmt.Errorf("探测接口返回 HTTP %d", resp.StatusCode)
+	}
+
+	obj, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w (响应不是 JSON)", ErrSessionRevoked)
+	}
+	if status, ok := obj["status"].(bool); ok && !status {
+		msg := fmt.Sprint(obj["message"])
+		if msg == "<nil>" {
+			b, _ := json.Marshal(obj)
+			msg = string(b)
+		}
+		for _, k := range []string{"登录", "登陆", "token", "认证", "授权", "login"} {
+			if strings.Contains(strings.ToLower(msg), k) {
+				return fmt.Errorf("%w: %s", ErrSessionRevoked, msg)
+			}
+		}
+		return fmt.Errorf("探测接口返回错误: %s", msg)
+	}
+	return nil
+}
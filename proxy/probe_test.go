@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zto-api-proxy/config"
+)
+
+func TestProbeResult(t *testing.T) {
+	cases := []struct {
+		name    string
+		resp    *ProxyResponse
+		revoked bool
+		ok      bool
+	}{
+		{"成功", &ProxyResponse{Success: true, StatusCode: 200, Data: map[string]interface{}{"status": true}}, false, true},
+		{"401", &ProxyResponse{StatusCode: 401}, true, false},
+		{"登录页", &ProxyResponse{Success: true, StatusCode: 200, Data: "<html>"}, true, false},
+		{"业务提示未登录", &ProxyResponse{Success: true, StatusCode: 200, Data: map[string]interface{}{"status": false, "message": "用户未登录"}}, true, false},
+		{"其它业务错误", &ProxyResponse{Success: true, StatusCode: 200, Data: map[string]interface{}{"status": false, "message": "系统繁忙"}}, false, false},
+		{"上游故障", &ProxyResponse{StatusCode: 502}, false, false},
+	}
+	for _, c := range cases {
+		err := probeResult(c.resp)
+		if (err == nil) != c.ok || errors.Is(err, ErrSessionRevoked) != c.revoked {
+			t.Errorf("%s: 判断不正确: %v", c.name, err)
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.Write([]byte(`{"status":true,"result":{}}`))
+	}))
+	defer server.Close()
+
+	cfg := config.GetConfig()
+	oldURL := cfg.ProbeURL
+	cfg.ProbeURL = server.URL
+	defer func() { cfg.ProbeURL = oldURL }()
+
+	if err := NewClient(nil).Probe("wyzdzjxhdnh=x"); err != nil {
+		t.Fatal(err)
+	}
+	if body != cfg.ProbeBody {
+		t.Errorf("应发送配置的探测请求体: %s", body)
+	}
+}
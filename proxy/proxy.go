@@ -2,10 +2,14 @@ package proxy
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"time"
 
 	"zto-api-proxy/config"
@@ -19,65 +23,173 @@ type ProxyRequest struct {
 	Headers     map[string]string `json:"headers"`
 	Body        interface{}       `json:"body"`
 	ContentType string            `json:"contentType"`
+	Account     string            `json:"account,omitempty"`  // 使用的账号，为空时自动选择
+	Paginate    *PaginateOptions  `json:"paginate,omitempty"` // 自动翻页并合并所有数据行
+	Guarded     bool              `json:"-"`                  // 外部提交的任意 URL，需要执行 SSRF 校验
+	Cookie      string            `json:"-"`                  // 指定 Cookie，代替账号当前的 Token
 }
 
 // ProxyResponse 代理响应结构
 type ProxyResponse struct {
 	Success     bool        `json:"success"`
 	StatusCode  int         `json:"statusCode"`
+	Account     string      `json:"account,omitempty"`
 	Data        interface{} `json:"data"`
 	Error       string      `json:"error,omitempty"`
 	RequestTime string      `json:"requestTime"`
-	Duration    int64       `json:"duration"` // 毫秒
+	Duration    int64       `json:"duration"`             // 毫秒
+	RetryAfter  int         `json:"retryAfter,omitempty"` // 队列繁忙时建议的重试间隔 (秒)
+	RequestID   string      `json:"requestId,omitempty"`  // 本次调用的 X-Request-ID
+
+	RequestSize  int64 `json:"-"` // 上游请求体字节数
+	ResponseSize int64 `json:"-"` // 上游响应体字节数
 }
 
 // Client HTTP 客户端
 type Client struct {
 	httpClient    *http.Client
-	onNeedRefresh func() error
+	onNeedRefresh func(ctx context.Context, account string) error
+	queue         *Queue
+	accounts      *accountSelector
+	cache         *Cache
 }
 
 // NewClient 创建代理客户端
-func NewClient(onNeedRefresh func() error) *Client {
+func NewClient(onNeedRefresh func(ctx context.Context, account string) error) *Client {
 	cfg := config.GetConfig()
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: time.Duration(cfg.RequestTimeout) * time.Second,
+			Timeout:       time.Duration(cfg.RequestTimeout) * time.Second,
+			Transport:     newTransport(),
+			CheckRedirect: checkRedirect,
 		},
 		onNeedRefresh: onNeedRefresh,
+		queue: NewQueue(cfg.HostConcurrency, cfg.DefaultConcurrency, cfg.QueueMaxDepth,
+			time.Duration(cfg.QueueWaitTimeout)*time.Second),
+		accounts: newAccountSelector(),
+		cache:    newCacheFromConfig(cfg),
 	}
 }
 
+func newCacheFromConfig(cfg *config.Config) *Cache {
+	dir := ""
+	if cfg.CachePersist {
+		dir = filepath.Join(cfg.DataDir, "cache")
+	}
+	return NewCache(cfg.CacheMaxEntries, dir)
+}
+
+// SelectAccount 解析请求使用的账号，name 为空时按配置策略选择
+func (c *Client) SelectAccount(name string) (string, error) {
+	return c.accounts.Select(name, config.GetConfig().AccountSelection)
+}
+
+// AccountUsage 返回账号使用统计
+func (c *Client) AccountUsage(name string) AccountUsage {
+	return c.accounts.Usage(name)
+}
+
+// QueueStats 返回请求队列统计
+func (c *Client) QueueStats() []QueueStats {
+	return c.queue.Stats()
+}
+
 // DoRequest 执行代理请求（带重试）
 func (c *Client) DoRequest(req *ProxyRequest) *ProxyResponse {
+	return c.DoRequestContext(context.Background(), req)
+}
+
+// DoRequestContext 执行代理请求，ctx 中的请求 ID 会写入各次重试的日志与响应。
+// ctx 只用于传递请求 ID 等值，调用方断开时仍会完成上游请求
+func (c *Client) DoRequestContext(ctx context.Context, req *ProxyRequest) *ProxyResponse {
+	ctx = context.WithoutCancel(ctx)
+	var resp *ProxyResponse
+	if req.Paginate != nil {
+		resp = c.doPaginated(ctx, req)
+	} else {
+		resp = c.doRequest(ctx, req)
+	}
+	resp.RequestID = logger.RequestID(ctx)
+	return resp
+}
+
+func (c *Client) doRequest(ctx context.Context, req *ProxyRequest) *ProxyResponse {
 	cfg := config.GetConfig()
 	startTime := time.Now()
 
 	var lastErr error
 	var resp *ProxyResponse
 
+	account, err := c.SelectAccount(req.Account)
+	if err != nil {
+		return &ProxyResponse{
+			Success:     false,
+			StatusCode:  http.StatusBadRequest,
+			Account:     req.Account,
+			Error:       fmt.Sprintf("%v: %s", err, req.Account),
+			RequestTime: startTime.Format(time.RFC3339),
+		}
+	}
+	c.accounts.touch(account)
+
+	host := requestHost(req.URL)
+	log := logger.With(logger.CategoryAPI, "account", account, "host", host)
+
 	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
 		if attempt > 0 {
-			logger.API("重试请求 (%d/%d): %s", attempt, cfg.MaxRetries, req.URL)
+			log.InfoContext(ctx, "重试请求", "attempt", attempt, "max", cfg.MaxRetries, "url", truncateURL(req.URL))
 			time.Sleep(time.Duration(cfg.RetryDelay) * time.Millisecond)
 		}
 
-		resp, lastErr = c.doSingleRequest(req)
+		if ctx.Err() != nil {
+			lastErr = ctx.Err()
+			break // 分页聚合已放弃本次请求
+		}
+		release, err := c.queue.Acquire(ctx, host)
+		if ctx.Err() != nil {
+			lastErr = ctx.Err()
+			break
+		}
+		if err != nil {
+			log.WarnContext(ctx, "请求排队失败", "error", err)
+			return &ProxyResponse{
+				Success:     false,
+				StatusCode:  http.StatusServiceUnavailable,
+				Account:     account,
+				Error:       err.Error(),
+				RequestTime: startTime.Format(time.RFC3339),
+				Duration:    time.Since(startTime).Milliseconds(),
+				RetryAfter:  c.queue.RetryAfter(host),
+			}
+		}
+		resp, lastErr = c.doSingleRequest(ctx, req, account)
+		release()
+		if resp != nil {
+			resp.Account = account
+		}
 
 		if lastErr == nil && resp.Success {
 			resp.Duration = time.Since(startTime).Milliseconds()
-			logger.API("%s %s -> %d (%dms)", req.Method, truncateURL(req.URL), resp.StatusCode, resp.Duration)
+			log.InfoContext(ctx, "上游请求完成",
+				"method", req.Method, "url", truncateURL(req.URL), "status", resp.StatusCode,
+				"duration_ms", resp.Duration, "attempt", attempt+1)
 			return resp
 		}
+		if lastErr != nil {
+			log.WarnContext(ctx, "上游请求失败", "attempt", attempt+1, "url", truncateURL(req.URL), "error", lastErr)
+		} else {
+			log.WarnContext(ctx, "上游请求失败", "attempt", attempt+1, "url", truncateURL(req.URL), "status", resp.StatusCode)
+		}
 
 		// 如果是 401/403 或 301（重定向到登录），尝试刷新 Token
 		if resp != nil && (resp.StatusCode == 401 || resp.StatusCode == 403 || resp.StatusCode == 301) {
-			logger.Token("检测到认证失败或重定向 (%d)，尝试刷新 Token", resp.StatusCode)
+			tokenLog := logger.With(logger.CategoryToken, "account", account)
+			tokenLog.InfoContext(ctx, "检测到认证失败或重定向，尝试刷新 Token", "status", resp.StatusCode)
 			if c.onNeedRefresh != nil {
-				if err := c.onNeedRefresh(); err != nil {
-					logger.Error("Token 刷新失败: %v", err)
+				if err := c.onNeedRefresh(ctx, account); err != nil {
+					tokenLog.ErrorContext(ctx, "Token 刷新失败", "error", err)
 				} else {
-					logger.Token("Token 刷新成功，重新请求")
+					tokenLog.InfoContext(ctx, "Token 刷新成功，重新请求")
 					continue
 				}
 			}
@@ -90,30 +202,35 @@ func (c *Client) DoRequest(req *ProxyRequest) *ProxyResponse {
 		resp = &ProxyResponse{
 			Success:     false,
 			StatusCode:  0,
+			Account:     account,
 			Error:       fmt.Sprintf("请求失败: %v", lastErr),
 			RequestTime: startTime.Format(time.RFC3339),
 			Duration:    duration,
 		}
 	}
 
-	logger.Error("请求最终失败: %s -> %s", req.URL, resp.Error)
+	log.ErrorContext(ctx, "请求最终失败",
+		"method", req.Method, "url", truncateURL(req.URL), "status", resp.StatusCode,
+		"duration_ms", duration, "error", resp.Error)
 	return resp
 }
 
-func (c *Client) doSingleRequest(req *ProxyRequest) (*ProxyResponse, error) {
+func (c *Client) doSingleRequest(ctx context.Context, req *ProxyRequest, account string) (*ProxyResponse, error) {
 	// 序列化请求体
 	var bodyReader io.Reader
+	var bodyBytes []byte
 	if req.Body != nil {
 		switch v := req.Body.(type) {
 		case string:
-			bodyReader = bytes.NewReader([]byte(v))
+			bodyBytes = []byte(v)
 		default:
-			bodyBytes, err := json.Marshal(req.Body)
+			b, err := json.Marshal(req.Body)
 			if err != nil {
 				return nil, fmt.Errorf("序列化请求体失败: %w", err)
 			}
-			bodyReader = bytes.NewReader(bodyBytes)
+			bodyBytes = b
 		}
+		bodyReader = bytes.NewReader(bodyBytes)
 	}
 
 	// 创建 HTTP 请求
@@ -122,7 +239,10 @@ func (c *Client) doSingleRequest(req *ProxyRequest) (*ProxyResponse, error) {
 		method = "GET"
 	}
 
-	httpReq, err := http.NewRequest(method, req.URL, bodyReader)
+	if req.Guarded {
+		ctx = withGuard(ctx)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, req.URL, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -148,10 +268,15 @@ func (c *Client) doSingleRequest(req *ProxyRequest) (*ProxyResponse, error) {
 		httpReq.Header.Set(key, value)
 	}
 
-	// 添加 cookies
-	cookieStr := config.GetCookieString()
-	if cookieStr != "" {
-		httpReq.Header.Set("Cookie", cookieStr)
+	// 添加 cookies（仅限中通域名）
+	if cookieAllowed(req.URL) {
+		cookieStr := req.Cookie
+		if cookieStr == "" {
+			cookieStr = config.GetAccountCookieString(account)
+		}
+		if cookieStr != "" {
+			httpReq.Header.Set("Cookie", cookieStr)
+		}
 	}
 
 	// 发送请求
@@ -183,9 +308,33 @@ func (c *Client) doSingleRequest(req *ProxyRequest) (*ProxyResponse, error) {
 		StatusCode:  resp.StatusCode,
 		Data:        data,
 		RequestTime: time.Now().Format(time.RFC3339),
+
+		RequestSize:  int64(len(bodyBytes)),
+		ResponseSize: int64(len(respBody)),
 	}, nil
 }
 
+// newTransport 创建带连接目标校验的 Transport
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{
+		Timeout:        30 * time.Second,
+		KeepAlive:      30 * time.Second,
+		ControlContext: dialControl,
+	}
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// requestHost 提取请求的上游主机名，作为队列分组依据
+func requestHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Hostname()
+}
+
 func truncateURL(url string) string {
 	if len(url) > 80 {
 		return url[:80] + "..."
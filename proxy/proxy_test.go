@@ -4,9 +4,24 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+
+	"zto-api-proxy/config"
 )
 
+// 测试期间把数据目录指向临时目录，避免在源码树里留下盐值等文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "zto-proxy-test")
+	if err != nil {
+		panic(err)
+	}
+	config.GetConfig().DataDir = dir
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestDoRequest_Success(t *testing.T) {
 	// 创建模拟服务器
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
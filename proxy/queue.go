@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull 排队数已达上限
+	ErrQueueFull = errors.New("请求队列已满")
+	// ErrQueueTimeout 排队等待超时
+	ErrQueueTimeout = errors.New("排队等待超时")
+)
+
+// QueueStats 单个上游主机的队列统计
+type QueueStats struct {
+	Host      string `json:"host"`
+	Limit     int    `json:"limit"`     // 并发上限
+	Active    int    `json:"active"`    // 正在执行
+	Waiting   int    `json:"waiting"`   // 正在排队
+	Served    int64  `json:"served"`    // 累计放行
+	Rejected  int64  `json:"rejected"`  // 队列满被拒绝
+	TimedOut  int64  `json:"timedOut"`  // 排队超时
+	AvgWaitMs int64  `json:"avgWaitMs"` // 平均排队时间
+	MaxWaitMs int64  `json:"maxWaitMs"` // 最长排队时间
+}
+
+type hostQueue struct {
+	slots     chan struct{}
+	waiting   int
+	served    int64
+	rejected  int64
+	timedOut  int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// Queue 按上游主机限流的请求队列
+type Queue struct {
+	mu           sync.Mutex
+	hosts        map[string]*hostQueue
+	limits       map[string]int
+	defaultLimit int
+	maxDepth     int
+	waitTimeout  time.Duration
+}
+
+// NewQueue 创建请求队列
+// limits 为各主机的并发上限，未列出的主机使用 defaultLimit
+func NewQueue(limits map[string]int, defaultLimit, maxDepth int, waitTimeout time.Duration) *Queue {
+	if defaultLimit <= 0 {
+		defaultLimit = 1
+	}
+	l := make(map[string]int, len(limits))
+	for host, n := range limits {
+		l[host] = n
+	}
+	return &Queue{
+		hosts:        make(map[string]*hostQueue),
+		limits:       l,
+		defaultLimit: defaultLimit,
+		maxDepth:     maxDepth,
+		waitTimeout:  waitTimeout,
+	}
+}
+
+func (q *Queue) getHost(host string) *hostQueue {
+	hq, ok := q.hosts[host]
+	if !ok {
+		limit := q.defaultLimit
+		if n, ok := q.limits[host]; ok && n > 0 {
+			limit = n
+		}
+		hq = &hostQueue{slots: make(chan struct{}, limit)}
+		q.hosts[host] = hq
+	}
+	return hq
+}
+
+// Acquire 为指定主机申请一个执行槽位，成功后必须调用返回的 release。
+// ctx 取消时放弃排队并返回 ctx.Err()
+func (q *Queue) Acquire(ctx context.Context, host string) (func(), error) {
+	q.mu.Lock()
+	hq := q.getHost(host)
+
+	// 有空闲槽位直接放行
+	select {
+	case hq.slots <- struct{}{}:
+		hq.served++
+		q.mu.Unlock()
+		return q.releaseFunc(hq), nil
+	default:
+	}
+
+	if q.maxDepth > 0 && hq.waiting >= q.maxDepth {
+		hq.rejected++
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	hq.waiting++
+	q.mu.Unlock()
+
+	start := time.Now()
+	var timeout <-chan time.Time
+	if q.waitTimeout > 0 {
+		timer := time.NewTimer(q.waitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case hq.slots <- struct{}{}:
+		waited := time.Since(start)
+		q.mu.Lock()
+		hq.waiting--
+		hq.served++
+		hq.totalWait += waited
+		if waited > hq.maxWait {
+			hq.maxWait = waited
+		}
+		q.mu.Unlock()
+		return q.releaseFunc(hq), nil
+	case <-timeout:
+		q.mu.Lock()
+		hq.waiting--
+		hq.timedOut++
+		q.mu.Unlock()
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		q.mu.Lock()
+		hq.waiting--
+		q.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (q *Queue) releaseFunc(hq *hostQueue) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-hq.slots })
+	}
+}
+
+// RetryAfter 根据该主机的平均排队时间估算客户端重试间隔（秒）
+func (q *Queue) RetryAfter(host string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	seconds := 1
+	if hq, ok := q.hosts[host]; ok && hq.served > 0 {
+		avg := hq.totalWait / time.Duration(hq.served)
+		if s := int(avg.Round(time.Second) / time.Second); s > seconds {
+			seconds = s
+		}
+	}
+	return seconds
+}
+
+// Stats 返回所有上游主机的队列统计
+func (q *Queue) Stats() []QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := make([]QueueStats, 0, len(q.hosts))
+	for host, hq := range q.hosts {
+		st := QueueStats{
+			Host:      host,
+			Limit:     cap(hq.slots),
+			Active:    len(hq.slots),
+			Waiting:   hq.waiting,
+			Served:    hq.served,
+			Rejected:  hq.rejected,
+			TimedOut:  hq.timedOut,
+			MaxWaitMs: hq.maxWait.Milliseconds(),
+		}
+		if hq.served > 0 {
+			st.AvgWaitMs = (hq.totalWait / time.Duration(hq.served)).Milliseconds()
+		}
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueue_ConcurrencyLimit(t *testing.T) {
+	q := NewQueue(map[string]int{"a.example.com": 2}, 1, 10, time.Second)
+
+	r1, err := q.Acquire(context.Background(), "a.example.com")
+	if err != nil {
+		t.Fatalf("第一个槽位应放行: %v", err)
+	}
+	r2, err := q.Acquire(context.Background(), "a.example.com")
+	if err != nil {
+		t.Fatalf("第二个槽位应放行: %v", err)
+	}
+
+	// 其它主机使用默认上限，互不影响
+	r3, err := q.Acquire(context.Background(), "b.example.com")
+	if err != nil {
+		t.Fatalf("其它主机应放行: %v", err)
+	}
+	r3()
+
+	done := make(chan struct{})
+	go func() {
+		r, err := q.Acquire(context.Background(), "a.example.com")
+		if err == nil {
+			r()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("超过并发上限时应排队等待")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r1()
+	<-done
+	r2()
+
+	for _, st := range q.Stats() {
+		if st.Host == "a.example.com" && (st.Limit != 2 || st.Served != 3 || st.Active != 0) {
+			t.Errorf("统计不正确: %+v", st)
+		}
+	}
+}
+
+func TestQueue_Full(t *testing.T) {
+	q := NewQueue(nil, 1, 1, time.Second)
+
+	release, _ := q.Acquire(context.Background(), "h")
+	defer release()
+
+	// 占满排队位，测试结束后释放其获得的并发位
+	queued := make(chan func(), 1)
+	go func() {
+		rel, err := q.Acquire(context.Background(), "h")
+		if err != nil {
+			rel = func() {}
+		}
+		queued <- rel
+	}()
+	t.Cleanup(func() { (<-queued)() })
+	waitQueued(t, q, "h", 1)
+
+	if _, err := q.Acquire(context.Background(), "h"); err != ErrQueueFull {
+		t.Errorf("期望 ErrQueueFull, 实际 %v", err)
+	}
+}
+
+func TestQueue_Timeout(t *testing.T) {
+	q := NewQueue(nil, 1, 10, 30*time.Millisecond)
+
+	release, _ := q.Acquire(context.Background(), "h")
+	defer release()
+
+	if _, err := q.Acquire(context.Background(), "h"); err != ErrQueueTimeout {
+		t.Errorf("期望 ErrQueueTimeout, 实际 %v", err)
+	}
+
+	stats := q.Stats()
+	if len(stats) != 1 || stats[0].TimedOut != 1 || stats[0].Waiting != 0 {
+		t.Errorf("统计不正确: %+v", stats)
+	}
+}
+
+func TestQueue_ContextCanceled(t *testing.T) {
+	q := NewQueue(nil, 1, 10, time.Second)
+
+	release, _ := q.Acquire(context.Background(), "h")
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := q.Acquire(ctx, "h")
+		errc <- err
+	}()
+	waitQueued(t, q, "h", 1)
+	cancel()
+
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("期望 context.Canceled, 实际 %v", err)
+	}
+	if stats := q.Stats(); stats[0].Waiting != 0 || stats[0].TimedOut != 0 {
+		t.Errorf("取消后不应计入排队或超时: %+v", stats)
+	}
+}
+
+// waitQueued 等待指定主机的排队数达到 n
+func waitQueued(t *testing.T, q *Queue, host string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, st := range q.Stats() {
+			if st.Host == host && st.Waiting == n {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s 排队数未达到 %d", host, n)
+}
@@ -0,0 +1,199 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+// State 刷新状态
+type State string
+
+const (
+	StateIdle    State = "idle"
+	StateRunning State = "running"
+	StateFailed  State = "failed"
+)
+
+// Status 刷新协调器状态快照
+type Status struct {
+	State         State     `json:"state"`
+	LastError     string    `json:"lastError,omitempty"`
+	Attempts      int       `json:"attempts"` // 累计实际发起的登录次数
+	Waiters       int       `json:"waiters"`  // 正在等待当前刷新结果的调用方
+	LastStart     time.Time `json:"lastStart,omitzero"`
+	LastFinish    time.Time `json:"lastFinish,omitzero"`
+	CooldownUntil time.Time `json:"cooldownUntil,omitzero"`
+}
+
+// call 一次正在进行的刷新
+type call struct {
+	done chan struct{}
+	err  error
+}
+
+// accountState 单个账号的刷新状态
+type accountState struct {
+	inflight      *call
+	waiters       int
+	attempts      int
+	lastErr       error
+	lastStart     time.Time
+	lastFinish    time.Time
+	cooldownUntil time.Time
+}
+
+// Coordinator 合并并发的刷新请求，同一账号同一时间只运行一次登录流程
+type Coordinator struct {
+	refreshFunc func(ctx context.Context, account string, names ...string) error
+	cooldown    time.Duration
+
+	mu       sync.Mutex
+	accounts map[string]*accountState
+}
+
+// NewCoordinator 创建刷新协调器，refreshFunc 的 names 为指定的登录方式，为空时使用账号配置。
+// cooldown 为刷新失败后拒绝再次发起登录的时长
+func NewCoordinator(refreshFunc func(ctx context.Context, account string, names ...string) error, cooldown time.Duration) *Coordinator {
+	return &Coordinator{
+		refreshFunc: refreshFunc,
+		cooldown:    cooldown,
+		accounts:    make(map[string]*accountState),
+	}
+}
+
+func (c *Coordinator) state(account string) *accountState {
+	st, ok := c.accounts[account]
+	if !ok {
+		st = &accountState{}
+		c.accounts[account] = st
+	}
+	return st
+}
+
+// Refresh 刷新默认账号
+func (c *Coordinator) Refresh() error {
+	return c.RefreshAccount(config.DefaultAccount)
+}
+
+// RefreshAll 依次刷新所有已配置的账号，返回各账号错误的合并
+func (c *Coordinator) RefreshAll() error {
+	var errs []error
+	for _, name := range config.AccountNames() {
+		if err := c.RefreshAccount(name); err != nil {
+			errs = append(errs, fmt.Errorf("账号 %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RefreshAccount 发起或加入指定账号的刷新，并阻塞等待结果
+func (c *Coordinator) RefreshAccount(account string) error {
+	return c.RefreshAccountContext(context.Background(), account)
+}
+
+// RefreshAccountContext 同 RefreshAccount，ctx 中的请求 ID 会写入刷新过程的日志。
+// ctx 只用于传递请求 ID 等值，发起方断开时不会中断登录
+func (c *Coordinator) RefreshAccountContext(ctx context.Context, account string) error {
+	return c.refresh(ctx, account, nil)
+}
+
+// RefreshAccountWith 使用指定的登录方式刷新（如手动扫码），不受失败冷却限制。
+// 已有刷新在进行时同样等待其结果，不会并发启动两个登录流程
+func (c *Coordinator) RefreshAccountWith(ctx context.Context, account string, names ...string) error {
+	return c.refresh(ctx, account, names)
+}
+
+func (c *Coordinator) refresh(ctx context.Context, account string, names []string) error {
+	if account == "" {
+		account = config.DefaultAccount
+	}
+
+	c.mu.Lock()
+	st := c.state(account)
+
+	// 已有刷新在进行，等待其结果
+	if cl := st.inflight; cl != nil {
+		st.waiters++
+		c.mu.Unlock()
+		logger.With(logger.CategoryToken, "account", account).InfoContext(ctx, "已有刷新任务进行中，等待其结果")
+		<-cl.done
+		c.mu.Lock()
+		st.waiters--
+		c.mu.Unlock()
+		return cl.err
+	}
+
+	// 失败冷却期内直接返回上次的错误，手动指定登录方式时除外
+	if len(names) == 0 && st.lastErr != nil && time.Now().Before(st.cooldownUntil) {
+		err := fmt.Errorf("刷新冷却中 (剩余 %v)，上次失败: %w",
+			time.Until(st.cooldownUntil).Round(time.Second), st.lastErr)
+		c.mu.Unlock()
+		return err
+	}
+
+	cl := &call{done: make(chan struct{})}
+	st.inflight = cl
+	st.attempts++
+	st.lastStart = time.Now()
+	c.mu.Unlock()
+
+	cl.err = c.run(context.WithoutCancel(ctx), account, names)
+
+	c.mu.Lock()
+	st.inflight = nil
+	st.lastFinish = time.Now()
+	st.lastErr = cl.err
+	if cl.err != nil {
+		st.cooldownUntil = st.lastFinish.Add(c.cooldown)
+	} else {
+		st.cooldownUntil = time.Time{}
+	}
+	c.mu.Unlock()
+
+	close(cl.done)
+	return cl.err
+}
+
+func (c *Coordinator) run(ctx context.Context, account string, names []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("刷新过程异常: %v", r)
+		}
+	}()
+	return c.refreshFunc(ctx, account, names...)
+}
+
+// Status 返回默认账号的刷新状态
+func (c *Coordinator) Status() Status {
+	return c.AccountStatus(config.DefaultAccount)
+}
+
+// AccountStatus 返回指定账号的刷新状态
+func (c *Coordinator) AccountStatus(account string) Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := c.state(account)
+	status := Status{
+		State:      StateIdle,
+		Attempts:   st.attempts,
+		Waiters:    st.waiters,
+		LastStart:  st.lastStart,
+		LastFinish: st.lastFinish,
+	}
+	if st.lastErr != nil {
+		status.State = StateFailed
+		status.LastError = st.lastErr.Error()
+		status.CooldownUntil = st.cooldownUntil
+	}
+	if st.inflight != nil {
+		status.State = StateRunning
+	}
+	return status
+}
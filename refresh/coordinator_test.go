@@ -0,0 +1,169 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+func TestRefresh_SingleFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := NewCoordinator(func(context.Context, string, ...string) error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Refresh(); err != nil {
+				t.Errorf("刷新不应失败: %v", err)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if st := c.Status(); st.State != StateRunning {
+		t.Errorf("期望 running, 实际 %s", st.State)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("并发刷新应合并为 1 次, 实际 %d 次", calls)
+	}
+	if st := c.Status(); st.State != StateIdle || st.Attempts != 1 {
+		t.Errorf("状态不正确: %+v", st)
+	}
+}
+
+func TestRefresh_Cooldown(t *testing.T) {
+	var calls int32
+	c := NewCoordinator(func(context.Context, string, ...string) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("宝盒未运行")
+	}, time.Hour)
+
+	if err := c.Refresh(); err == nil {
+		t.Fatal("期望返回错误")
+	}
+	if err := c.Refresh(); err == nil {
+		t.Fatal("冷却期内应返回错误")
+	}
+	if calls != 1 {
+		t.Errorf("冷却期内不应再次登录, 实际 %d 次", calls)
+	}
+
+	st := c.Status()
+	if st.State != StateFailed || st.LastError == "" || st.CooldownUntil.IsZero() {
+		t.Errorf("状态不正确: %+v", st)
+	}
+}
+
+func TestRefresh_PerAccount(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]int{}
+	c := NewCoordinator(func(_ context.Context, account string, _ ...string) error {
+		mu.Lock()
+		seen[account]++
+		mu.Unlock()
+		if account == "b" {
+			return errors.New("登录失败")
+		}
+		return nil
+	}, time.Hour)
+
+	c.RefreshAccount("a")
+	c.RefreshAccount("b")
+	c.Refresh()
+
+	if seen["a"] != 1 || seen["b"] != 1 || seen["default"] != 1 {
+		t.Errorf("各账号应独立刷新: %v", seen)
+	}
+	if c.AccountStatus("a").State != StateIdle || c.AccountStatus("b").State != StateFailed {
+		t.Errorf("账号状态应互不影响")
+	}
+}
+
+func TestRefreshAll(t *testing.T) {
+	cfg := config.GetConfig()
+	old := cfg.Accounts
+	cfg.Accounts = []config.AccountConfig{{Name: "a"}, {Name: "b"}}
+	t.Cleanup(func() { cfg.Accounts = old })
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	c := NewCoordinator(func(_ context.Context, account string, _ ...string) error {
+		mu.Lock()
+		seen[account]++
+		mu.Unlock()
+		if account == "b" {
+			return errors.New("登录失败")
+		}
+		return nil
+	}, time.Hour)
+
+	err := c.RefreshAll()
+	if seen["default"] != 1 || seen["a"] != 1 || seen["b"] != 1 {
+		t.Errorf("应刷新所有账号: %v", seen)
+	}
+	if err == nil || !strings.Contains(err.Error(), "账号 b") {
+		t.Errorf("应返回失败账号的错误, 实际 %v", err)
+	}
+}
+
+func TestRefreshAccountWith(t *testing.T) {
+	var got []string
+	fail := true
+	c := NewCoordinator(func(_ context.Context, account string, names ...string) error {
+		got = names
+		if fail {
+			return errors.New("登录失败")
+		}
+		return nil
+	}, time.Hour)
+
+	c.RefreshAccount("a")
+	fail = false
+	if err := c.RefreshAccount("a"); err == nil {
+		t.Error("冷却期内自动刷新应直接返回错误")
+	}
+	if err := c.RefreshAccountWith(context.Background(), "a", "qr"); err != nil {
+		t.Errorf("手动指定登录方式不应受冷却限制: %v", err)
+	}
+	if len(got) != 1 || got[0] != "qr" {
+		t.Errorf("应传递指定的登录方式: %v", got)
+	}
+}
+
+func TestRefreshAccountContext(t *testing.T) {
+	var gotID string
+	var gotErr error
+	c := NewCoordinator(func(ctx context.Context, account string, _ ...string) error {
+		gotID, gotErr = logger.RequestID(ctx), ctx.Err()
+		return nil
+	}, time.Hour)
+
+	ctx, cancel := context.WithCancel(logger.WithRequestID(context.Background(), "req-1"))
+	cancel()
+	if err := c.RefreshAccountContext(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if gotID != "req-1" {
+		t.Errorf("刷新函数应收到发起方的请求 ID, 实际 %q", gotID)
+	}
+	if gotErr != nil {
+		t.Error("发起方取消不应中断刷新")
+	}
+}
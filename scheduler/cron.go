@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 解析后的 cron 表达式（分 时 日 月 周）
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // 位图
+	domAny, dowAny                bool
+	every                         time.Duration // @every 间隔
+}
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	fieldMinute = field{0, 59, nil}
+	fieldHour   = field{0, 23, nil}
+	fieldDom    = field{1, 31, nil}
+	fieldMonth  = field{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	fieldDow = field{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// ParseSchedule 解析调度表达式，支持：
+//   - 标准 5 段 cron："5 0 * * *"、"*/10 8-20 * * mon-fri"
+//   - 描述符：@daily、@hourly、@every 30m
+//   - 每日时间简写："00:05"（兼容旧版 refreshTime/preventTime）
+func ParseSchedule(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("调度表达式为空")
+	}
+
+	if d, ok := strings.CutPrefix(expr, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || every < time.Minute {
+			return nil, fmt.Errorf("无效的间隔 %q (最小 1m)", d)
+		}
+		return &Schedule{every: every}, nil
+	}
+	if std, ok := descriptors[expr]; ok {
+		expr = std
+	}
+	if t, err := time.Parse("15:04", expr); err == nil {
+		expr = fmt.Sprintf("%d %d * * *", t.Minute(), t.Hour())
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron 表达式需要 5 段 (分 时 日 月 周): %q", expr)
+	}
+
+	s := &Schedule{}
+	var err error
+	if s.minute, err = parseField(parts[0], fieldMinute); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(parts[1], fieldHour); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(parts[2], fieldDom); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(parts[3], fieldMonth); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(parts[4], fieldDow); err != nil {
+		return nil, err
+	}
+	// 周日可写作 0 或 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = parts[2] == "*" || parts[2] == "?"
+	s.dowAny = parts[4] == "*" || parts[4] == "?"
+	// 如 "0 0 30 2 *" 这类永远不会触发的表达式
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("调度表达式永远不会触发: %q", expr)
+	}
+	return s, nil
+}
+
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepExpr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的步长: %q", part)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rangeExpr == "*" || rangeExpr == "?":
+		case strings.Contains(rangeExpr, "-"):
+			a, b, _ := strings.Cut(rangeExpr, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := f.value(rangeExpr)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			if hasStep {
+				hi = f.max
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("无效的范围: %q", part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("取值 %q 超出范围 %d-%d", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next 返回 t 之后的下一次执行时间
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Truncate(time.Minute).Add(s.every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 日与周同时限定时满足其一即可（与标准 cron 一致）
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
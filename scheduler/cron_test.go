@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_Next(t *testing.T) {
+	base := time.Date(2025, 12, 25, 10, 7, 30, 0, time.Local) // 周四
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"00:05", time.Date(2025, 12, 26, 0, 5, 0, 0, time.Local)},
+		{"19:30", time.Date(2025, 12, 25, 19, 30, 0, 0, time.Local)},
+		{"* * * * *", time.Date(2025, 12, 25, 10, 8, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2025, 12, 25, 10, 15, 0, 0, time.Local)},
+		{"0 8-20/6 * * *", time.Date(2025, 12, 25, 14, 0, 0, 0, time.Local)},
+		{"30 9 * * mon-fri", time.Date(2025, 12, 26, 9, 30, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2025, 12, 28, 0, 0, 0, 0, time.Local)},
+		{"0 0 1 jan *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"@hourly", time.Date(2025, 12, 25, 11, 0, 0, 0, time.Local)},
+		{"@every 30m", time.Date(2025, 12, 25, 10, 37, 0, 0, time.Local)},
+		// 日与周同时限定时满足其一即可
+		{"0 12 1 * fri", time.Date(2025, 12, 26, 12, 0, 0, 0, time.Local)},
+	}
+	for _, c := range cases {
+		s, err := ParseSchedule(c.spec)
+		if err != nil {
+			t.Errorf("%q: 解析失败: %v", c.spec, err)
+			continue
+		}
+		if got := s.Next(base); !got.Equal(c.want) {
+			t.Errorf("%q: 期望 %v, 实际 %v", c.spec, c.want, got)
+		}
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "25:00", "* * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "@every 10s", "0 0 * * funday", "0 0 30 2 *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q 应解析失败", spec)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+// 内置任务名称
+const (
+	JobTokenRefresh   = "token-refresh"   // 每日定时刷新 (refreshTime)
+	JobTokenPrevent   = "token-prevent"   // 预防型刷新 (preventTime)
+	JobTokenCheck     = "token-check"     // 即将过期时提前刷新
+	JobHistoryCleanup = "history-cleanup" // 清理过期请求历史
+	JobTodoMetrics    = "todo-metrics"    // 采样各账号待办数量
+	JobAlertCheck     = "alert-check"     // 检查告警规则并发送通知
+	JobTokenProbe     = "token-probe"     // 探测登录状态是否已被服务端注销
+)
+
+// JobSnapshotPrefix 数据采集任务名前缀，后接 snapshotJobs 中的 name
+const JobSnapshotPrefix = "snapshot:"
+
+// expireAhead Token 剩余有效期低于该值时提前刷新
+const expireAhead = 30 * time.Minute
+
+// TokenCheckJob 检查所有账号，对 30 分钟内即将失效的 Token 执行提前刷新
+func TokenCheckJob(refreshAccount func(account string) error) func() error {
+	return func() error {
+		var errs []error
+		for _, name := range config.AccountNames() {
+			token := config.GetAccountToken(name)
+			if token == nil || token.ExpiresAt.IsZero() {
+				continue
+			}
+			timeLeft := time.Until(token.ExpiresAt)
+			if timeLeft <= 0 || timeLeft >= expireAhead {
+				continue
+			}
+			logger.Token("检测到账号 %s 的 Token 即将失效 (剩余 %v)，执行提前刷新", name, timeLeft.Round(time.Second))
+			if err := refreshAccount(name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
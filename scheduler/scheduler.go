@@ -1,40 +1,147 @@
 package scheduler
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
-	"zto-api-proxy/config"
 	"zto-api-proxy/logger"
 )
 
+var (
+	ErrJobNotFound = errors.New("任务不存在")
+	ErrJobRunning  = errors.New("任务正在执行")
+)
+
+// 错过执行时间超过该值视为休眠/关机期间错过
+const missGrace = 2 * time.Minute
+
+// Job 定时任务定义
+type Job struct {
+	Name        string
+	Description string
+	Spec        string        // 调度表达式，见 ParseSchedule
+	SpecFunc    func() string // 从配置实时读取表达式，优先于 Spec
+	CatchUp     bool          // 休眠或关机错过执行时间后补跑一次
+	Run         func() error
+}
+
+// JobInfo 任务状态
+type JobInfo struct {
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	Spec         string    `json:"spec"`
+	SpecError    string    `json:"specError,omitempty"`
+	CatchUp      bool      `json:"catchUp"`
+	Running      bool      `json:"running"`
+	NextRun      time.Time `json:"nextRun,omitzero"`
+	LastRun      time.Time `json:"lastRun,omitzero"`
+	LastDuration int64     `json:"lastDuration"` // 毫秒
+	LastResult   string    `json:"lastResult"`   // success | failed，空表示从未执行
+	LastError    string    `json:"lastError,omitempty"`
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+	Skipped      int       `json:"skipped"` // 因上次未结束或错过执行而跳过的次数
+}
+
+type jobState struct {
+	job      Job
+	spec     string
+	loaded   bool
+	schedule *Schedule
+	info     JobInfo
+}
+
 // Scheduler 定时任务调度器
 type Scheduler struct {
-	refreshFunc func() error
-	stopChan    chan struct{}
-	running     bool
+	mu       sync.Mutex
+	jobs     []*jobState
+	stopChan chan struct{}
+	running  bool
+	lastTick time.Time
+	now      func() time.Time
 }
 
 // NewScheduler 创建调度器
-func NewScheduler(refreshFunc func() error) *Scheduler {
+func NewScheduler() *Scheduler {
 	return &Scheduler{
-		refreshFunc: refreshFunc,
-		stopChan:    make(chan struct{}),
+		stopChan: make(chan struct{}),
+		now:      time.Now,
+	}
+}
+
+// AddJob 注册任务，表达式无效时返回错误但任务仍会列出
+func (s *Scheduler) AddJob(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.job.Name == job.Name {
+			return fmt.Errorf("任务已存在: %s", job.Name)
+		}
 	}
+	j := &jobState{job: job, info: JobInfo{
+		Name:        job.Name,
+		Description: job.Description,
+		CatchUp:     job.CatchUp,
+	}}
+	s.jobs = append(s.jobs, j)
+	s.updateSpec(j, s.now())
+	if j.info.SpecError != "" {
+		return fmt.Errorf("任务 %s: %s", job.Name, j.info.SpecError)
+	}
+	return nil
+}
+
+// updateSpec 表达式变化时重新解析并计算下次执行时间
+func (s *Scheduler) updateSpec(j *jobState, now time.Time) {
+	spec := j.job.Spec
+	if j.job.SpecFunc != nil {
+		spec = j.job.SpecFunc()
+	}
+	if j.loaded && spec == j.spec {
+		return
+	}
+
+	j.loaded = true
+	j.spec = spec
+	j.info.Spec = spec
+	j.info.SpecError = ""
+	j.schedule = nil
+	j.info.NextRun = time.Time{}
+
+	if spec == "" || spec == "off" {
+		return // 已禁用
+	}
+	sched, err := ParseSchedule(spec)
+	if err != nil {
+		j.info.SpecError = err.Error()
+		logger.Error("任务 %s 调度表达式无效: %v", j.job.Name, err)
+		return
+	}
+	j.schedule = sched
+	j.info.NextRun = sched.Next(now)
 }
 
 // Start 启动调度器
 func (s *Scheduler) Start() {
+	s.mu.Lock()
 	if s.running {
+		s.mu.Unlock()
 		return
 	}
 	s.running = true
+	s.mu.Unlock()
 
 	go s.run()
 	logger.Info("定时任务调度器已启动")
 }
 
-// Stop 停止调度器
+// Stop 停止调度器，不等待正在执行的任务
 func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if !s.running {
 		return
 	}
@@ -44,57 +151,116 @@ func (s *Scheduler) Stop() {
 }
 
 func (s *Scheduler) run() {
-	// 每分钟检查一次
-	ticker := time.NewTicker(1 * time.Minute)
+	ticker := time.NewTicker(20 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-s.stopChan:
 			return
-		case now := <-ticker.C:
-			s.checkAndExecute(now)
+		case <-ticker.C:
+			s.tick(s.now())
 		}
 	}
 }
 
-func (s *Scheduler) checkAndExecute(now time.Time) {
-	hour := now.Hour()
-	minute := now.Minute()
+// tick 检查并启动到期任务
+func (s *Scheduler) tick(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// 1. 凌晨 00:05 强制全面刷新 (常规维护)
-	if hour == 0 && minute == 5 {
-		logger.Token("执行凌晨定时刷新任务")
-		if err := s.refreshFunc(); err != nil {
-			logger.Error("凌晨定时刷新失败: %v", err)
-		}
-		return
+	// 使用墙上时间比较，休眠期间单调时钟可能不前进
+	now = now.Round(0)
+	if !s.lastTick.IsZero() && now.Sub(s.lastTick) > missGrace {
+		logger.Warn("检测到时间跳变 %v (系统休眠或时钟调整)", now.Sub(s.lastTick).Round(time.Second))
 	}
+	s.lastTick = now
 
-	// 2. 智能预刷新：检查是否即将失效 (提前 30 分钟)
-	token := config.GetTokenData()
-	if token != nil && !token.ExpiresAt.IsZero() {
-		// 距离过期还有多久
-		timeLeft := time.Until(token.ExpiresAt)
-		if timeLeft > 0 && timeLeft < 30*time.Minute {
-			logger.Token("检测到 Token 即将失效 (剩余 %v)，执行提前刷新", timeLeft.Round(time.Second))
-			if err := s.refreshFunc(); err != nil {
-				logger.Error("提前预刷新失败: %v", err)
+	for _, j := range s.jobs {
+		s.updateSpec(j, now)
+		// NextRun 为零表示找不到下一次执行时间，不能当作已到期
+		if j.schedule == nil || j.info.NextRun.IsZero() || now.Before(j.info.NextRun) {
+			continue
+		}
+
+		missed := now.Sub(j.info.NextRun) > missGrace
+		scheduled := j.info.NextRun
+		j.info.NextRun = j.schedule.Next(now)
+
+		if missed {
+			if !j.job.CatchUp {
+				j.info.Skipped++
+				logger.Warn("任务 %s 错过执行时间 %s，跳过", j.job.Name, scheduled.Format("01-02 15:04"))
+				continue
 			}
-			return
+			logger.Info("任务 %s 错过执行时间 %s，补执行", j.job.Name, scheduled.Format("01-02 15:04"))
 		}
+		s.start(j)
 	}
+}
 
-	// 3. 预防型刷新 (针对 wyandyy 10h 周期，如果不幸错过了上面的检查)
-	// 原 19:30 逻辑保留作为双保险
-	if hour == 19 && minute == 30 {
-		logger.Token("执行 19:30 预防型刷新")
-		s.refreshFunc()
+// start 在后台执行任务，上次未结束时跳过（调用方需持有锁）
+func (s *Scheduler) start(j *jobState) error {
+	if j.info.Running {
+		j.info.Skipped++
+		logger.Warn("任务 %s 上次执行尚未结束，跳过本次", j.job.Name)
+		return ErrJobRunning
 	}
+	j.info.Running = true
+	j.info.LastRun = s.now()
+	go s.execute(j)
+	return nil
 }
 
-// TriggerRefresh 手动触发刷新
-func (s *Scheduler) TriggerRefresh() error {
-	logger.Token("手动触发 Token 刷新")
-	return s.refreshFunc()
+func (s *Scheduler) execute(j *jobState) {
+	start := time.Now()
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("任务 panic: %v", r)
+			}
+		}()
+		err = j.job.Run()
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.info.Running = false
+	j.info.LastDuration = time.Since(start).Milliseconds()
+	j.info.Runs++
+	if err != nil {
+		j.info.Failures++
+		j.info.LastResult = "failed"
+		j.info.LastError = err.Error()
+		logger.Error("任务 %s 执行失败: %v", j.job.Name, err)
+		return
+	}
+	j.info.LastResult = "success"
+	j.info.LastError = ""
+}
+
+// RunNow 立即执行任务
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.job.Name == name {
+			logger.Info("手动执行任务 %s", name)
+			return s.start(j)
+		}
+	}
+	return ErrJobNotFound
+}
+
+// Jobs 返回所有任务状态
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]JobInfo, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		s.updateSpec(j, s.now())
+		list = append(list, j.info)
+	}
+	return list
 }
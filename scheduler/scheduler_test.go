@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestScheduler 使用可控时钟的调度器
+func newTestScheduler(now *time.Time) *Scheduler {
+	s := NewScheduler()
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func waitIdle(t *testing.T, s *Scheduler, name string) JobInfo {
+	for i := 0; i < 200; i++ {
+		for _, j := range s.Jobs() {
+			if j.Name == name && !j.Running {
+				return j
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("任务 %s 未结束", name)
+	return JobInfo{}
+}
+
+func TestScheduler_RunsDueJob(t *testing.T) {
+	now := time.Date(2025, 12, 25, 0, 4, 30, 0, time.Local)
+	s := newTestScheduler(&now)
+
+	var runs int32
+	s.AddJob(Job{Name: "refresh", Spec: "00:05", Run: func() error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}})
+
+	s.tick(now)
+	if atomic.LoadInt32(&runs) != 0 {
+		t.Fatal("未到时间不应执行")
+	}
+
+	now = now.Add(time.Minute)
+	s.tick(now)
+	info := waitIdle(t, s, "refresh")
+	if runs != 1 || info.LastResult != "success" {
+		t.Errorf("到期应执行一次: runs=%d info=%+v", runs, info)
+	}
+	if want := time.Date(2025, 12, 26, 0, 5, 0, 0, time.Local); !info.NextRun.Equal(want) {
+		t.Errorf("下次执行时间应为 %v, 实际 %v", want, info.NextRun)
+	}
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	now := time.Date(2025, 12, 25, 0, 0, 0, 0, time.Local)
+	s := newTestScheduler(&now)
+
+	var caught, skipped int32
+	s.AddJob(Job{Name: "catch", Spec: "00:05", CatchUp: true, Run: func() error {
+		atomic.AddInt32(&caught, 1)
+		return nil
+	}})
+	s.AddJob(Job{Name: "skip", Spec: "00:05", Run: func() error {
+		atomic.AddInt32(&skipped, 1)
+		return nil
+	}})
+	s.tick(now)
+
+	// 模拟休眠到早上 8 点
+	now = now.Add(8 * time.Hour)
+	s.tick(now)
+	waitIdle(t, s, "catch")
+
+	if caught != 1 {
+		t.Errorf("CatchUp 任务应补执行一次, 实际 %d", caught)
+	}
+	if skipped != 0 {
+		t.Errorf("非 CatchUp 任务应跳过, 实际执行 %d 次", skipped)
+	}
+	for _, j := range s.Jobs() {
+		if j.Name == "skip" && j.Skipped != 1 {
+			t.Errorf("应记录跳过次数: %+v", j)
+		}
+	}
+}
+
+func TestScheduler_NoOverlap(t *testing.T) {
+	now := time.Now()
+	s := newTestScheduler(&now)
+
+	release := make(chan struct{})
+	var runs int32
+	s.AddJob(Job{Name: "slow", Spec: "* * * * *", Run: func() error {
+		atomic.AddInt32(&runs, 1)
+		<-release
+		return errors.New("boom")
+	}})
+
+	if err := s.RunNow("slow"); err != nil {
+		t.Fatalf("首次执行应成功: %v", err)
+	}
+	if err := s.RunNow("slow"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("执行中再次触发应返回 ErrJobRunning, 实际 %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	s.tick(now)
+
+	close(release)
+	info := waitIdle(t, s, "slow")
+	if runs != 1 || info.Skipped != 2 || info.LastResult != "failed" || info.LastError != "boom" {
+		t.Errorf("执行状态不正确: runs=%d info=%+v", runs, info)
+	}
+
+	if err := s.RunNow("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("不存在的任务应返回 ErrJobNotFound, 实际 %v", err)
+	}
+}
+
+func TestScheduler_SpecFromConfig(t *testing.T) {
+	now := time.Date(2025, 12, 25, 10, 0, 0, 0, time.Local)
+	s := newTestScheduler(&now)
+
+	spec := "19:30"
+	s.AddJob(Job{Name: "prevent", SpecFunc: func() string { return spec }, Run: func() error { return nil }})
+
+	spec = "20:00"
+	jobs := s.Jobs()
+	if want := time.Date(2025, 12, 25, 20, 0, 0, 0, time.Local); !jobs[0].NextRun.Equal(want) {
+		t.Errorf("修改配置后应按新表达式调度: %v", jobs[0].NextRun)
+	}
+
+	spec = "off"
+	if jobs := s.Jobs(); !jobs[0].NextRun.IsZero() {
+		t.Error("off 表示禁用任务")
+	}
+}
+
+func TestScheduler_NeverDueSchedule(t *testing.T) {
+	now := time.Date(2025, 12, 25, 10, 0, 0, 0, time.Local)
+	s := newTestScheduler(&now)
+
+	var runs int32
+	s.AddJob(Job{Name: "never", Spec: "00:05", Run: func() error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}})
+	s.tick(now)
+	// 模拟找不到下一次执行时间的调度（位图全空）
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		j.schedule = &Schedule{}
+		j.info.NextRun = time.Time{}
+	}
+	s.mu.Unlock()
+
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Minute)
+		s.tick(now)
+	}
+	if n := atomic.LoadInt32(&runs); n != 0 {
+		t.Errorf("NextRun 为零的任务不应执行, 实际执行 %d 次", n)
+	}
+}
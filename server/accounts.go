@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/proxy"
+	"zto-api-proxy/refresh"
+)
+
+// accountHeader 指定账号的请求头
+const accountHeader = "X-ZTO-Account"
+
+// AccountStatus 单个账号的状态
+type AccountStatus struct {
+	Name        string             `json:"name"`
+	SiteCode    string             `json:"siteCode"`
+	TokenValid  bool               `json:"tokenValid"`
+	Verified    bool               `json:"serverVerified"` // 当前 Token 已通过服务端探测
+	Probe       *ProbeStatus       `json:"probe,omitempty"`
+	ExpiresAt   string             `json:"expiresAt"`
+	AppExpire   string             `json:"appExpire"`
+	SessExpire  string             `json:"sessExpire"`
+	LastRefresh string             `json:"lastRefresh"`
+	Usage       proxy.AccountUsage `json:"usage"`
+	Refresh     *refresh.Status    `json:"refresh,omitempty"`
+}
+
+// resolveAccount 解析请求头指定的账号，未指定时按策略选择
+func (s *Server) resolveAccount(w http.ResponseWriter, r *http.Request) (config.AccountConfig, bool) {
+	name, err := s.proxyClient.SelectAccount(r.Header.Get(accountHeader))
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, err.Error()+": "+r.Header.Get(accountHeader))
+		return config.AccountConfig{}, false
+	}
+	acc, _ := config.GetAccount(name)
+	return acc, true
+}
+
+func (s *Server) accountStatuses() []AccountStatus {
+	names := config.AccountNames()
+	list := make([]AccountStatus, 0, len(names))
+	for _, name := range names {
+		acc, _ := config.GetAccount(name)
+		st := AccountStatus{
+			Name:       name,
+			SiteCode:   acc.SiteCode,
+			TokenValid: config.IsAccountTokenValid(name),
+		}
+
+		if token := config.GetAccountToken(name); token != nil {
+			st.ExpiresAt = formatTime(token.ExpiresAt)
+			st.AppExpire = formatTime(token.AppExpire)
+			st.SessExpire = formatTime(token.SessExpire)
+			st.LastRefresh = formatTime(token.LastRefresh)
+		}
+		if probe, ok := s.probeStatus(name); ok {
+			st.Probe = &probe
+		}
+		st.Verified = s.serverVerified(name)
+		if s.proxyClient != nil {
+			st.Usage = s.proxyClient.AccountUsage(name)
+		}
+		if s.refresher != nil {
+			rs := s.refresher.AccountStatus(name)
+			st.Refresh = &rs
+		}
+		list = append(list, st)
+	}
+	return list
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"zto-api-proxy/alert"
+	"zto-api-proxy/config"
+	"zto-api-proxy/history"
+	"zto-api-proxy/refresh"
+)
+
+// alertSource 为告警规则提供 Token、刷新、请求历史与待办采样数据
+type alertSource struct {
+	s *Server
+}
+
+// Accounts 跳过从未配置且没有 Token 的默认账号，避免只配置了命名账号时
+// Token 类规则对默认账号一直触发
+func (a alertSource) Accounts() []string {
+	names := config.AccountNames()
+	if len(names) == 1 {
+		return names // 只有默认账号时照常检查
+	}
+	for _, acc := range config.GetConfig().Accounts {
+		if acc.Name == config.DefaultAccount {
+			return names
+		}
+	}
+	if token := config.GetAccountToken(config.DefaultAccount); token != nil && len(token.Cookies) > 0 {
+		return names
+	}
+	return names[1:]
+}
+
+func (a alertSource) Token(account string) (bool, time.Time) {
+	var expiresAt time.Time
+	if token := config.GetAccountToken(account); token != nil {
+		expiresAt = token.ExpiresAt
+	}
+	return config.IsAccountTokenValid(account), expiresAt
+}
+
+func (a alertSource) RefreshError(account string) string {
+	if a.s.refresher == nil {
+		return ""
+	}
+	st := a.s.refresher.AccountStatus(account)
+	if st.State != refresh.StateFailed {
+		return ""
+	}
+	return st.LastError
+}
+
+func (a alertSource) Requests(account string, since time.Time) (int, int) {
+	res, err := a.s.history.Query(history.Query{From: since, Account: account, Size: 1})
+	if err != nil {
+		return 0, 0
+	}
+	return res.Total, res.Errors
+}
+
+func (a alertSource) TodoCount(account, key string, at time.Time) (int, bool) {
+	samples, err := a.s.todoMetrics.Range(at.Add(-24*time.Hour), at, account)
+	if err != nil || len(samples) == 0 {
+		return 0, false
+	}
+	last := samples[len(samples)-1]
+	if key == "" {
+		return last.Total, true
+	}
+	n, ok := last.Counts[key]
+	return n, ok
+}
+
+// CheckAlerts 检查告警规则，由定时任务调用
+func (s *Server) CheckAlerts() error {
+	return s.alerts.Check()
+}
+
+// 当前告警及规则
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	rules, hooks, _ := config.GetAlertSettings()
+	names := make([]string, 0, len(hooks))
+	for _, h := range hooks {
+		names = append(names, h.Name)
+	}
+	s.jsonResponse(w, map[string]interface{}{
+		"active":   s.alerts.Active(),
+		"rules":    rules,
+		"webhooks": names,
+	})
+}
+
+// 发送测试告警
+func (s *Server) handleTestAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 POST 方法")
+		return
+	}
+	var req struct {
+		Webhook string `json:"webhook"` // 为空时发送到全部渠道
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的请求格式: "+err.Error())
+			return
+		}
+	}
+
+	err := s.alerts.Test(req.Webhook)
+	switch {
+	case errors.Is(err, alert.ErrWebhookNotFound):
+		s.jsonError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		s.jsonError(w, http.StatusBadGateway, err.Error())
+	default:
+		s.jsonResponse(w, map[string]interface{}{"success": true, "message": "测试消息已发送"})
+	}
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"zto-api-proxy/config"
+)
+
+type ctxKey int
+
+const ctxCaller ctxKey = iota
+
+// roleAny 任意有效 Key 均可访问
+const roleAny = "*"
+
+// localCaller 本机免鉴权访问时记录的调用方
+const localCaller = "local"
+
+// requiredRole 返回访问路径所需的角色，空字符串表示公开访问
+func requiredRole(path string) string {
+	switch {
+	case path == "/health":
+		return ""
+	case path == "/proxy":
+		return config.RoleProxy
+	case path == "/refresh" || strings.HasPrefix(path, "/admin/"):
+		return config.RoleAdmin
+	case path == "/orders" || strings.HasPrefix(path, "/orders/") ||
+		path == "/province-report" || strings.HasPrefix(path, "/api/") ||
+		strings.HasPrefix(path, "/metrics/"):
+		return config.RoleQuery
+	case path == "/status":
+		return roleAny
+	default:
+		return "" // 静态控制面板
+	}
+}
+
+// requestKey 从请求头或查询参数中提取 API Key
+func requestKey(r *http.Request) string {
+	if k := r.Header.Get("X-API-Key"); k != "" {
+		return k
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+func isLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLocalHost 判断 Host 是否为本机名称
+func isLocalHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// sameOrigin 判断请求是否来自控制面板自身或非浏览器客户端。
+// 其他网页发起的请求 Origin 与 Host 不一致；经 DNS 重绑定访问本机时 Host 不是本机名称
+func sameOrigin(r *http.Request) bool {
+	if isLoopback(r) && !isLocalHost(r.Host) {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// jsonContentType 管理接口的 POST 只接受 JSON (原始数据可用 octet-stream)，
+// 拒绝表单、text/plain 等浏览器无需预检即可跨站提交的类型
+func jsonContentType(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json" || mediaType == "application/octet-stream"
+}
+
+// postOnlyPaths 会修改状态的管理接口，只接受 POST，避免 <img src> 之类的跨站 GET 触发
+var postOnlyPaths = map[string]bool{
+	"/refresh":               true,
+	"/admin/open-logs":       true,
+	"/admin/open-debug":      true,
+	"/admin/save-config":     true,
+	"/admin/clear-logs":      true,
+	"/admin/api-keys/delete": true,
+	"/admin/jobs/run":        true,
+	"/admin/snapshots/run":   true,
+	"/admin/alerts/test":     true,
+	"/admin/token/import":    true,
+}
+
+// 中间件：鉴权
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := requiredRole(r.URL.Path)
+		if role == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if postOnlyPaths[r.URL.Path] && r.Method != http.MethodPost {
+			s.jsonError(w, http.StatusMethodNotAllowed, "只支持 POST 方法")
+			return
+		}
+		if role == config.RoleAdmin && r.Method == http.MethodPost && !jsonContentType(r) {
+			s.jsonError(w, http.StatusUnsupportedMediaType, "管理接口只接受 Content-Type: application/json")
+			return
+		}
+
+		key, ok := config.FindAPIKey(requestKey(r))
+		// 其他网页借用本机浏览器发起的请求必须携带 Key，不享受本机免鉴权或未启用鉴权
+		if !ok && !sameOrigin(r) {
+			s.jsonError(w, http.StatusForbidden, "跨站请求需要携带 API Key")
+			return
+		}
+		if !config.AuthEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !ok {
+			if config.GetConfig().AuthAllowLocal && isLoopback(r) {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxCaller, localCaller)))
+				return
+			}
+			s.jsonError(w, http.StatusUnauthorized, "缺少或无效的 API Key")
+			return
+		}
+		if role != roleAny && !key.HasRole(role) {
+			s.jsonError(w, http.StatusForbidden, "API Key 无权访问 (需要 "+role+" 角色)")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxCaller, key.Name)))
+	})
+}
+
+// callerName 返回发起请求的 API Key 名称
+func callerName(r *http.Request) string {
+	if name, ok := r.Context().Value(ctxCaller).(string); ok {
+		return name
+	}
+	return ""
+}
+
+// API Key 列表 / 创建
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		s.jsonResponse(w, config.ListAPIKeys())
+	case "POST":
+		var req struct {
+			Name  string   `json:"name"`
+			Roles []string `json:"roles"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的请求格式: "+err.Error())
+			return
+		}
+		if req.Name == "" {
+			s.jsonError(w, http.StatusBadRequest, "name 是必需的")
+			return
+		}
+		key, info, err := config.AddAPIKey(req.Name, req.Roles)
+		if err != nil {
+			s.jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.jsonResponse(w, map[string]interface{}{
+			"success": true,
+			"key":     key, // 明文只返回这一次
+			"info":    info,
+		})
+	default:
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 GET/POST 方法")
+	}
+}
+
+// 删除 API Key
+func (s *Server) handleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 POST 方法")
+		return
+	}
+	var req struct {
+		ID    string `json:"id"`
+		Force bool   `json:"force"` // 确认删除最后一个 Key 并关闭鉴权
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		s.jsonError(w, http.StatusBadRequest, "id 是必需的")
+		return
+	}
+	if err := config.DeleteAPIKey(req.ID, req.Force); err != nil {
+		if errors.Is(err, config.ErrLastAPIKey) {
+			s.jsonError(w, http.StatusConflict, err.Error()+"；确认请传 force=true")
+			return
+		}
+		s.jsonError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.jsonResponse(w, map[string]interface{}{"success": true, "authEnabled": config.AuthEnabled()})
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"zto-api-proxy/config"
+)
+
+func withAPIKeys(t *testing.T, keys []config.APIKey) {
+	cfg := config.GetConfig()
+	old := cfg.APIKeys
+	cfg.APIKeys = keys
+	t.Cleanup(func() { cfg.APIKeys = old })
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	withAPIKeys(t, []config.APIKey{
+		{Name: "dashboard", Key: "query-key", Roles: []string{config.RoleQuery}},
+		{Name: "ops", Key: "admin-key", Roles: []string{config.RoleAdmin}},
+	})
+
+	srv := NewServer(nil, nil)
+	var caller string
+	handler := srv.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller = callerName(r)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cases := []struct {
+		path, key string
+		want      int
+	}{
+		{"/health", "", http.StatusOK},
+		{"/orders/todo", "", http.StatusUnauthorized},
+		{"/orders/todo", "wrong", http.StatusUnauthorized},
+		{"/orders/todo", "query-key", http.StatusOK},
+		{"/proxy", "query-key", http.StatusForbidden},
+		{"/admin/config", "query-key", http.StatusForbidden},
+		{"/proxy", "admin-key", http.StatusOK},
+		{"/status", "query-key", http.StatusOK},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.path, nil)
+		if c.key != "" {
+			req.Header.Set("X-API-Key", c.key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("%s (key=%q): 期望 %d, 实际 %d", c.path, c.key, c.want, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/orders?api_key=admin-key", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if caller != "ops" {
+		t.Errorf("期望记录调用方 ops, 实际 %q", caller)
+	}
+}
+
+func TestAuthMiddleware_Loopback(t *testing.T) {
+	withAPIKeys(t, []config.APIKey{{Name: "ops", Key: "admin-key", Roles: []string{config.RoleAdmin}}})
+
+	srv := NewServer(nil, nil)
+	handler := srv.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	newReq := func(host, origin, contentType string) *http.Request {
+		req := httptest.NewRequest("POST", "/refresh", nil)
+		req.RemoteAddr = "127.0.0.1:50000"
+		req.Host = host
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req
+	}
+	// 不带 Origin 的 GET，相当于跨站页面里的 <img src>
+	newGet := func(path string) *http.Request {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "127.0.0.1:50000"
+		req.Host = "127.0.0.1:8765"
+		return req
+	}
+
+	cases := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"本机免鉴权", newReq("127.0.0.1:8765", "", "application/json"), http.StatusOK},
+		{"控制面板同源", newReq("localhost:8765", "http://localhost:8765", "application/json"), http.StatusOK},
+		{"跨站网页", newReq("127.0.0.1:8765", "http://evil.example", "application/json"), http.StatusForbidden},
+		{"DNS 重绑定", newReq("evil.example:8765", "", "application/json"), http.StatusForbidden},
+		{"text/plain 简单请求", newReq("127.0.0.1:8765", "", "text/plain"), http.StatusUnsupportedMediaType},
+		{"缺少 Content-Type", newReq("127.0.0.1:8765", "", ""), http.StatusUnsupportedMediaType},
+		{"GET 清空历史", newGet("/admin/clear-logs"), http.StatusMethodNotAllowed},
+		{"GET 打开日志目录", newGet("/admin/open-logs"), http.StatusMethodNotAllowed},
+		{"GET 只读接口", newGet("/admin/config"), http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, c.req)
+		if w.Code != c.status {
+			t.Errorf("%s: 期望 %d, 实际 %d", c.name, c.status, w.Code)
+		}
+	}
+}
+
+func TestAuthMiddleware_CrossOriginWithoutAuth(t *testing.T) {
+	withAPIKeys(t, nil)
+
+	srv := NewServer(nil, nil)
+	handler := srv.corsMiddleware(srv.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	req := httptest.NewRequest("GET", "/proxy", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("未启用鉴权时跨站请求也应被拒绝, 实际 %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/admin/config", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("管理接口不应返回跨域响应头")
+	}
+	req = httptest.NewRequest("GET", "/status", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("非管理接口应保留跨域响应头")
+	}
+}
+
+func TestHandleGetConfig_Redacted(t *testing.T) {
+	withAPIKeys(t, []config.APIKey{
+		{ID: "a", Name: "manual", Key: "plain-key", Roles: []string{config.RoleAdmin}},
+		{ID: "b", Name: "generated", KeyHash: "hash", Prefix: "zk_123", Roles: []string{config.RoleQuery}},
+	})
+	cfg := config.GetConfig()
+	oldHooks := cfg.AlertWebhooks
+	cfg.AlertWebhooks = []config.AlertWebhook{{
+		Name: "robot", Type: "dingtalk", URL: "https://oapi.dingtalk.com/robot/send?access_token=tok",
+		Secret: "SEC123", Headers: map[string]string{"Authorization": "Bearer hook"},
+	}}
+	t.Cleanup(func() { cfg.AlertWebhooks = oldHooks })
+
+	srv := NewServer(nil, nil)
+	w := httptest.NewRecorder()
+	srv.handleGetConfig(w, httptest.NewRequest("GET", "/admin/config", nil))
+
+	body := w.Body.String()
+	for _, secret := range []string{"plain-key", `"hash"`, "access_token=tok", "SEC123", "Bearer hook"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("配置接口泄露了敏感信息 %q", secret)
+		}
+	}
+	if !strings.Contains(body, "zk_123") {
+		t.Error("应保留 Key 前缀用于展示")
+	}
+	if cfg.APIKeys[0].Key != "plain-key" || cfg.AlertWebhooks[0].Secret != "SEC123" {
+		t.Error("脱敏不应修改原配置")
+	}
+}
+
+func TestHandleDeleteAPIKey_Last(t *testing.T) {
+	withAPIKeys(t, []config.APIKey{{ID: "only", Name: "ops", Key: "admin-key", Roles: []string{config.RoleAdmin}}})
+
+	srv := NewServer(nil, nil)
+	w := httptest.NewRecorder()
+	srv.handleDeleteAPIKey(w, httptest.NewRequest("POST", "/admin/api-keys/delete", strings.NewReader(`{"id":"only"}`)))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("删除最后一个 Key 应返回 409, 实际 %d", w.Code)
+	}
+	if !config.AuthEnabled() {
+		t.Error("未确认时不应关闭鉴权")
+	}
+}
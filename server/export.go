@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"zto-api-proxy/export"
+	"zto-api-proxy/logger"
+	"zto-api-proxy/proxy"
+)
+
+// exportFormat 解析 format 参数，为空表示返回 JSON
+func (s *Server) exportFormat(w http.ResponseWriter, query url.Values) (string, bool) {
+	format := query.Get("format")
+	if format == "" || format == "json" {
+		return "", true
+	}
+	if !export.ValidFormat(format) {
+		s.jsonError(w, http.StatusBadRequest, "不支持的导出格式: "+format+" (可选 csv/xlsx/ndjson)")
+		return "", false
+	}
+	return format, true
+}
+
+// writeResult 按 format 输出 JSON 或导出文件，columns 参数指定列及顺序
+func (s *Server) writeResult(w http.ResponseWriter, r *http.Request, resp *proxy.ProxyResponse, format, name string) {
+	if format == "" || !resp.Success {
+		s.proxyResponse(w, resp)
+		return
+	}
+
+	rows := export.ExtractRows(resp.Data)
+	table := export.NewTable(rows, export.ParseColumns(r.URL.Query().Get("columns")))
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if err := export.Write(w, format, table); err != nil {
+		logger.With(logger.CategoryAPI).ErrorContext(r.Context(), "导出失败", "file", filename, "error", err)
+		return
+	}
+	logger.With(logger.CategoryAPI).InfoContext(r.Context(), "导出完成",
+		"file", filename, "rows", len(table.Rows), "columns", len(table.Columns))
+}
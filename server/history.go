@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/history"
+	"zto-api-proxy/logger"
+	"zto-api-proxy/proxy"
+)
+
+// openHistory 打开 DataDir/history 下的请求历史存储
+func openHistory() *history.Store {
+	cfg := config.GetConfig()
+	return history.Open(filepath.Join(cfg.DataDir, "history"),
+		cfg.HistoryRetentionDays, int64(cfg.HistoryMaxSizeMB)<<20)
+}
+
+// addHistory 记录一次代理请求
+func (s *Server) addHistory(w http.ResponseWriter, r *http.Request, req *proxy.ProxyRequest, resp *proxy.ProxyResponse, duration int64) {
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	rec := history.Record{
+		RequestID:    logger.RequestID(r.Context()),
+		Time:         time.Now(),
+		Method:       method,
+		URL:          req.URL,
+		Caller:       callerName(r),
+		Account:      resp.Account,
+		StatusCode:   resp.StatusCode,
+		Duration:     duration,
+		RequestSize:  resp.RequestSize,
+		ResponseSize: resp.ResponseSize,
+		Cache:        w.Header().Get("X-Cache"),
+		Error:        resp.Error,
+	}
+	if err := s.history.Add(rec); err != nil {
+		logger.With(logger.CategoryAPI).WarnContext(r.Context(), "保存请求历史失败", "error", err)
+	}
+}
+
+// parseHistoryQuery 解析查询参数，时间支持 RFC3339、"2006-01-02 15:04:05" 与 "2006-01-02"
+func parseHistoryQuery(values url.Values) (history.Query, error) {
+	q := history.Query{
+		Status:    values.Get("status"),
+		Path:      values.Get("path"),
+		Text:      values.Get("q"),
+		Caller:    values.Get("caller"),
+		Account:   values.Get("account"),
+		RequestID: values.Get("requestId"),
+	}
+	q.Page, _ = strconv.Atoi(values.Get("page"))
+	q.Size, _ = strconv.Atoi(values.Get("size"))
+	if q.Size > 500 {
+		q.Size = 500
+	}
+
+	var err error
+	if v := values.Get("from"); v != "" {
+		if q.From, err = parseTime(v, false); err != nil {
+			return q, err
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if q.To, err = parseTime(v, true); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+func parseTime(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", v, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err == nil && endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, err
+}
+
+// 查询请求历史
+func (s *Server) handleRequestLogs(w http.ResponseWriter, r *http.Request) {
+	q, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, "无效的时间参数: "+err.Error())
+		return
+	}
+	result, err := s.history.Query(q)
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.jsonResponse(w, result)
+}
+
+// 清空历史记录
+func (s *Server) handleClearLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 POST 方法")
+		return
+	}
+	if err := s.history.Clear(); err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.jsonResponse(w, map[string]bool{"success": true})
+}
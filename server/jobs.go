@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"zto-api-proxy/scheduler"
+)
+
+// SetScheduler 关联定时任务调度器，用于 /admin/jobs
+func (s *Server) SetScheduler(sched *scheduler.Scheduler) {
+	s.scheduler = sched
+}
+
+// PruneHistory 按保留策略清理请求历史
+func (s *Server) PruneHistory() error {
+	s.history.Prune()
+	return nil
+}
+
+// 定时任务列表
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if s.scheduler == nil {
+		s.jsonResponse(w, []scheduler.JobInfo{})
+		return
+	}
+	s.jsonResponse(w, s.scheduler.Jobs())
+}
+
+// 立即执行定时任务
+func (s *Server) handleRunJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 POST 方法")
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		s.jsonError(w, http.StatusBadRequest, "name 是必需的")
+		return
+	}
+	s.runJob(w, req.Name)
+}
+
+// runJob 通过调度器立即执行任务，与定时执行共用运行状态，避免重复运行
+func (s *Server) runJob(w http.ResponseWriter, name string) {
+	if s.scheduler == nil {
+		s.jsonError(w, http.StatusServiceUnavailable, "调度器未启动")
+		return
+	}
+
+	err := s.scheduler.RunNow(name)
+	switch {
+	case errors.Is(err, scheduler.ErrJobNotFound):
+		s.jsonError(w, http.StatusNotFound, err.Error()+": "+name)
+	case errors.Is(err, scheduler.ErrJobRunning):
+		s.jsonError(w, http.StatusConflict, err.Error())
+	default:
+		s.jsonResponse(w, map[string]interface{}{"success": true, "message": "任务已开始执行"})
+	}
+}
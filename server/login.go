@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"zto-api-proxy/browser"
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+)
+
+// 扫码登录状态
+const (
+	qrStarting = "starting" // 正在打开登录页
+	qrWaiting  = "waiting"  // 二维码已生成，等待扫码
+	qrSuccess  = "success"
+	qrFailed   = "failed"
+)
+
+// qrLogin 账号最近一次扫码登录
+type qrLogin struct {
+	Account   string    `json:"account"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt,omitzero"` // 二维码最近刷新时间
+	Image     []byte    `json:"-"`
+}
+
+// SetBrowser 关联浏览器自动化实例，用于查看登录过程与扫码登录
+func (s *Server) SetBrowser(b *browser.Browser) {
+	s.browser = b
+	b.SetQRHandler(s.PublishQR)
+}
+
+// PublishQR 保存最新的登录二维码供控制面板展示，并推送到配置的渠道
+func (s *Server) PublishQR(account string, png []byte) {
+	now := time.Now()
+	s.qrLock.Lock()
+	st, ok := s.qrLogins[account]
+	if !ok || st.Status == qrSuccess || st.Status == qrFailed {
+		// 自动刷新回退到扫码登录时不经过 handleLoginQR
+		st = &qrLogin{Account: account, StartedAt: now}
+		s.qrLogins[account] = st
+	}
+	st.Status = qrWaiting
+	st.Image = png
+	st.UpdatedAt = now
+	s.qrLock.Unlock()
+
+	if hooks := config.GetConfig().LoginQRWebhooks; len(hooks) > 0 {
+		go func() {
+			text := "账号: " + account + "\n请使用中通宝盒 App 扫码登录，二维码约 1 分钟后失效"
+			if err := s.alerts.PushImage(hooks, "扫码登录", text, png); err != nil {
+				logger.Warn("推送登录二维码失败: %v", err)
+			}
+		}()
+	}
+}
+
+// qrStatus 返回账号扫码登录状态的副本，等待中的登录若已拿到新 Token 视为成功
+func (s *Server) qrStatus(account string) (qrLogin, bool) {
+	s.qrLock.Lock()
+	defer s.qrLock.Unlock()
+	st, ok := s.qrLogins[account]
+	if !ok {
+		return qrLogin{}, false
+	}
+	if st.Status == qrWaiting {
+		if token := config.GetAccountToken(account); token != nil && token.LastRefresh.After(st.StartedAt) && config.IsAccountTokenValid(account) {
+			st.Status = qrSuccess
+		}
+	}
+	return *st, true
+}
+
+func (s *Server) finishQR(account string, err error) {
+	s.qrLock.Lock()
+	defer s.qrLock.Unlock()
+	st := s.qrLogins[account]
+	if st == nil {
+		return
+	}
+	st.Status = qrSuccess
+	if err != nil {
+		st.Status = qrFailed
+		st.Error = err.Error()
+	}
+	st.Image = nil
+}
+
+// 扫码登录: POST 发起，GET 查询状态与二维码 (format=png 直接返回图片)
+func (s *Server) handleLoginQR(w http.ResponseWriter, r *http.Request) {
+	account := r.URL.Query().Get("account")
+	if account == "" {
+		account = config.DefaultAccount
+	}
+	if !config.HasAccount(account) {
+		s.jsonError(w, http.StatusBadRequest, "未知账号: "+account)
+		return
+	}
+	if s.browser == nil {
+		s.jsonError(w, http.StatusServiceUnavailable, "浏览器模块未启用")
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		s.startQRLogin(w, r, account)
+	case "GET":
+		st, ok := s.qrStatus(account)
+		if !ok {
+			s.jsonError(w, http.StatusNotFound, "账号未发起扫码登录: "+account)
+			return
+		}
+		if r.URL.Query().Get("format") == "png" {
+			if len(st.Image) == 0 {
+				s.jsonError(w, http.StatusNotFound, "二维码尚未生成")
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write(st.Image)
+			return
+		}
+		resp := map[string]interface{}{"login": st}
+		if len(st.Image) > 0 {
+			resp["image"] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(st.Image)
+		}
+		s.jsonResponse(w, resp)
+	default:
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 GET 或 POST 方法")
+	}
+}
+
+// startQRLogin 后台启动扫码登录，同一账号同时只进行一次
+func (s *Server) startQRLogin(w http.ResponseWriter, r *http.Request, account string) {
+	s.qrLock.Lock()
+	if st, ok := s.qrLogins[account]; ok && (st.Status == qrStarting || st.Status == qrWaiting) {
+		s.qrLock.Unlock()
+		s.jsonError(w, http.StatusConflict, "该账号已有扫码登录进行中")
+		return
+	}
+	if s.refresher == nil {
+		s.qrLock.Unlock()
+		s.jsonError(w, http.StatusServiceUnavailable, "刷新模块未启用")
+		return
+	}
+	st := &qrLogin{Account: account, Status: qrStarting, StartedAt: time.Now()}
+	s.qrLogins[account] = st
+	login := *st
+	s.qrLock.Unlock()
+
+	// 登录在请求结束后继续进行，只沿用请求 ID
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		// 经由刷新协调器，避免与自动刷新同时操作同一账号的浏览器
+		err := s.refresher.RefreshAccountWith(ctx, account, browser.StrategyQR)
+		if err != nil {
+			logger.With(logger.CategoryToken, "account", account).WarnContext(ctx, "扫码登录失败", "error", err)
+		}
+		s.finishQR(account, err)
+	}()
+
+	s.jsonResponse(w, map[string]interface{}{"success": true, "message": "已发起扫码登录，请稍候获取二维码", "login": login})
+}
+
+// 登录诊断包列表，account 为空时列出全部账号
+func (s *Server) handleLoginDiagnostics(w http.ResponseWriter, r *http.Request) {
+	files, err := browser.DiagnosticsStore().List(r.URL.Query().Get("account"))
+	if err != nil {
+		s.jsonError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.jsonResponse(w, files)
+}
+
+// 下载登录诊断包
+func (s *Server) handleDownloadDiagnostics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	path, err := browser.DiagnosticsStore().Path(query.Get("account"), query.Get("file"))
+	if err != nil {
+		s.jsonError(w, http.StatusNotFound, "诊断包不存在")
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(path)))
+	http.ServeFile(w, r, path)
+}
+
+// 最近一次刷新的登录步骤记录
+func (s *Server) handleLoginTimeline(w http.ResponseWriter, r *http.Request) {
+	account := r.URL.Query().Get("account")
+	if account == "" {
+		account = config.DefaultAccount
+	}
+	if s.browser == nil {
+		s.jsonError(w, http.StatusServiceUnavailable, "浏览器模块未启用")
+		return
+	}
+	tl, ok := s.browser.LastTimeline(account)
+	if !ok {
+		s.jsonError(w, http.StatusNotFound, "账号尚未刷新过: "+account)
+		return
+	}
+	s.jsonResponse(w, tl)
+}
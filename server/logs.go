@@ -0,0 +1,278 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/history"
+	"zto-api-proxy/logger"
+)
+
+// logLevelRequest 修改日志级别，Category 为空时修改默认级别，Level 为空时恢复默认
+type logLevelRequest struct {
+	Category string `json:"category"`
+	Level    string `json:"level"`
+}
+
+// handleLogLevels GET 查看各分类日志级别，POST 运行时修改 (重启后恢复为配置文件中的设置)
+func (s *Server) handleLogLevels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req logLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的请求参数")
+			return
+		}
+		if req.Category != "" && !knownCategory(req.Category) {
+			s.jsonError(w, http.StatusBadRequest, "未知的日志分类: "+req.Category)
+			return
+		}
+		if req.Level == "" && req.Category != "" {
+			logger.ResetLevel(req.Category)
+		} else {
+			level, err := logger.ParseLevel(req.Level)
+			if err != nil {
+				s.jsonError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			logger.SetLevel(req.Category, level)
+		}
+		logger.Info("日志级别已修改: category=%s level=%s", req.Category, req.Level)
+	default:
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 GET/POST")
+		return
+	}
+
+	def, levels := logger.Levels()
+	s.jsonResponse(w, map[string]interface{}{"default": def, "categories": levels})
+}
+
+func knownCategory(name string) bool {
+	for _, c := range logger.Categories {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// logFilter 实时日志过滤条件
+type logFilter struct {
+	level      logger.LogLevel
+	categories map[string]bool // 为空表示全部分类
+}
+
+func parseLogFilter(r *http.Request) (logFilter, error) {
+	f := logFilter{level: logger.DEBUG}
+	if v := r.URL.Query().Get("level"); v != "" {
+		level, err := logger.ParseLevel(v)
+		if err != nil {
+			return f, err
+		}
+		f.level = level
+	}
+	if v := r.URL.Query().Get("category"); v != "" {
+		f.categories = make(map[string]bool)
+		for _, c := range strings.Split(v, ",") {
+			f.categories[strings.ToLower(strings.TrimSpace(c))] = true
+		}
+	}
+	return f, nil
+}
+
+func (f logFilter) match(e logger.Entry) bool {
+	if level, err := logger.ParseLevel(e.Level); err == nil && level < f.level {
+		return false
+	}
+	return f.categories == nil || f.categories[strings.ToLower(e.Category)]
+}
+
+// sseHeartbeat 空闲时发送注释行，避免代理或浏览器断开连接
+const sseHeartbeat = 15 * time.Second
+
+// handleLogStream 以 SSE 推送实时日志。
+// 支持 level (最低级别)、category (逗号分隔) 过滤，tail 指定首次连接时补发的条数，
+// 断线重连时按 Last-Event-ID (或 lastEventId 参数) 补发内存中保留的后续日志
+func (s *Server) handleLogStream(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLogFilter(r)
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	since, _ := strconv.ParseUint(lastID, 10, 64)
+	tail, _ := strconv.Atoi(r.URL.Query().Get("tail"))
+
+	backlog, entries, cancel := logger.Subscribe(since, tail)
+	defer cancel()
+	if entries == nil {
+		s.jsonError(w, http.StatusServiceUnavailable, "日志系统未初始化")
+		return
+	}
+
+	// 长连接不受服务器写超时限制
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(e logger.Entry) error {
+		if !filter.match(e) {
+			return nil
+		}
+		data, _ := json.Marshal(e)
+		_, err := fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", e.ID, data)
+		return err
+	}
+	for _, e := range backlog {
+		if send(e) != nil {
+			return
+		}
+	}
+	fmt.Fprint(w, "retry: 3000\n\n")
+	rc.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-entries:
+			if !ok {
+				// 处理过慢被断开，客户端会携带最后的 ID 重连
+				return
+			}
+			if send(e) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// logsDir 运行日志目录
+func logsDir() string {
+	return filepath.Join(config.GetConfig().DataDir, "logs")
+}
+
+// handleLogSearch 检索历史日志 (含已压缩的归档)。
+// 参数: from / to (时间或日期)、level (最低级别)、category、q (关键字)、limit (默认 200，最多 2000)
+func (s *Server) handleLogSearch(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	q := logger.Query{Category: values.Get("category"), Text: values.Get("q")}
+	q.Limit, _ = strconv.Atoi(values.Get("limit"))
+	if q.Limit > 2000 {
+		q.Limit = 2000
+	}
+	var err error
+	if v := values.Get("level"); v != "" {
+		if q.Level, err = logger.ParseLevel(v); err != nil {
+			s.jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if v := values.Get("from"); v != "" {
+		if q.From, err = parseTime(v, false); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的时间参数: "+err.Error())
+			return
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if q.To, err = parseTime(v, true); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的时间参数: "+err.Error())
+			return
+		}
+	}
+
+	res, err := logger.Search(logsDir(), q)
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.jsonResponse(w, res)
+}
+
+// validRequestID 接受的外部请求 ID：1-64 位字母、数字与 . _ : -
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("._:-", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID 生成 16 位十六进制请求 ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// handleRequestTrace 按请求 ID 查询相关的全部日志与请求历史。
+// 默认检索最近 7 天，可用 from / to 指定范围
+func (s *Server) handleRequestTrace(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	id := values.Get("id")
+	if !validRequestID(id) {
+		s.jsonError(w, http.StatusBadRequest, "无效的请求 ID")
+		return
+	}
+	from := time.Now().AddDate(0, 0, -6)
+	q := logger.Query{RequestID: id, Limit: 2000, From: time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)}
+	var err error
+	if v := values.Get("from"); v != "" {
+		if q.From, err = parseTime(v, false); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的时间参数: "+err.Error())
+			return
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if q.To, err = parseTime(v, true); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的时间参数: "+err.Error())
+			return
+		}
+	}
+
+	res, err := logger.Search(logsDir(), q)
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	records, err := s.history.Query(history.Query{RequestID: id, From: q.From, To: q.To, Size: 10})
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.jsonResponse(w, map[string]interface{}{
+		"requestId": id,
+		"lines":     res.Lines,
+		"truncated": res.Truncated,
+		"history":   records.Records,
+	})
+}
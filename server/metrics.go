@@ -0,0 +1,126 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+	"zto-api-proxy/metrics"
+	"zto-api-proxy/proxy"
+)
+
+// openTodoMetrics 打开 DataDir/metrics/todo 下的待办采样存储
+func openTodoMetrics() *metrics.Store {
+	cfg := config.GetConfig()
+	return metrics.Open(filepath.Join(cfg.DataDir, "metrics", "todo"), cfg.TodoMetricsRetentionDays)
+}
+
+func defaultTodoBody() map[string]interface{} {
+	return map[string]interface{}{
+		"traceQueryChannel": "ALL_PICK_CHANNEL",
+	}
+}
+
+// todoRequest 构造待办中心查询请求
+func todoRequest(account string, body interface{}) *proxy.ProxyRequest {
+	return &proxy.ProxyRequest{
+		URL:     "https://preorder-query-center.gw.zt-express.com/preOrderQuery/getTodoCenterList",
+		Method:  "POST",
+		Body:    body,
+		Account: account,
+	}
+}
+
+// CollectTodo 采样所有 Token 有效账号的待办数量
+func (s *Server) CollectTodo() error {
+	var errs []error
+	for _, name := range config.AccountNames() {
+		if !config.IsAccountTokenValid(name) {
+			continue
+		}
+		acc, _ := config.GetAccount(name)
+		req := todoRequest(name, defaultTodoBody())
+
+		resp, _ := s.proxyClient.DoCached(req, true)
+		if !resp.Success {
+			errs = append(errs, fmt.Errorf("账号 %s 查询待办失败: %s", name, resp.Error))
+			continue
+		}
+		sample := metrics.NewSample(name, acc.SiteCode, metrics.ExtractCounts(resp.Data), time.Now())
+		if err := s.todoMetrics.Add(sample); err != nil {
+			errs = append(errs, fmt.Errorf("保存待办采样失败: %v", err))
+			continue
+		}
+		logger.Debug("账号 %s 待办数量: %d", name, sample.Total)
+	}
+	return errors.Join(errs...)
+}
+
+// 待办数量时间序列，默认最近 24 小时，step 为聚合粒度 (如 15m、1h)
+func (s *Server) handleTodoMetrics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = parseTime(v, false); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的 from: "+err.Error())
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = parseTime(v, true); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的 to: "+err.Error())
+			return
+		}
+	}
+	var step time.Duration
+	if v := query.Get("step"); v != "" {
+		if step, err = time.ParseDuration(v); err != nil || step < time.Minute {
+			s.jsonError(w, http.StatusBadRequest, "无效的 step (如 5m、1h): "+v)
+			return
+		}
+	}
+
+	samples, err := s.todoMetrics.Range(from, to, query.Get("account"))
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.jsonResponse(w, map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"step":   step.String(),
+		"series": metrics.BuildSeries(samples, step),
+	})
+}
+
+// 最近一次采样相对上一次的变化
+func (s *Server) handleTodoDiff(w http.ResponseWriter, r *http.Request) {
+	last, err := s.todoMetrics.Last(2)
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	account := r.URL.Query().Get("account")
+	diffs := []metrics.Diff{}
+	for name, samples := range last {
+		if account != "" && name != account {
+			continue
+		}
+		var prev metrics.Sample
+		if len(samples) == 2 {
+			prev = samples[0]
+		}
+		diffs = append(diffs, metrics.DiffSamples(prev, samples[len(samples)-1]))
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Account < diffs[j].Account })
+	s.jsonResponse(w, diffs)
+}
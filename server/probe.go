@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+	"zto-api-proxy/proxy"
+)
+
+// ProbeStatus 最近一次服务端登录状态探测结果
+type ProbeStatus struct {
+	Verified  bool      `json:"verified"`          // 服务端确认登录有效
+	Revoked   bool      `json:"revoked,omitempty"` // 服务端已注销登录 (如宝盒异地登录)
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+func (s *Server) recordProbe(account string, err error) ProbeStatus {
+	st := ProbeStatus{Verified: err == nil, Revoked: errors.Is(err, proxy.ErrSessionRevoked), CheckedAt: time.Now()}
+	if err != nil {
+		st.Error = err.Error()
+	}
+	s.probeLock.Lock()
+	s.probes[account] = st
+	s.probeLock.Unlock()
+	return st
+}
+
+// probeStatus 返回账号的探测结果，以及该结果是否对应当前 Token
+func (s *Server) probeStatus(account string) (ProbeStatus, bool) {
+	s.probeLock.Lock()
+	st, ok := s.probes[account]
+	s.probeLock.Unlock()
+	if !ok {
+		return st, false
+	}
+	token := config.GetAccountToken(account)
+	return st, token != nil && !st.CheckedAt.Before(token.LastRefresh)
+}
+
+// serverVerified 当前 Token 是否已通过服务端探测
+func (s *Server) serverVerified(account string) bool {
+	st, current := s.probeStatus(account)
+	return current && st.Verified
+}
+
+// ProbeTokens 探测本地有效的 Token 是否仍被服务端接受，
+// 检测到登录已被注销时立即刷新，由定时任务调用
+func (s *Server) ProbeTokens() error {
+	var errs []error
+	for _, name := range config.AccountNames() {
+		if !config.IsAccountTokenValid(name) {
+			continue
+		}
+		err := s.proxyClient.Probe(config.GetAccountCookieString(name))
+		st := s.recordProbe(name, err)
+		if !st.Revoked {
+			if err != nil {
+				errs = append(errs, fmt.Errorf("账号 %s 探测失败: %w", name, err))
+			}
+			continue
+		}
+
+		logger.Token("账号 %s 的登录状态已被服务端注销 (%v)，立即刷新", name, err)
+		if s.refresher == nil {
+			continue
+		}
+		if err := s.refresher.RefreshAccount(name); err != nil {
+			errs = append(errs, fmt.Errorf("账号 %s 刷新失败: %w", name, err))
+			continue
+		}
+		s.recordProbe(name, s.proxyClient.Probe(config.GetAccountCookieString(name)))
+	}
+	return errors.Join(errs...)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,31 +17,36 @@ import (
 	"syscall"
 	"time"
 
+	"zto-api-proxy/alert"
+	"zto-api-proxy/browser"
 	"zto-api-proxy/config"
+	"zto-api-proxy/history"
 	"zto-api-proxy/logger"
+	"zto-api-proxy/metrics"
+	"zto-api-proxy/procdetect"
 	"zto-api-proxy/proxy"
+	"zto-api-proxy/refresh"
+	"zto-api-proxy/scheduler"
 )
 
 //go:embed static/*
 var staticFS embed.FS
 
-// ProxyRecord 代理请求记录
-type ProxyRecord struct {
-	ID         string    `json:"id"`
-	Time       time.Time `json:"time"`
-	Method     string    `json:"method"`
-	URL        string    `json:"url"`
-	StatusCode int       `json:"statusCode"`
-	Duration   int64     `json:"duration"` // 毫秒
-}
-
 // Server HTTP 服务器
 type Server struct {
 	proxyClient *proxy.Client
-	refreshFunc func() error
+	refresher   *refresh.Coordinator
 	httpServer  *http.Server
-	history     []ProxyRecord
-	historyLock sync.RWMutex
+	history     *history.Store
+	todoMetrics *metrics.Store
+	alerts      *alert.Manager
+	scheduler   *scheduler.Scheduler
+	browser     *browser.Browser
+	qrLogins    map[string]*qrLogin // 账号 -> 最近一次扫码登录
+	qrLock      sync.Mutex
+	probes      map[string]ProbeStatus // 账号 -> 最近一次服务端探测结果
+	probeLock   sync.Mutex
+	procs       procdetect.Lister // 检测宝盒进程
 	lastFetch   time.Time
 	zboxStatus  string
 	zboxPid     string
@@ -48,12 +54,18 @@ type Server struct {
 }
 
 // NewServer 创建服务器
-func NewServer(proxyClient *proxy.Client, refreshFunc func() error) *Server {
+func NewServer(proxyClient *proxy.Client, refresher *refresh.Coordinator) *Server {
 	s := &Server{
 		proxyClient: proxyClient,
-		refreshFunc: refreshFunc,
+		refresher:   refresher,
+		history:     openHistory(),
+		todoMetrics: openTodoMetrics(),
+		qrLogins:    make(map[string]*qrLogin),
+		probes:      make(map[string]ProbeStatus),
+		procs:       procdetect.System(),
 		zboxStatus:  "检测中...",
 	}
+	s.alerts = alert.NewManager(alertSource{s})
 	s.CheckZBox() // 启动时检查一次
 	return s
 }
@@ -76,6 +88,10 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/orders/todo", s.handleOrdersTodo)
 	mux.HandleFunc("/province-report", s.handleProvinceReport)
 
+	// 待办数量趋势
+	mux.HandleFunc("/metrics/todo", s.handleTodoMetrics)
+	mux.HandleFunc("/metrics/todo/diff", s.handleTodoDiff)
+
 	// 管理 API
 	mux.HandleFunc("/status", s.handleStatus)
 	mux.HandleFunc("/refresh", s.handleRefresh)
@@ -86,16 +102,34 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/admin/open-debug", s.handleOpenDebug)
 	mux.HandleFunc("/admin/recent-logs", s.handleRecentLogs)
 	mux.HandleFunc("/admin/request-logs", s.handleRequestLogs)
+	mux.HandleFunc("/admin/log-levels", s.handleLogLevels)
+	mux.HandleFunc("/admin/logs/stream", s.handleLogStream)
+	mux.HandleFunc("/admin/logs/search", s.handleLogSearch)
+	mux.HandleFunc("/admin/logs/request", s.handleRequestTrace)
 	mux.HandleFunc("/admin/config", s.handleGetConfig)
 	mux.HandleFunc("/admin/save-config", s.handleSaveConfig)
 	mux.HandleFunc("/admin/clear-logs", s.handleClearLogs)
+	mux.HandleFunc("/admin/api-keys", s.handleAPIKeys)
+	mux.HandleFunc("/admin/api-keys/delete", s.handleDeleteAPIKey)
+	mux.HandleFunc("/admin/jobs", s.handleJobs)
+	mux.HandleFunc("/admin/jobs/run", s.handleRunJob)
+	mux.HandleFunc("/admin/snapshots", s.handleSnapshots)
+	mux.HandleFunc("/admin/snapshots/download", s.handleDownloadSnapshot)
+	mux.HandleFunc("/admin/snapshots/run", s.handleRunSnapshot)
+	mux.HandleFunc("/admin/alerts", s.handleAlerts)
+	mux.HandleFunc("/admin/alerts/test", s.handleTestAlert)
+	mux.HandleFunc("/admin/login/timeline", s.handleLoginTimeline)
+	mux.HandleFunc("/admin/login/qr", s.handleLoginQR)
+	mux.HandleFunc("/admin/login/diagnostics", s.handleLoginDiagnostics)
+	mux.HandleFunc("/admin/login/diagnostics/download", s.handleDownloadDiagnostics)
+	mux.HandleFunc("/admin/token/import", s.handleTokenImport)
 
 	// 兼容性/自定义 API 路径
 	mux.HandleFunc("/api/query/order_trace", s.handleLegacyOrders)
 
 	s.httpServer = &http.Server{
 		Addr:         fmt.Sprintf("0.0.0.0:%d", cfg.Port),
-		Handler:      s.corsMiddleware(s.logMiddleware(mux)),
+		Handler:      s.corsMiddleware(s.authMiddleware(s.logMiddleware(mux))),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 60 * time.Second,
 	}
@@ -115,9 +149,13 @@ func (s *Server) Stop() error {
 // 中间件：CORS
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		// 管理接口只供同源的控制面板使用，不允许跨域
+		if requiredRole(r.URL.Path) != config.RoleAdmin {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-ZTO-Account, X-API-Key, Authorization, Cache-Control, X-Request-ID")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Cache, Retry-After, Content-Disposition, X-Request-ID")
+		}
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -128,15 +166,46 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// 中间件：日志
+// 中间件：日志。沿用调用方的 X-Request-ID 或生成新的，随 context 传递并写入响应头
 func (s *Server) logMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		logger.Debug("HTTP %s %s (%s)", r.Method, r.URL.Path, time.Since(start))
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(logger.WithRequestID(r.Context(), id))
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		logger.With(logger.CategoryHTTP).DebugContext(r.Context(), "请求处理完成",
+			"method", r.Method, "path", r.URL.Path, "status", sw.status,
+			"duration_ms", time.Since(start).Milliseconds(), "remote", r.RemoteAddr)
 	})
 }
 
+// statusWriter 记录响应状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // 透传代理
 func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -154,20 +223,37 @@ func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 		s.jsonError(w, http.StatusBadRequest, "url 是必需的")
 		return
 	}
+	if err := proxy.CheckURL(req.URL); err != nil {
+		logger.With(logger.CategoryAPI).WarnContext(r.Context(), "拒绝透传请求", "error", err)
+		s.jsonError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	req.Guarded = true
+	if req.Account == "" {
+		req.Account = r.Header.Get(accountHeader)
+	}
 
 	startTime := time.Now()
-	resp := s.proxyClient.DoRequest(&req)
+	resp := s.doCached(w, r, &req)
 	duration := time.Since(startTime).Milliseconds()
-	s.addHistory(r.Method, req.URL, resp.StatusCode, duration)
+	s.addHistory(w, r, &req, resp, duration)
 	if resp.StatusCode == 200 {
 		s.lastFetch = time.Now()
 	}
-	s.jsonResponse(w, resp)
+	s.proxyResponse(w, resp)
 }
 
 // 订单查询（便捷模式）
 func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request) {
+	account, ok := s.resolveAccount(w, r)
+	if !ok {
+		return
+	}
 	query := r.URL.Query()
+	format, ok := s.exportFormat(w, query)
+	if !ok {
+		return
+	}
 
 	start := query.Get("start")
 	end := query.Get("end")
@@ -175,6 +261,9 @@ func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request) {
 	size, _ := strconv.Atoi(query.Get("size"))
 	siteCode := query.Get("siteCode")
 	empCode := query.Get("empCode")
+	if siteCode == "" {
+		siteCode = account.SiteCode
+	}
 
 	if start == "" {
 		start = time.Now().Format("2006-01-02") + " 00:00:00"
@@ -232,21 +321,27 @@ func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request) {
 	}
 
 	req := &proxy.ProxyRequest{
-		URL:    "https://preorder-query-center.gw.zt-express.com/preOrderQuery/getSiteOrderTraceList",
-		Method: "POST",
-		Body:   body,
+		URL:     "https://preorder-query-center.gw.zt-express.com/preOrderQuery/getSiteOrderTraceList",
+		Method:  "POST",
+		Body:    body,
+		Account: account.Name,
 	}
+	req.Paginate = paginateOptions(query)
 
 	startTime := time.Now()
-	resp := s.proxyClient.DoRequest(req)
+	resp := s.doCached(w, r, req)
 	duration := time.Since(startTime).Milliseconds()
-	s.addHistory(r.Method, req.URL, resp.StatusCode, duration)
-	s.jsonResponse(w, resp)
+	s.addHistory(w, r, req, resp, duration)
+	s.writeResult(w, r, resp, format, "orders")
 }
 
 // 兼容旧版/自定义路径的订单查询
 func (s *Server) handleLegacyOrders(w http.ResponseWriter, r *http.Request) {
-	token := config.GetTokenData()
+	account, ok := s.resolveAccount(w, r)
+	if !ok {
+		return
+	}
+	token := config.GetAccountToken(account.Name)
 	var errStr string
 	if token == nil || len(token.Cookies) == 0 {
 		errStr = "Token数据为空"
@@ -290,15 +385,16 @@ func (s *Server) handleLegacyOrders(w http.ResponseWriter, r *http.Request) {
 		"pageIndex":         1,
 	}
 	req := &proxy.ProxyRequest{
-		URL:    "https://preorder-query-center.gw.zt-express.com/preOrderQuery/getSiteOrderTraceList",
-		Method: "POST",
-		Body:   body,
+		URL:     "https://preorder-query-center.gw.zt-express.com/preOrderQuery/getSiteOrderTraceList",
+		Method:  "POST",
+		Body:    body,
+		Account: account.Name,
 	}
 
 	startTime := time.Now()
-	resp := s.proxyClient.DoRequest(req)
+	resp := s.doCached(w, r, req)
 	duration := time.Since(startTime).Milliseconds()
-	s.addHistory("POST", req.URL, resp.StatusCode, duration)
+	s.addHistory(w, r, req, resp, duration)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -314,9 +410,17 @@ func (s *Server) handleLegacyOrders(w http.ResponseWriter, r *http.Request) {
 
 // 待办事项
 func (s *Server) handleOrdersTodo(w http.ResponseWriter, r *http.Request) {
-	body := map[string]interface{}{
-		"traceQueryChannel": "ALL_PICK_CHANNEL",
+	account, ok := s.resolveAccount(w, r)
+	if !ok {
+		return
 	}
+	query := r.URL.Query()
+	format, ok := s.exportFormat(w, query)
+	if !ok {
+		return
+	}
+
+	body := defaultTodoBody()
 
 	// 如果请求带了 Body，则透传 Body
 	if r.Method == "POST" && r.Body != nil {
@@ -326,23 +430,28 @@ func (s *Server) handleOrdersTodo(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	req := &proxy.ProxyRequest{
-		URL:    "https://preorder-query-center.gw.zt-express.com/preOrderQuery/getTodoCenterList",
-		Method: "POST",
-		Body:   body,
-	}
+	req := todoRequest(account.Name, body)
 
 	startTime := time.Now()
-	resp := s.proxyClient.DoRequest(req)
+	resp := s.doCached(w, r, req)
 	duration := time.Since(startTime).Milliseconds()
-	s.addHistory(r.Method, req.URL, resp.StatusCode, duration)
-	s.jsonResponse(w, resp)
+	s.addHistory(w, r, req, resp, duration)
+	s.writeResult(w, r, resp, format, "orders-todo")
 }
 
 // 省市区报表
 func (s *Server) handleProvinceReport(w http.ResponseWriter, r *http.Request) {
+	account, ok := s.resolveAccount(w, r)
+	if !ok {
+		return
+	}
+
 	// 默认参数 (从 Query 获取)
 	query := r.URL.Query()
+	format, ok := s.exportFormat(w, query)
+	if !ok {
+		return
+	}
 	date := query.Get("date")
 	if date == "" {
 		date = time.Now().Format("2006-01-02")
@@ -355,6 +464,10 @@ func (s *Server) handleProvinceReport(w http.ResponseWriter, r *http.Request) {
 	if size == 0 {
 		size = 100
 	}
+	siteCode := query.Get("siteCode")
+	if siteCode == "" {
+		siteCode = account.SiteCode
+	}
 
 	body := map[string]interface{}{
 		"empCode":                   "",
@@ -372,7 +485,7 @@ func (s *Server) handleProvinceReport(w http.ResponseWriter, r *http.Request) {
 		"cityName":                  query.Get("city"),
 		"tiktokArea":                "",
 		"streetName":                "",
-		"siteCode":                  query.Get("siteCode"),
+		"siteCode":                  siteCode,
 		"siteName":                  "",
 		"sortType":                  1,
 		"sortField":                 "",
@@ -392,16 +505,18 @@ func (s *Server) handleProvinceReport(w http.ResponseWriter, r *http.Request) {
 	}
 
 	req := &proxy.ProxyRequest{
-		URL:    "https://orderapi.zt-express.com/opsApi/zjProvinceReport/queryZjPreOrderReport",
-		Method: "POST",
-		Body:   body,
+		URL:     "https://orderapi.zt-express.com/opsApi/zjProvinceReport/queryZjPreOrderReport",
+		Method:  "POST",
+		Body:    body,
+		Account: account.Name,
 	}
+	req.Paginate = paginateOptions(query)
 
 	startTime := time.Now()
-	resp := s.proxyClient.DoRequest(req)
+	resp := s.doCached(w, r, req)
 	duration := time.Since(startTime).Milliseconds()
-	s.addHistory(r.Method, req.URL, resp.StatusCode, duration)
-	s.jsonResponse(w, resp)
+	s.addHistory(w, r, req, resp, duration)
+	s.writeResult(w, r, resp, format, "province-report")
 }
 
 // 状态查询
@@ -431,6 +546,19 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		status["lastFetch"] = s.lastFetch.Format(time.RFC3339)
 	}
 
+	if s.proxyClient != nil {
+		status["queue"] = s.proxyClient.QueueStats()
+		status["cache"] = s.proxyClient.CacheStats()
+	}
+	if s.refresher != nil {
+		status["refresh"] = s.refresher.Status()
+	}
+	status["serverVerified"] = s.serverVerified(config.DefaultAccount)
+	if probe, ok := s.probeStatus(config.DefaultAccount); ok {
+		status["probe"] = probe
+	}
+	status["accounts"] = s.accountStatuses()
+
 	if token != nil {
 		if !token.ExpiresAt.IsZero() {
 			status["expiresAt"] = token.ExpiresAt.Format(time.RFC3339)
@@ -456,8 +584,14 @@ func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	account := r.URL.Query().Get("account")
+	if account != "" && !config.HasAccount(account) {
+		s.jsonError(w, http.StatusBadRequest, "未知账号: "+account)
+		return
+	}
+
 	s.CheckZBox() // 刷新前检查一下宝盒环境
-	err := s.refreshFunc()
+	err := s.refresher.RefreshAccountContext(r.Context(), account)
 	if err != nil {
 		s.jsonError(w, http.StatusInternalServerError, "刷新失败: "+err.Error())
 		return
@@ -477,23 +611,13 @@ func (s *Server) CheckZBox() {
 	status := "未运行"
 	pid := ""
 
-	cmd := exec.Command("tasklist", "/FO", "CSV", "/NH")
-	if runtime.GOOS == "windows" {
-		cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	procs, err := procdetect.Find(s.procs, config.GetConfig().ZBoxProcesses)
+	if err != nil {
+		logger.Warn("宝盒进程检测失败: %v", err)
 	}
-	if out, err := cmd.Output(); err == nil {
-		lines := strings.Split(string(out), "\n")
-		for _, line := range lines {
-			if strings.Contains(strings.ToLower(line), "zbox.exe") ||
-				strings.Contains(strings.ToLower(line), "lite.exe") {
-				parts := strings.Split(line, ",")
-				if len(parts) > 1 {
-					pid = strings.Trim(parts[1], "\"")
-					status = "运行中"
-					break
-				}
-			}
-		}
+	if len(procs) > 0 {
+		status = "运行中"
+		pid = strconv.Itoa(procs[0].PID)
 	}
 
 	s.zboxStatus = status
@@ -508,6 +632,10 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 // 打开日志目录
 func (s *Server) handleOpenLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 POST 方法")
+		return
+	}
 	cfg := config.GetConfig()
 	logsDir := filepath.Join(cfg.DataDir, "logs")
 	os.MkdirAll(logsDir, 0755)
@@ -519,8 +647,12 @@ func (s *Server) handleOpenLogs(w http.ResponseWriter, r *http.Request) {
 	s.jsonResponse(w, map[string]bool{"success": true})
 }
 
-// 打开调试截图目录
+// 打开登录诊断包目录
 func (s *Server) handleOpenDebug(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 POST 方法")
+		return
+	}
 	cfg := config.GetConfig()
 	debugDir := filepath.Join(cfg.DataDir, "debug")
 	os.MkdirAll(debugDir, 0755)
@@ -534,70 +666,35 @@ func (s *Server) handleOpenDebug(w http.ResponseWriter, r *http.Request) {
 
 // 获取最近的日志内容
 func (s *Server) handleRecentLogs(w http.ResponseWriter, r *http.Request) {
-	cfg := config.GetConfig()
-	logsDir := filepath.Join(cfg.DataDir, "logs")
-
-	// 1. 尝试读取当天的
-	today := time.Now().Format("2006-01-02")
-	logFile := filepath.Join(logsDir, fmt.Sprintf("service_%s.log", today))
-
-	data, err := os.ReadFile(logFile)
-	if err != nil {
-		// 2. 如果今天还没有日志，尝试找最新的一个文件
-		files, _ := os.ReadDir(logsDir)
-		var latestFile string
-		for i := len(files) - 1; i >= 0; i-- {
-			if !files[i].IsDir() && strings.HasPrefix(files[i].Name(), "service_") {
-				latestFile = filepath.Join(logsDir, files[i].Name())
-				break
-			}
-		}
-
-		if latestFile == "" {
-			s.jsonResponse(w, []string{"[SYSTEM] [INFO] 暂无日志记录"})
-			return
-		}
-		data, err = os.ReadFile(latestFile)
-		if err != nil {
-			s.jsonResponse(w, []string{"[SYSTEM] [ERROR] 无法读取历史日志文件"})
-			return
+	// 优先使用内存中的最近日志，避免重复读取整个日志文件
+	if entries := logger.Recent(50); len(entries) > 0 {
+		lines := make([]string, len(entries))
+		for i, e := range entries {
+			lines[i] = e.Text
 		}
+		s.jsonResponse(w, lines)
+		return
 	}
 
-	lines := strings.Split(string(data), "\n")
-	// 过滤空行
-	var validLines []string
-	for _, l := range lines {
-		if strings.TrimSpace(l) != "" {
-			validLines = append(validLines, l)
-		}
+	res, err := logger.Search(logsDir(), logger.Query{Limit: 50})
+	if err != nil {
+		s.jsonResponse(w, []string{"[SYSTEM] [ERROR] 无法读取历史日志文件"})
+		return
 	}
-
-	if len(validLines) > 50 {
-		validLines = validLines[len(validLines)-50:]
+	if len(res.Lines) == 0 {
+		s.jsonResponse(w, []string{"[SYSTEM] [INFO] 暂无日志记录"})
+		return
 	}
-
-	s.jsonResponse(w, validLines)
-}
-
-// 获取请求日志记录
-func (s *Server) handleRequestLogs(w http.ResponseWriter, r *http.Request) {
-	s.historyLock.RLock()
-	defer s.historyLock.RUnlock()
-	s.jsonResponse(w, s.history)
-}
-
-// 清空历史记录
-func (s *Server) handleClearLogs(w http.ResponseWriter, r *http.Request) {
-	s.historyLock.Lock()
-	s.history = []ProxyRecord{}
-	s.historyLock.Unlock()
-	s.jsonResponse(w, map[string]bool{"success": true})
+	lines := make([]string, len(res.Lines))
+	for i, l := range res.Lines {
+		lines[i] = l.Text
+	}
+	s.jsonResponse(w, lines)
 }
 
 // 获取配置
 func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
-	s.jsonResponse(w, config.GetConfig())
+	s.jsonResponse(w, config.RedactedConfig())
 }
 
 // 保存配置
@@ -611,39 +708,55 @@ func (s *Server) handleSaveConfig(w http.ResponseWriter, r *http.Request) {
 		s.jsonError(w, http.StatusBadRequest, "无效的配置参数")
 		return
 	}
-	// 这里目前只允许保存部分安全参数，且保存后需要重启生效
-	config.SetCustomConfig(&newCfg)
+	for _, spec := range []string{newCfg.RefreshTime, newCfg.PreventTime} {
+		if _, err := scheduler.ParseSchedule(spec); err != nil && spec != "off" {
+			s.jsonError(w, http.StatusBadRequest, "无效的刷新时间: "+err.Error())
+			return
+		}
+	}
+	// 这里目前只允许保存部分安全参数，刷新时间立即生效，其余设置需重启
+	if err := config.SetCustomConfig(&newCfg); err != nil {
+		s.jsonError(w, http.StatusInternalServerError, "保存配置失败: "+err.Error())
+		return
+	}
 	s.jsonResponse(w, map[string]interface{}{"success": true, "message": "配置已保存，部分设置需重启后生效"})
 }
 
-func (s *Server) addHistory(method, url string, statusCode int, duration int64) {
-	s.historyLock.Lock()
-	defer s.historyLock.Unlock()
-
-	// 截取 URL 长度
-	displayURL := url
-	if len(displayURL) > 80 {
-		displayURL = displayURL[:77] + "..."
-	}
+func (s *Server) jsonResponse(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(data)
+}
 
-	record := ProxyRecord{
-		ID:         fmt.Sprintf("%d", time.Now().UnixNano()),
-		Time:       time.Now(),
-		Method:     method,
-		URL:        displayURL,
-		StatusCode: statusCode,
-		Duration:   duration,
+// paginateOptions 解析 all=true&max=N 自动翻页参数
+func paginateOptions(query url.Values) *proxy.PaginateOptions {
+	if all, _ := strconv.ParseBool(query.Get("all")); !all {
+		return nil
 	}
+	max, _ := strconv.Atoi(query.Get("max"))
+	return &proxy.PaginateOptions{MaxRecords: max}
+}
 
-	s.history = append([]ProxyRecord{record}, s.history...)
-	if len(s.history) > 100 {
-		s.history = s.history[:100]
+// doCached 通过响应缓存执行请求，Cache-Control: no-cache 时跳过缓存
+func (s *Server) doCached(w http.ResponseWriter, r *http.Request, req *proxy.ProxyRequest) *proxy.ProxyResponse {
+	noCache := strings.Contains(r.Header.Get("Cache-Control"), "no-cache") ||
+		r.Header.Get("Pragma") == "no-cache"
+	resp, cacheStatus := s.proxyClient.DoCachedContext(r.Context(), req, noCache)
+	if cacheStatus != "" {
+		w.Header().Set("X-Cache", cacheStatus)
 	}
+	return resp
 }
 
-func (s *Server) jsonResponse(w http.ResponseWriter, data interface{}) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	json.NewEncoder(w).Encode(data)
+// proxyResponse 输出代理结果，队列繁忙时返回 503 并附带 Retry-After
+func (s *Server) proxyResponse(w http.ResponseWriter, resp *proxy.ProxyResponse) {
+	if resp.RetryAfter > 0 {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Retry-After", strconv.Itoa(resp.RetryAfter))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	s.jsonResponse(w, resp)
 }
 
 func (s *Server) jsonError(w http.ResponseWriter, code int, message string) {
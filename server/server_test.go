@@ -1,15 +1,42 @@
 package server
 
 import (
+	"bufio"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"zto-api-proxy/browser"
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+	"zto-api-proxy/metrics"
+	"zto-api-proxy/procdetect"
 	"zto-api-proxy/proxy"
+	"zto-api-proxy/refresh"
+	"zto-api-proxy/scheduler"
 )
 
+// TestMain 使用临时数据目录，避免测试在源码目录下留下 Token、盐值等文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "zto-server-test")
+	if err != nil {
+		panic(err)
+	}
+	config.GetConfig().DataDir = dir
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestHandleHealth(t *testing.T) {
 	srv := NewServer(nil, nil)
 
@@ -63,6 +90,18 @@ func TestHandleProxy_InvalidMethod(t *testing.T) {
 	}
 }
 
+func TestHandleClearLogs_RejectsGet(t *testing.T) {
+	srv := NewServer(nil, nil)
+
+	req := httptest.NewRequest("GET", "/admin/clear-logs", nil)
+	w := httptest.NewRecorder()
+	srv.handleClearLogs(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("期望 405, 实际 %d", w.Code)
+	}
+}
+
 func TestHandleProxy_MissingURL(t *testing.T) {
 	srv := NewServer(nil, nil)
 
@@ -85,6 +124,12 @@ func TestHandleProxy_Success(t *testing.T) {
 	}))
 	defer targetServer.Close()
 
+	// 测试服务器监听在回环地址，需要显式放行
+	cfg := config.GetConfig()
+	oldHosts, oldPrivate, oldDir := cfg.AllowedHosts, cfg.AllowPrivateTargets, cfg.DataDir
+	cfg.AllowedHosts, cfg.AllowPrivateTargets, cfg.DataDir = []string{"127.0.0.1"}, true, t.TempDir()
+	defer func() { cfg.AllowedHosts, cfg.AllowPrivateTargets, cfg.DataDir = oldHosts, oldPrivate, oldDir }()
+
 	proxyClient := proxy.NewClient(nil)
 	srv := NewServer(proxyClient, nil)
 
@@ -97,6 +142,521 @@ func TestHandleProxy_Success(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("期望 200, 实际 %d", w.Code)
 	}
+
+	// 请求应写入历史记录，包含完整 URL
+	logs := httptest.NewRecorder()
+	srv.handleRequestLogs(logs, httptest.NewRequest("GET", "/admin/request-logs?status=2xx", nil))
+	var result struct {
+		Total   int `json:"total"`
+		Records []struct {
+			URL          string `json:"url"`
+			ResponseSize int64  `json:"responseSize"`
+		} `json:"records"`
+	}
+	json.NewDecoder(logs.Body).Decode(&result)
+	if result.Total != 1 || result.Records[0].URL != targetServer.URL || result.Records[0].ResponseSize == 0 {
+		t.Errorf("历史记录不正确: %+v", result)
+	}
+}
+
+func TestHandleProxy_QueueFull(t *testing.T) {
+	release := make(chan struct{})
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(map[string]string{"result": "success"})
+	}))
+	defer targetServer.Close()
+
+	// 每个主机只允许 1 个并发、1 个排队
+	cfg := config.GetConfig()
+	oldHosts, oldPrivate := cfg.AllowedHosts, cfg.AllowPrivateTargets
+	oldLimit, oldDepth := cfg.DefaultConcurrency, cfg.QueueMaxDepth
+	cfg.AllowedHosts, cfg.AllowPrivateTargets = []string{"127.0.0.1"}, true
+	cfg.DefaultConcurrency, cfg.QueueMaxDepth = 1, 1
+	defer func() {
+		cfg.AllowedHosts, cfg.AllowPrivateTargets = oldHosts, oldPrivate
+		cfg.DefaultConcurrency, cfg.QueueMaxDepth = oldLimit, oldDepth
+	}()
+
+	srv := NewServer(proxy.NewClient(nil), nil)
+	body := `{"url": "` + targetServer.URL + `", "method": "GET"}`
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.handleProxy(w, httptest.NewRequest("POST", "/proxy", strings.NewReader(body)))
+		return w
+	}
+
+	// 一个请求占住并发位，一个请求占住排队位
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- send().Code }()
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := srv.proxyClient.QueueStats()
+		if len(stats) == 1 && stats[0].Active == 1 && stats[0].Waiting == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("请求未进入排队: %+v", stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	w := send()
+	close(release)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("队列满时期望 503 且带 Retry-After, 实际 %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("已排队的请求应成功, 实际 %d", code)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"result": "success"})
+	}))
+	defer targetServer.Close()
+
+	cfg := config.GetConfig()
+	oldHosts, oldPrivate, oldDir := cfg.AllowedHosts, cfg.AllowPrivateTargets, cfg.DataDir
+	cfg.AllowedHosts, cfg.AllowPrivateTargets, cfg.DataDir = []string{"127.0.0.1"}, true, t.TempDir()
+	defer func() { cfg.AllowedHosts, cfg.AllowPrivateTargets, cfg.DataDir = oldHosts, oldPrivate, oldDir }()
+
+	srv := NewServer(proxy.NewClient(nil), nil)
+	handler := srv.logMiddleware(http.HandlerFunc(srv.handleProxy))
+
+	req := httptest.NewRequest("POST", "/proxy", strings.NewReader(`{"url": "`+targetServer.URL+`", "method": "GET"}`))
+	req.Header.Set("X-Request-ID", "order-sync-42")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var resp proxy.ProxyResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Header().Get("X-Request-ID") != "order-sync-42" || resp.RequestID != "order-sync-42" {
+		t.Errorf("应沿用调用方的请求 ID: header=%s body=%s", w.Header().Get("X-Request-ID"), resp.RequestID)
+	}
+
+	// 不合法的请求 ID 重新生成
+	req = httptest.NewRequest("POST", "/proxy", strings.NewReader(`{"url": "`+targetServer.URL+`", "method": "GET"}`))
+	req.Header.Set("X-Request-ID", "bad id\n")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if id := w.Header().Get("X-Request-ID"); len(id) != 16 || id == "bad id\n" {
+		t.Errorf("应生成新的请求 ID: %q", id)
+	}
+
+	// 按请求 ID 查询日志与请求历史
+	dir := filepath.Join(cfg.DataDir, "logs")
+	os.MkdirAll(dir, 0755)
+	now := time.Now()
+	os.WriteFile(filepath.Join(dir, "service_"+now.Format("2006-01-02")+".log"), []byte(
+		"["+now.Format("2006-01-02 15:04:05")+"] [WARN] [API] 上游请求失败 attempt=1 status=500 request_id=order-sync-42\n"+
+			"["+now.Format("2006-01-02 15:04:05")+"] [INFO] [API] 上游请求完成 status=200 request_id=order-sync-4\n"), 0644)
+
+	w = httptest.NewRecorder()
+	srv.handleRequestTrace(w, httptest.NewRequest("GET", "/admin/logs/request?id=order-sync-42", nil))
+	var trace struct {
+		Lines   []struct{ Text string } `json:"lines"`
+		History []struct {
+			RequestID string `json:"requestId"`
+		} `json:"history"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &trace)
+	if len(trace.Lines) != 1 || !strings.Contains(trace.Lines[0].Text, "上游请求失败") ||
+		len(trace.History) != 1 || trace.History[0].RequestID != "order-sync-42" {
+		t.Errorf("请求追踪结果不正确: %s", w.Body.String())
+	}
+}
+
+func TestRunSnapshot(t *testing.T) {
+	var gotBody map[string]interface{}
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"result": "success"})
+	}))
+	defer targetServer.Close()
+
+	cfg := config.GetConfig()
+	oldHosts, oldPrivate, oldDir, oldJobs := cfg.AllowedHosts, cfg.AllowPrivateTargets, cfg.DataDir, cfg.SnapshotJobs
+	cfg.AllowedHosts, cfg.AllowPrivateTargets, cfg.DataDir = []string{"127.0.0.1"}, true, t.TempDir()
+	cfg.SnapshotJobs = []config.SnapshotJob{
+		{Name: "daily", Endpoint: "/proxy", Body: map[string]interface{}{
+			"url": targetServer.URL, "method": "POST", "body": map[string]interface{}{"date": "{{yesterday}}"},
+		}},
+		{Name: "bad", Endpoint: "/admin/config"},
+	}
+	defer func() {
+		cfg.AllowedHosts, cfg.AllowPrivateTargets, cfg.DataDir, cfg.SnapshotJobs = oldHosts, oldPrivate, oldDir, oldJobs
+	}()
+
+	srv := NewServer(proxy.NewClient(nil), nil)
+	file, err := srv.RunSnapshot("daily")
+	if err != nil {
+		t.Fatalf("采集失败: %v", err)
+	}
+	if want := time.Now().AddDate(0, 0, -1).Format("2006-01-02"); gotBody["date"] != want {
+		t.Errorf("请求体占位符应替换为 %s, 实际 %v", want, gotBody["date"])
+	}
+
+	w := httptest.NewRecorder()
+	srv.handleDownloadSnapshot(w, httptest.NewRequest("GET", "/admin/snapshots/download?job=daily&file="+file.Name, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"result":"success"`) {
+		t.Errorf("下载快照内容不正确: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleDownloadSnapshot(w, httptest.NewRequest("GET", "/admin/snapshots/download?job=daily&file=../../config.json", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("越权路径应返回 404, 实际 %d", w.Code)
+	}
+
+	if _, err := srv.RunSnapshot("bad"); err == nil {
+		t.Error("不支持的 endpoint 应失败")
+	}
+}
+
+func TestHandleTodoMetrics(t *testing.T) {
+	cfg := config.GetConfig()
+	oldDir := cfg.DataDir
+	cfg.DataDir = t.TempDir()
+	defer func() { cfg.DataDir = oldDir }()
+
+	srv := NewServer(nil, nil)
+	now := time.Now()
+	srv.todoMetrics.Add(metrics.NewSample("default", "S1", map[string]int{"待揽收": 3}, now.Add(-10*time.Minute)))
+	srv.todoMetrics.Add(metrics.NewSample("default", "S1", map[string]int{"待揽收": 5, "超时": 1}, now.Add(-5*time.Minute)))
+
+	w := httptest.NewRecorder()
+	srv.handleTodoMetrics(w, httptest.NewRequest("GET", "/metrics/todo?step=1h", nil))
+	var series struct {
+		Series []metrics.Series `json:"series"`
+	}
+	json.NewDecoder(w.Body).Decode(&series)
+	if len(series.Series) != 1 || series.Series[0].Points[len(series.Series[0].Points)-1].Total != 6 {
+		t.Errorf("时间序列不正确: %+v", series)
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleTodoMetrics(w, httptest.NewRequest("GET", "/metrics/todo?step=abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("无效 step 应返回 400, 实际 %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleTodoDiff(w, httptest.NewRequest("GET", "/metrics/todo/diff", nil))
+	var diffs []metrics.Diff
+	json.NewDecoder(w.Body).Decode(&diffs)
+	if len(diffs) != 1 || diffs[0].Total.Delta != 3 || len(diffs[0].Changes) != 2 {
+		t.Errorf("差异不正确: %+v", diffs)
+	}
+}
+
+func TestAlertSource_SkipsUnusedDefault(t *testing.T) {
+	cfg := config.GetConfig()
+	oldAccounts := cfg.Accounts
+	defer func() { cfg.Accounts = oldAccounts }()
+
+	src := alertSource{s: NewServer(nil, nil)}
+	cfg.Accounts = []config.AccountConfig{{Name: "site2"}}
+	if got := src.Accounts(); len(got) != 1 || got[0] != "site2" {
+		t.Errorf("未配置且无 Token 的默认账号不应参与告警: %v", got)
+	}
+
+	cfg.Accounts = []config.AccountConfig{{Name: config.DefaultAccount}, {Name: "site2"}}
+	if got := src.Accounts(); len(got) != 2 {
+		t.Errorf("显式配置的默认账号应参与告警: %v", got)
+	}
+
+	cfg.Accounts = nil
+	if got := src.Accounts(); len(got) != 1 || got[0] != config.DefaultAccount {
+		t.Errorf("只有默认账号时应照常检查: %v", got)
+	}
+}
+
+func TestHandleTestAlert(t *testing.T) {
+	var got map[string]interface{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer receiver.Close()
+
+	cfg := config.GetConfig()
+	oldHooks := cfg.AlertWebhooks
+	cfg.AlertWebhooks = []config.AlertWebhook{{Name: "robot", Type: "wecom", URL: receiver.URL}}
+	defer func() { cfg.AlertWebhooks = oldHooks }()
+
+	srv := NewServer(nil, nil)
+	w := httptest.NewRecorder()
+	srv.handleTestAlert(w, httptest.NewRequest("POST", "/admin/alerts/test", strings.NewReader(`{"webhook":"robot"}`)))
+	if w.Code != http.StatusOK || got["msgtype"] != "markdown" {
+		t.Errorf("测试消息应发送成功: %d %s %+v", w.Code, w.Body.String(), got)
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleTestAlert(w, httptest.NewRequest("POST", "/admin/alerts/test", strings.NewReader(`{"webhook":"missing"}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("不存在的渠道应返回 404, 实际 %d", w.Code)
+	}
+}
+
+func TestHandleLoginQR(t *testing.T) {
+	srv := NewServer(nil, nil)
+	srv.SetBrowser(browser.NewBrowser())
+
+	w := httptest.NewRecorder()
+	srv.handleLoginQR(w, httptest.NewRequest("GET", "/admin/login/qr", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("未发起扫码登录应返回 404, 实际 %d", w.Code)
+	}
+
+	png := []byte("\x89PNG fake")
+	srv.PublishQR(config.DefaultAccount, png)
+
+	w = httptest.NewRecorder()
+	srv.handleLoginQR(w, httptest.NewRequest("GET", "/admin/login/qr", nil))
+	var resp struct {
+		Login qrLogin `json:"login"`
+		Image string  `json:"image"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Login.Status != qrWaiting || !strings.HasPrefix(resp.Image, "data:image/png;base64,") {
+		t.Errorf("应返回等待扫码状态与二维码: %+v", resp)
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleLoginQR(w, httptest.NewRequest("GET", "/admin/login/qr?format=png", nil))
+	if w.Header().Get("Content-Type") != "image/png" || w.Body.String() != string(png) {
+		t.Errorf("format=png 应直接返回图片: %s", w.Header().Get("Content-Type"))
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleLoginQR(w, httptest.NewRequest("POST", "/admin/login/qr", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("扫码进行中再次发起应返回 409, 实际 %d", w.Code)
+	}
+
+	srv.finishQR(config.DefaultAccount, nil)
+	if st, _ := srv.qrStatus(config.DefaultAccount); st.Status != qrSuccess || st.Image != nil {
+		t.Errorf("登录完成后应清除二维码: %+v", st)
+	}
+}
+
+func TestHandleTokenImport(t *testing.T) {
+	revoked := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if revoked {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"status":true}`))
+	}))
+	defer upstream.Close()
+
+	cfg := config.GetConfig()
+	oldDir, oldURL, oldAccounts := cfg.DataDir, cfg.ProbeURL, cfg.Accounts
+	cfg.DataDir, cfg.ProbeURL = t.TempDir(), upstream.URL
+	cfg.Accounts = []config.AccountConfig{{Name: "site2"}}
+	defer func() { cfg.DataDir, cfg.ProbeURL, cfg.Accounts = oldDir, oldURL, oldAccounts }()
+
+	exp := time.Now().Add(2 * time.Hour).Unix()
+	jwt := "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp))) + ".sig"
+	curl := "curl 'https://x.zt-express.com/a' -b 'wyzdzjxhdnh=" + jwt + "; wyandyy=" + jwt + "'"
+
+	srv := NewServer(proxy.NewClient(nil), nil)
+	w := httptest.NewRecorder()
+	srv.handleTokenImport(w, httptest.NewRequest("POST", "/admin/token/import?account=site2", strings.NewReader(curl)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"format":"curl"`) {
+		t.Fatalf("导入应成功: %d %s", w.Code, w.Body.String())
+	}
+	if token := config.GetAccountToken("site2"); token == nil || token.ExpiresAt.Unix() != exp {
+		t.Errorf("应保存解析出的 Token: %+v", token)
+	}
+
+	revoked = true
+	w = httptest.NewRecorder()
+	body := `{"account":"site2","data":"Cookie: wyzdzjxhdnh=` + jwt + `"}`
+	req := httptest.NewRequest("POST", "/admin/token/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	srv.handleTokenImport(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("探测失败时应拒绝导入, 实际 %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestProbeTokens(t *testing.T) {
+	revoked := true
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if revoked {
+			w.Write([]byte(`{"status":false,"message":"登录已失效，请重新登录"}`))
+			return
+		}
+		w.Write([]byte(`{"status":true}`))
+	}))
+	defer upstream.Close()
+
+	cfg := config.GetConfig()
+	oldDir, oldURL, oldAccounts := cfg.DataDir, cfg.ProbeURL, cfg.Accounts
+	cfg.DataDir, cfg.ProbeURL = t.TempDir(), upstream.URL
+	cfg.Accounts = []config.AccountConfig{{Name: "site2"}}
+	defer func() { cfg.DataDir, cfg.ProbeURL, cfg.Accounts = oldDir, oldURL, oldAccounts }()
+
+	newToken := func() *config.TokenData {
+		return &config.TokenData{Cookies: map[string]string{"wyzdzjxhdnh": "x"}, LastRefresh: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	}
+	config.SetAccountToken("site2", newToken())
+
+	var refreshed []string
+	refresher := refresh.NewCoordinator(func(_ context.Context, account string, _ ...string) error {
+		refreshed = append(refreshed, account)
+		revoked = false
+		return config.SetAccountToken(account, newToken())
+	}, time.Minute)
+
+	srv := NewServer(proxy.NewClient(nil), refresher)
+	if srv.serverVerified("site2") {
+		t.Error("未探测前不应视为服务端已验证")
+	}
+	if err := srv.ProbeTokens(); err != nil {
+		t.Fatal(err)
+	}
+	if len(refreshed) != 1 || refreshed[0] != "site2" {
+		t.Errorf("登录被注销时应立即刷新: %v", refreshed)
+	}
+	if !srv.serverVerified("site2") {
+		t.Error("刷新后应重新探测并记录为已验证")
+	}
+
+	config.SetAccountToken("site2", newToken())
+	if srv.serverVerified("site2") {
+		t.Error("Token 更新后旧的探测结果不应再算作验证通过")
+	}
+}
+
+func TestCheckZBox(t *testing.T) {
+	srv := NewServer(nil, nil)
+	srv.procs = &procdetect.Fake{Procs: []procdetect.Process{{PID: 4, Name: "System"}, {PID: 5120, Name: "ZBox.exe"}}}
+	srv.CheckZBox()
+	if srv.zboxStatus != "运行中" || srv.zboxPid != "5120" {
+		t.Errorf("应检测到宝盒进程: %s %s", srv.zboxStatus, srv.zboxPid)
+	}
+
+	srv.procs = &procdetect.Fake{Procs: []procdetect.Process{{PID: 4, Name: "System"}}}
+	srv.CheckZBox()
+	if srv.zboxStatus != "未运行" || srv.zboxPid != "" {
+		t.Errorf("宝盒未运行时状态不正确: %s %s", srv.zboxStatus, srv.zboxPid)
+	}
+}
+
+func TestHandleLogLevels(t *testing.T) {
+	logger.Init(t.TempDir())
+	srv := NewServer(nil, nil)
+	t.Cleanup(func() { logger.ResetLevel(logger.CategoryHTTP) })
+
+	w := httptest.NewRecorder()
+	srv.handleLogLevels(w, httptest.NewRequest("POST", "/admin/log-levels", strings.NewReader(`{"category":"HTTP","level":"debug"}`)))
+	var resp struct {
+		Default    string            `json:"default"`
+		Categories map[string]string `json:"categories"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Categories["HTTP"] != "DEBUG" || resp.Categories["API"] != resp.Default {
+		t.Errorf("修改分类级别失败: %d %s", w.Code, w.Body.String())
+	}
+
+	for _, body := range []string{`{"category":"SQL","level":"debug"}`, `{"category":"HTTP","level":"verbose"}`} {
+		w = httptest.NewRecorder()
+		srv.handleLogLevels(w, httptest.NewRequest("POST", "/admin/log-levels", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("无效参数应返回 400: %s -> %d", body, w.Code)
+		}
+	}
+}
+
+func TestHandleLogStream(t *testing.T) {
+	logger.Init(t.TempDir())
+	srv := NewServer(nil, nil)
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleLogStream))
+	defer ts.Close()
+
+	logger.With(logger.CategoryAPI).Info("流式日志之前")
+	before := logger.Recent(1)[0].ID
+
+	resp, err := http.Get(ts.URL + "?level=warn&category=api,token&lastEventId=" + fmt.Sprint(before-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("Content-Type 不正确: %s", ct)
+	}
+
+	logger.With(logger.CategoryHTTP).Error("其他分类不推送")
+	logger.With(logger.CategoryAPI).Info("低于级别不推送")
+	logger.With(logger.CategoryToken, "account", "site2").Warn("刷新失败")
+
+	events := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "id: ") || strings.HasPrefix(line, "data: ") {
+				events <- line
+			}
+		}
+		close(events)
+	}()
+
+	var got []string
+	timeout := time.After(3 * time.Second)
+	for len(got) < 2 {
+		select {
+		case line := <-events:
+			got = append(got, line)
+		case <-timeout:
+			t.Fatalf("未收到推送: %v", got)
+		}
+	}
+	// 补发的 "流式日志之前" 为 INFO，同样被级别过滤，首条应为 TOKEN 警告
+	if !strings.HasPrefix(got[0], "id: ") || !strings.Contains(got[1], `"category":"TOKEN"`) || !strings.Contains(got[1], "刷新失败") {
+		t.Errorf("推送内容不正确: %v", got)
+	}
+}
+
+func TestHandleLogSearch(t *testing.T) {
+	cfg := config.GetConfig()
+	oldDir := cfg.DataDir
+	cfg.DataDir = t.TempDir()
+	defer func() { cfg.DataDir = oldDir }()
+
+	dir := filepath.Join(cfg.DataDir, "logs")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "service_2025-12-24.log"), []byte("[2025-12-24 09:00:00] [WARN] [TOKEN] 账号 site2 即将过期\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "service_2025-12-25.log"), []byte("[2025-12-25 09:00:00] [INFO] [TOKEN] 账号 site2 刷新成功\n"+
+		"[2025-12-25 09:01:00] [ERROR] [API] 请求最终失败 account=site2\n"), 0644)
+
+	srv := NewServer(nil, nil)
+	w := httptest.NewRecorder()
+	srv.handleLogSearch(w, httptest.NewRequest("GET", "/admin/logs/search?from=2025-12-24&to=2025-12-25&level=warn&q=SITE2", nil))
+	var res struct {
+		Lines []struct {
+			Level, Category, Text string
+		} `json:"lines"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if w.Code != http.StatusOK || len(res.Lines) != 2 || res.Lines[0].Category != "TOKEN" || res.Lines[1].Level != "ERROR" {
+		t.Errorf("检索结果不正确: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleLogSearch(w, httptest.NewRequest("GET", "/admin/logs/search?level=verbose", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("无效级别应返回 400, 实际 %d", w.Code)
+	}
 }
 
 func TestHandleRefresh_InvalidMethod(t *testing.T) {
@@ -128,3 +688,58 @@ func TestCORSMiddleware(t *testing.T) {
 		t.Error("缺少 CORS header")
 	}
 }
+
+func TestHandleProxy_Rejected(t *testing.T) {
+	srv := NewServer(proxy.NewClient(nil), nil)
+
+	for _, target := range []string{
+		"https://evil.example.com/steal",
+		"http://127.0.0.1:8765/admin/config",
+		"file:///etc/passwd",
+	} {
+		body := strings.NewReader(`{"url": "` + target + `"}`)
+		req := httptest.NewRequest("POST", "/proxy", body)
+		w := httptest.NewRecorder()
+
+		srv.handleProxy(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: 期望 403, 实际 %d", target, w.Code)
+		}
+	}
+}
+
+func TestHandleRunSnapshot_UsesScheduler(t *testing.T) {
+	cfg := config.GetConfig()
+	oldJobs := cfg.SnapshotJobs
+	cfg.SnapshotJobs = []config.SnapshotJob{{Name: "daily", Endpoint: "/orders/todo"}}
+	defer func() { cfg.SnapshotJobs = oldJobs }()
+
+	release := make(chan struct{})
+	var runs int32
+	sched := scheduler.NewScheduler()
+	sched.AddJob(scheduler.Job{Name: scheduler.JobSnapshotPrefix + "daily", Spec: "off", Run: func() error {
+		atomic.AddInt32(&runs, 1)
+		<-release
+		return nil
+	}})
+	defer close(release)
+
+	srv := NewServer(nil, nil)
+	srv.SetScheduler(sched)
+	run := func() int {
+		w := httptest.NewRecorder()
+		srv.handleRunSnapshot(w, httptest.NewRequest("POST", "/admin/snapshots/run", strings.NewReader(`{"name":"daily"}`)))
+		return w.Code
+	}
+
+	if code := run(); code != http.StatusOK {
+		t.Fatalf("立即采集应成功, 实际 %d", code)
+	}
+	if code := run(); code != http.StatusConflict {
+		t.Errorf("上次采集未结束时应返回 409, 实际 %d", code)
+	}
+	if n := atomic.LoadInt32(&runs); n > 1 {
+		t.Errorf("不应重复运行采集任务: %d", n)
+	}
+}
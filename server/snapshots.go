@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+	"zto-api-proxy/scheduler"
+	"zto-api-proxy/snapshot"
+)
+
+// snapshotStore 快照保存在 DataDir/snapshots 下
+func snapshotStore() *snapshot.Store {
+	return snapshot.NewStore(filepath.Join(config.GetConfig().DataDir, "snapshots"))
+}
+
+// snapshotHandlers 采集任务可调用的接口
+func (s *Server) snapshotHandlers() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"/proxy":           s.handleProxy,
+		"/orders":          s.handleOrders,
+		"/orders/todo":     s.handleOrdersTodo,
+		"/province-report": s.handleProvinceReport,
+	}
+}
+
+// captureWriter 记录进程内调用接口的响应
+type captureWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) Header() http.Header         { return c.header }
+func (c *captureWriter) Write(p []byte) (int, error) { return c.body.Write(p) }
+func (c *captureWriter) WriteHeader(code int) {
+	if c.code == 0 {
+		c.code = code
+	}
+}
+
+// RunSnapshot 执行一次采集任务并保存快照
+func (s *Server) RunSnapshot(name string) (snapshot.File, error) {
+	job, ok := config.GetSnapshotJob(name)
+	if !ok {
+		return snapshot.File{}, fmt.Errorf("采集任务不存在: %s", name)
+	}
+	now := time.Now()
+
+	u, err := url.Parse(snapshot.Expand(job.Endpoint, now))
+	if err != nil {
+		return snapshot.File{}, fmt.Errorf("无效的 endpoint: %v", err)
+	}
+	handler, ok := s.snapshotHandlers()[u.Path]
+	if !ok {
+		return snapshot.File{}, fmt.Errorf("不支持的 endpoint: %s", u.Path)
+	}
+
+	query := u.Query()
+	if job.Paginate {
+		query.Set("all", "true")
+	}
+	if job.Format != "" && job.Format != "json" {
+		query.Set("format", job.Format)
+	}
+	if job.Columns != "" {
+		query.Set("columns", job.Columns)
+	}
+	u.RawQuery = query.Encode()
+
+	var body []byte
+	if job.Body != nil {
+		body, err = json.Marshal(snapshot.ExpandValue(job.Body, now))
+		if err != nil {
+			return snapshot.File{}, fmt.Errorf("无效的 body: %v", err)
+		}
+	}
+	method := job.Method
+	if method == "" {
+		method = "GET"
+		if body != nil {
+			method = "POST"
+		}
+	}
+
+	r, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return snapshot.File{}, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Cache-Control", "no-cache")
+	if job.Account != "" {
+		r.Header.Set(accountHeader, job.Account)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), ctxCaller, "snapshot:"+name))
+
+	w := &captureWriter{header: http.Header{}}
+	handler(w, r)
+	if err := snapshotError(w); err != nil {
+		return snapshot.File{}, err
+	}
+
+	ext := job.Format
+	if ext == "" {
+		ext = "json"
+	}
+	store := snapshotStore()
+	file, err := store.Save(name, ext, w.body.Bytes(), now)
+	if err != nil {
+		return snapshot.File{}, fmt.Errorf("保存快照失败: %v", err)
+	}
+	logger.Info("采集任务 %s 已保存快照 %s (%d 字节)", name, file.Name, file.Size)
+
+	retention := job.RetentionDays
+	if retention == 0 {
+		retention = config.GetConfig().SnapshotRetentionDays
+	}
+	if n := store.Prune(name, retention, job.MaxFiles); n > 0 {
+		logger.Info("采集任务 %s 已清理 %d 个过期快照", name, n)
+	}
+	return file, nil
+}
+
+// snapshotError 检查接口响应是否成功
+func snapshotError(w *captureWriter) error {
+	if w.code != 0 && w.code != http.StatusOK {
+		return fmt.Errorf("接口返回 HTTP %d: %s", w.code, strings.TrimSpace(w.body.String()))
+	}
+	if !strings.HasPrefix(w.header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+	var result struct {
+		Success *bool  `json:"success"`
+		Error   string `json:"error"`
+	}
+	if json.Unmarshal(w.body.Bytes(), &result) == nil && result.Success != nil && !*result.Success {
+		return errors.New("接口返回失败: " + result.Error)
+	}
+	return nil
+}
+
+// 快照列表
+func (s *Server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	files, err := snapshotStore().List(r.URL.Query().Get("job"))
+	if err != nil {
+		s.jsonError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.jsonResponse(w, files)
+}
+
+// 下载快照
+func (s *Server) handleDownloadSnapshot(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	path, err := snapshotStore().Path(query.Get("job"), query.Get("file"))
+	if err != nil {
+		s.jsonError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(path)))
+	http.ServeFile(w, r, path)
+}
+
+// 立即执行采集任务，经由调度器运行，与定时采集互斥
+func (s *Server) handleRunSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 POST 方法")
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		s.jsonError(w, http.StatusBadRequest, "name 是必需的")
+		return
+	}
+	if _, ok := config.GetSnapshotJob(req.Name); !ok {
+		s.jsonError(w, http.StatusNotFound, "采集任务不存在: "+req.Name)
+		return
+	}
+
+	s.runJob(w, scheduler.JobSnapshotPrefix+req.Name)
+}
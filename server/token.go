@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"zto-api-proxy/browser"
+	"zto-api-proxy/config"
+	"zto-api-proxy/logger"
+	"zto-api-proxy/proxy"
+)
+
+// 手动导入 Token: 支持 Cookie 字符串、cURL 命令与 cookies.txt，
+// 探测接口验证通过后保存。JSON 请求体 {"account", "data"}，也可直接提交文本并用 ?account= 指定账号
+func (s *Server) handleTokenImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		s.jsonError(w, http.StatusMethodNotAllowed, "只支持 POST 方法")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, "读取请求失败: "+err.Error())
+		return
+	}
+	req := struct {
+		Account string `json:"account"`
+		Data    string `json:"data"`
+	}{Account: r.URL.Query().Get("account"), Data: string(body)}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.jsonError(w, http.StatusBadRequest, "无效的请求格式: "+err.Error())
+			return
+		}
+	}
+	if req.Account == "" {
+		req.Account = config.DefaultAccount
+	}
+	if !config.HasAccount(req.Account) {
+		s.jsonError(w, http.StatusBadRequest, "未知账号: "+req.Account)
+		return
+	}
+
+	token, format, err := browser.TokenFromInput(req.Data)
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, "解析失败: "+err.Error())
+		return
+	}
+
+	err = s.proxyClient.Probe(token.CookieString())
+	if err != nil {
+		code := http.StatusBadGateway
+		if errors.Is(err, proxy.ErrSessionRevoked) {
+			code = http.StatusUnprocessableEntity
+		}
+		s.jsonError(w, code, "验证失败: "+err.Error())
+		return
+	}
+
+	if err := config.SetAccountToken(req.Account, token); err != nil {
+		s.jsonError(w, http.StatusInternalServerError, "保存 Token 失败: "+err.Error())
+		return
+	}
+	s.recordProbe(req.Account, nil)
+	logger.With(logger.CategoryToken, "account", req.Account).InfoContext(r.Context(), "已手动导入 Token",
+		"format", format, "expires", token.ExpiresAt.Format("2006-01-02 15:04:05"))
+
+	names := make([]string, 0, len(token.Cookies))
+	for name := range token.Cookies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s.jsonResponse(w, map[string]interface{}{
+		"success":    true,
+		"account":    req.Account,
+		"format":     format,
+		"cookies":    names,
+		"expiresAt":  token.ExpiresAt,
+		"appExpire":  token.AppExpire,
+		"sessExpire": token.SessExpire,
+	})
+}
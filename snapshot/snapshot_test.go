@@ -0,0 +1,111 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	now := time.Date(2025, 12, 3, 14, 5, 6, 0, time.Local)
+
+	cases := map[string]string{
+		"{{today}}":                   "2025-12-03",
+		"{{yesterday}}":               "2025-12-02",
+		"{{today-7}}":                 "2025-11-26",
+		"{{ today + 1 }}":             "2025-12-04",
+		"{{todayStart}}~{{todayEnd}}": "2025-12-03 00:00:00~2025-12-03 23:59:59",
+		"{{monthStart}}":              "2025-12-01",
+		"{{now}}":                     "2025-12-03 14:05:06",
+		"{{unknown}}":                 "{{unknown}}",
+	}
+	for in, want := range cases {
+		if got := Expand(in, now); got != want {
+			t.Errorf("%q: 期望 %q, 实际 %q", in, want, got)
+		}
+	}
+}
+
+func TestExpandValue(t *testing.T) {
+	now := time.Date(2025, 12, 3, 0, 0, 0, 0, time.Local)
+	body := map[string]interface{}{
+		"startDate": "{{today-1}}",
+		"pageSize":  float64(100),
+		"dates":     []interface{}{"{{today}}", true},
+	}
+
+	got := ExpandValue(body, now)
+	want := map[string]interface{}{
+		"startDate": "2025-12-02",
+		"pageSize":  float64(100),
+		"dates":     []interface{}{"2025-12-03", true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("期望 %v, 实际 %v", want, got)
+	}
+	if body["startDate"] != "{{today-1}}" {
+		t.Error("不应修改原始模板")
+	}
+}
+
+func TestStore_SaveListPrune(t *testing.T) {
+	s := NewStore(t.TempDir())
+	base := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.Save("daily", "csv", []byte("a,b\n"), base.Add(time.Duration(-i)*time.Hour)); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+	}
+	old, err := s.Save("other", "ndjson", []byte("{}\n"), base.AddDate(0, 0, -40))
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+
+	files, _ := s.List("")
+	if len(files) != 4 || files[0].Job != "daily" || files[3].Name != old.Name {
+		t.Fatalf("列表应按时间倒序包含全部快照: %+v", files)
+	}
+	if files, _ := s.List("daily"); len(files) != 3 || files[0].Size != 4 {
+		t.Errorf("按任务过滤不正确: %+v", files)
+	}
+
+	if n := s.Prune("other", 30, 0); n != 1 {
+		t.Errorf("超过保留天数的快照应删除, 实际删除 %d", n)
+	}
+	if n := s.Prune("daily", 30, 2); n != 1 {
+		t.Errorf("超出数量上限应删除最旧的快照, 实际删除 %d", n)
+	}
+	files, _ = s.List("")
+	if len(files) != 2 {
+		t.Errorf("清理后应剩余 2 个快照: %+v", files)
+	}
+}
+
+func TestStore_Path(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	f, _ := s.Save("daily", "csv", []byte("x"), time.Now())
+
+	path, err := s.Path("daily", f.Name)
+	if err != nil || path != filepath.Join(dir, "daily", f.Name) {
+		t.Errorf("应返回快照路径: %q %v", path, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("x"), 0600)
+	for _, bad := range [][2]string{
+		{"daily", "../secret.txt"},
+		{"..", "secret.txt"},
+		{"daily", "missing.csv"},
+		{"", f.Name},
+	} {
+		if _, err := s.Path(bad[0], bad[1]); err != ErrNotFound {
+			t.Errorf("%v 应返回 ErrNotFound, 实际 %v", bad, err)
+		}
+	}
+	if _, err := s.Save("../x", "csv", nil, time.Now()); err == nil {
+		t.Error("非法任务名应保存失败")
+	}
+}
@@ -0,0 +1,146 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const timeLayout = "20060102-150405"
+
+// ErrNotFound 快照不存在或名称非法
+var ErrNotFound = errors.New("快照不存在")
+
+// File 快照文件信息
+type File struct {
+	Job  string    `json:"job"`
+	Name string    `json:"name"`
+	Size int64     `json:"size"`
+	Time time.Time `json:"time"`
+}
+
+// Store 快照目录，每个任务一个子目录
+type Store struct {
+	dir string
+}
+
+// NewStore 创建快照存储
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// validName 任务名与文件名只允许出现在单级目录中
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\:`) && filepath.Base(name) == name
+}
+
+// Save 写入一个快照，文件名为 <job>-<时间>.<ext>
+func (s *Store) Save(job, ext string, data []byte, now time.Time) (File, error) {
+	if !validName(job) {
+		return File{}, fmt.Errorf("无效的任务名: %q", job)
+	}
+	dir := filepath.Join(s.dir, job)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return File{}, err
+	}
+
+	name := fmt.Sprintf("%s-%s.%s", job, now.Format(timeLayout), ext)
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return File{}, err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		os.Remove(tmp)
+		return File{}, err
+	}
+	return File{Job: job, Name: name, Size: int64(len(data)), Time: now}, nil
+}
+
+// List 列出快照，job 为空时列出全部任务，按时间倒序
+func (s *Store) List(job string) ([]File, error) {
+	jobs := []string{job}
+	if job == "" {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		jobs = jobs[:0]
+		for _, e := range entries {
+			if e.IsDir() {
+				jobs = append(jobs, e.Name())
+			}
+		}
+	} else if !validName(job) {
+		return nil, ErrNotFound
+	}
+
+	files := []File{}
+	for _, j := range jobs {
+		entries, err := os.ReadDir(filepath.Join(s.dir, j))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, File{Job: j, Name: e.Name(), Size: info.Size(), Time: fileTime(j, e.Name(), info)})
+		}
+	}
+	sort.Slice(files, func(a, b int) bool { return files[a].Time.After(files[b].Time) })
+	return files, nil
+}
+
+// fileTime 优先从文件名解析快照时间，失败时使用修改时间
+func fileTime(job, name string, info os.FileInfo) time.Time {
+	stamp := strings.TrimPrefix(name, job+"-")
+	if i := strings.IndexByte(stamp, '.'); i >= 0 {
+		stamp = stamp[:i]
+	}
+	if t, err := time.ParseInLocation(timeLayout, stamp, time.Local); err == nil {
+		return t
+	}
+	return info.ModTime()
+}
+
+// Path 返回快照文件路径，拒绝越出任务目录的名称
+func (s *Store) Path(job, name string) (string, error) {
+	if !validName(job) || !validName(name) {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(s.dir, job, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// Prune 删除超过保留天数或超出数量上限的快照，返回删除的数量
+func (s *Store) Prune(job string, retentionDays, maxFiles int) int {
+	files, err := s.List(job)
+	if err != nil {
+		return 0
+	}
+
+	removed := 0
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	for i, f := range files {
+		expired := retentionDays > 0 && f.Time.Before(cutoff)
+		overflow := maxFiles > 0 && i >= maxFiles
+		if expired || overflow {
+			if os.Remove(filepath.Join(s.dir, f.Job, f.Name)) == nil {
+				removed++
+			}
+		}
+	}
+	return removed
+}
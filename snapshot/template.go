@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*(\w+)\s*(?:([+-])\s*(\d+))?\s*\}\}`)
+
+// Expand 替换字符串中的日期占位符，可附带天数偏移，如 {{today-7}}：
+//
+//	{{today}}       2006-01-02
+//	{{yesterday}}   昨天的日期
+//	{{todayStart}}  2006-01-02 00:00:00
+//	{{todayEnd}}    2006-01-02 23:59:59
+//	{{monthStart}}  当月第一天
+//	{{now}}         2006-01-02 15:04:05
+//
+// 未知占位符原样保留。
+func Expand(s string, now time.Time) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		sub := placeholder.FindStringSubmatch(m)
+		days := 0
+		if sub[3] != "" {
+			days, _ = strconv.Atoi(sub[3])
+			if sub[2] == "-" {
+				days = -days
+			}
+		}
+		day := now.AddDate(0, 0, days)
+
+		switch sub[1] {
+		case "today":
+			return day.Format("2006-01-02")
+		case "yesterday":
+			return day.AddDate(0, 0, -1).Format("2006-01-02")
+		case "todayStart":
+			return day.Format("2006-01-02") + " 00:00:00"
+		case "todayEnd":
+			return day.Format("2006-01-02") + " 23:59:59"
+		case "monthStart":
+			return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location()).Format("2006-01-02")
+		case "now":
+			return day.Format("2006-01-02 15:04:05")
+		}
+		return m
+	})
+}
+
+// ExpandValue 递归替换 JSON 值中所有字符串的占位符，返回新的值
+func ExpandValue(v interface{}, now time.Time) interface{} {
+	switch val := v.(type) {
+	case string:
+		return Expand(val, now)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, inner := range val {
+			out[k] = ExpandValue(inner, now)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, inner := range val {
+			out[i] = ExpandValue(inner, now)
+		}
+		return out
+	}
+	return v
+}